@@ -0,0 +1,443 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/rs/zerolog"
+)
+
+// ExportCmd is the `clod export` subcommand: it packages one Slack thread's
+// session into a zip archive (see ExportSession).
+type ExportCmd struct {
+	SessionStore         string `kong:"default='sessions.json',env='SESSION_STORE',help='Session store backend URL (see Flags.SessionStore)'"`
+	FileMappingStorePath string `kong:"default='file_mappings.json',name='file-mapping-store',env='FILE_MAPPING_STORE',help='Path to the JSON file tracking Slack file ID <-> local path mappings'"`
+
+	Session string `kong:"arg,help='Thread to export, as channel:thread_ts'"`
+	Output  string `kong:"arg,optional,help='Output zip path; defaults to <channel>-<thread_ts>.zip'"`
+}
+
+func (cmd *ExportCmd) Run(logger zerolog.Logger) error {
+	channelID, threadTS, ok := strings.Cut(cmd.Session, ":")
+	if !ok {
+		return oops.New("session %q must be formatted as channel:thread_ts", cmd.Session)
+	}
+
+	sessions, err := NewSessionStore(cmd.SessionStore)
+	if err != nil {
+		return err
+	}
+
+	mappings, err := NewFileMappingStore(cmd.FileMappingStorePath)
+	if err != nil {
+		return err
+	}
+
+	output := cmd.Output
+	if output == "" {
+		output = channelID + "-" + threadTS + ".zip"
+	}
+
+	if err := ExportSession(sessions, mappings, channelID, threadTS, output, logger); err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("session", cmd.Session).
+		Str("output", output).
+		Msg("exported session archive")
+
+	return nil
+}
+
+// ImportCmd is the `clod import` subcommand: it rehydrates a zip archive
+// produced by clod export (or compatible tooling) under a task directory
+// and registers it with the destination SessionStore (see ImportSession).
+type ImportCmd struct {
+	SessionStore string `kong:"default='sessions.json',env='SESSION_STORE',help='Session store backend URL (see Flags.SessionStore)'"`
+	AgentsPath   string `kong:"default='.',env='AGENTS_PATH',help='Base path to place the imported task directory under'"`
+	TaskName     string `kong:"name='task-name',help='Name to register the imported task directory under; defaults to the archive manifest task name'"`
+
+	Archive string `kong:"arg,help='Path to a zip archive produced by clod export'"`
+}
+
+func (cmd *ImportCmd) Run(logger zerolog.Logger) error {
+	sessions, err := NewSessionStore(cmd.SessionStore)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := ImportSession(sessions, cmd.AgentsPath, cmd.Archive, cmd.TaskName, logger)
+	if err != nil {
+		return err
+	}
+
+	if err := sessions.Save(); err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("archive", cmd.Archive).
+		Str("channel", manifest.ChannelID).
+		Str("thread_ts", manifest.ThreadTS).
+		Msg("imported session archive")
+
+	return nil
+}
+
+// Zip entry layout produced by ExportSession and understood by
+// ImportSession. It mirrors the convention used by tools like
+// slack-advanced-exporter (sessions.json, tasks/<name>/…,
+// uploads/<file_id>/<filename>) so archives can be produced or consumed by
+// tooling other than clod itself.
+const (
+	archiveManifestEntry = "manifest.json"
+	archiveSessionsEntry = "sessions.json"
+	archiveTasksDir      = "tasks/"
+	archiveUploadsDir    = "uploads/"
+)
+
+// SessionArchiveManifest describes the Slack thread bundled into a session
+// archive: the channel/thread/task it came from, who was on it, and when it
+// was originally created versus exported. ImportSession restores
+// OriginalCreatedAt/OriginalUpdatedAt into the rehydrated SessionMapping so
+// a round-tripped archive doesn't look freshly created.
+type SessionArchiveManifest struct {
+	ChannelID string   `json:"channel_id"`
+	ThreadTS  string   `json:"thread_ts"`
+	TaskName  string   `json:"task_name"`
+	Users     []string `json:"users"`
+
+	OriginalCreatedAt time.Time `json:"original_created_at"`
+	OriginalUpdatedAt time.Time `json:"original_updated_at"`
+	ExportedAt        time.Time `json:"exported_at"`
+}
+
+// ExportSession packages channelID/threadTS's SessionMapping, its task
+// directory (inputs and outputs), and any Slack files tracked for that
+// thread (see FileMappingStore) into a zip archive at outputPath. mappings
+// may be nil, in which case the archive carries no uploads/ entries.
+func ExportSession(sessions SessionStore, mappings *FileMappingStore, channelID, threadTS, outputPath string, logger zerolog.Logger) (err error) {
+	session := sessions.Get(channelID, threadTS)
+	if session == nil {
+		return oops.New("no session mapping for channel %q thread %q", channelID, threadTS)
+	}
+
+	var users []string
+	if session.UserID != "" {
+		users = []string{session.UserID}
+	}
+
+	manifest := SessionArchiveManifest{
+		ChannelID:         channelID,
+		ThreadTS:          threadTS,
+		TaskName:          session.TaskName,
+		Users:             users,
+		OriginalCreatedAt: session.CreatedAt,
+		OriginalUpdatedAt: session.UpdatedAt,
+		ExportedAt:        time.Now(),
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer func() {
+		oops.ChainP(&err, out.Close())
+	}()
+
+	zw := zip.NewWriter(out)
+	defer func() {
+		oops.ChainP(&err, zw.Close())
+	}()
+
+	if err = writeZipJSON(zw, archiveManifestEntry, manifest); err != nil {
+		return err
+	}
+	if err = writeZipJSON(zw, archiveSessionsEntry, []*SessionMapping{session}); err != nil {
+		return err
+	}
+
+	if session.TaskPath != "" {
+		if err = addDirToZip(zw, archiveTasksDir+session.TaskName+"/", session.TaskPath); err != nil {
+			return err
+		}
+	}
+
+	if mappings != nil {
+		for _, ref := range mappings.ListByThread(channelID, threadTS) {
+			entry := archiveUploadsDir + ref.SlackFileID + "/" + filepath.Base(ref.LocalPath)
+			if addErr := addFileToZip(zw, entry, ref.LocalPath); addErr != nil {
+				if errors.Is(addErr, fs.ErrNotExist) {
+					logger.Warn().
+						Str("file_id", ref.SlackFileID).
+						Str("local_path", ref.LocalPath).
+						Msg("skipping archived file: local copy no longer exists")
+					continue
+				}
+				err = addErr
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ImportSession rehydrates an archive produced by ExportSession (or
+// compatible external tooling) under taskName: it extracts tasks/<name>/…
+// into agentsPath/taskName, extracts uploads/<file_id>/<filename> alongside
+// it, merges the archived SessionMapping into sessions (regenerating
+// CreatedAt/UpdatedAt, since this is a new mapping on this host), and
+// re-discovers agentsPath so TaskRegistry picks up the new task directory.
+// If taskName is empty, the manifest's own TaskName is used.
+func ImportSession(sessions SessionStore, agentsPath, archivePath, taskName string, logger zerolog.Logger) (manifest SessionArchiveManifest, err error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return manifest, oops.Trace(err)
+	}
+	defer func() {
+		oops.ChainP(&err, zr.Close())
+	}()
+
+	if err = readZipJSON(&zr.Reader, archiveManifestEntry, &manifest); err != nil {
+		return manifest, err
+	}
+
+	var mappings []*SessionMapping
+	if err = readZipJSON(&zr.Reader, archiveSessionsEntry, &mappings); err != nil {
+		return manifest, err
+	}
+	if len(mappings) == 0 {
+		return manifest, oops.New("archive %q has no session mapping", archivePath)
+	}
+	session := mappings[0]
+
+	if taskName == "" {
+		taskName = manifest.TaskName
+	}
+	if taskName == "" {
+		return manifest, oops.New("archive %q has no task name; pass --task-name", archivePath)
+	}
+
+	taskPath := filepath.Join(agentsPath, taskName)
+	if err = os.MkdirAll(taskPath, 0o755); err != nil {
+		return manifest, oops.Trace(err)
+	}
+
+	if err = extractZipDir(&zr.Reader, archiveTasksDir+manifest.TaskName+"/", taskPath); err != nil {
+		return manifest, err
+	}
+	if err = extractZipUploads(&zr.Reader, taskPath); err != nil {
+		return manifest, err
+	}
+
+	session.ChannelID = manifest.ChannelID
+	session.ThreadTS = manifest.ThreadTS
+	session.TaskName = taskName
+	session.TaskPath = taskPath
+	session.CreatedAt = time.Now()
+	session.UpdatedAt = time.Now()
+
+	if err = sessions.Put(session); err != nil {
+		return manifest, err
+	}
+
+	tasks, err := NewTaskRegistry(agentsPath)
+	if err != nil {
+		return manifest, err
+	}
+	if _, getErr := tasks.Get(taskName); getErr != nil {
+		logger.Warn().
+			Str("task_name", taskName).
+			Str("task_path", taskPath).
+			Msg("imported task directory has no .clod/system/run; it won't show up in task discovery")
+	}
+
+	return manifest, nil
+}
+
+// writeZipJSON marshals v as indented JSON and writes it to zw as name.
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return oops.Trace(err)
+	}
+
+	return nil
+}
+
+// readZipJSON finds name among zr's files and unmarshals its contents into
+// v.
+func readZipJSON(zr *zip.Reader, name string, v any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return oops.Trace(err)
+	}
+
+	return nil
+}
+
+// addDirToZip walks dir recursively, adding every regular file under prefix
+// in the archive with dir's structure preserved.
+func addDirToZip(zw *zip.Writer, prefix, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return oops.Trace(walkErr)
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return oops.Trace(err)
+		}
+
+		return addFileToZip(zw, prefix+filepath.ToSlash(rel), path)
+	})
+}
+
+// addFileToZip copies localPath's contents into the archive as entry.
+func addFileToZip(zw *zip.Writer, entry, localPath string) error {
+	in, err := os.Open(localPath)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer in.Close()
+
+	w, err := zw.Create(entry)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return oops.Trace(err)
+	}
+
+	return nil
+}
+
+// extractZipDir extracts every entry under prefix in zr into destDir,
+// stripping prefix and recreating the remaining relative path (e.g. a task
+// directory's own subdirectories). prefix comes from the archive's own
+// manifest.json (TaskName), not a validated flag, so an entry whose
+// resulting path would escape destDir (via "../" segments or an absolute
+// path) is rejected rather than extracted.
+func extractZipDir(zr *zip.Reader, prefix, destDir string) error {
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) || f.FileInfo().IsDir() {
+			continue
+		}
+
+		rel := strings.TrimPrefix(f.Name, prefix)
+		destPath, err := safeJoin(destDir, rel)
+		if err != nil {
+			return oops.New("%s: %v", f.Name, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+			return oops.Trace(err)
+		}
+
+		if err := extractZipEntry(f, destPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// safeJoin joins dir and rel the way filepath.Join would, but rejects the
+// result if it would land outside dir (e.g. rel contains ".." segments or is
+// itself absolute) — a zip-slip guard for paths sourced from archive entry
+// names rather than trusted input.
+func safeJoin(dir, rel string) (string, error) {
+	destPath := filepath.Join(dir, filepath.FromSlash(rel))
+
+	if !isContainedIn(dir, destPath) {
+		return "", oops.New("entry path %q escapes destination directory", rel)
+	}
+
+	return destPath, nil
+}
+
+// isContainedIn reports whether path is dir itself or a descendant of dir,
+// after cleaning both. Used to reject an entry/filename-derived path that
+// would otherwise land outside its intended base directory (see safeJoin,
+// FileHandler.HandleDeletedFile).
+func isContainedIn(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// extractZipUploads extracts every uploads/<file_id>/<filename> entry in zr
+// into taskPath/<filename>, dropping the file_id directory so an imported
+// attachment sits directly in the task directory, the same place
+// FileHandler.DownloadToTask would have put it.
+func extractZipUploads(zr *zip.Reader, taskPath string) error {
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, archiveUploadsDir) || f.FileInfo().IsDir() {
+			continue
+		}
+
+		filename := filepath.Base(f.Name)
+		destPath := filepath.Join(taskPath, filename)
+
+		if err := extractZipEntry(f, destPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractZipEntry writes one zip.File's contents to destPath.
+func extractZipEntry(f *zip.File, destPath string) (err error) {
+	in, err := f.Open()
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer func() {
+		oops.ChainP(&err, out.Close())
+	}()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return oops.Trace(err)
+	}
+
+	return nil
+}
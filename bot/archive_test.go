@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestExportImportSessionRoundTrip(t *testing.T) {
+	srcAgents := t.TempDir()
+	taskPath := filepath.Join(srcAgents, "mytask")
+	if err := os.MkdirAll(filepath.Join(taskPath, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(taskPath, "input.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(taskPath, "sub", "output.txt"), []byte("world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uploadPath := filepath.Join(taskPath, "photo.png")
+	if err := os.WriteFile(uploadPath, []byte("fakepng"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &SessionMapping{
+		ChannelID: "C1",
+		ThreadTS:  "T1",
+		TaskName:  "mytask",
+		TaskPath:  taskPath,
+		SessionID: "S1",
+		UserID:    "U1",
+	}
+	if err := sessions.Put(session); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings, err := NewFileMappingStore(filepath.Join(t.TempDir(), "file_mappings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mappings.Track(FileRef{SlackFileID: "F1", LocalPath: uploadPath, ChannelID: "C1", ThreadTS: "T1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "archive.zip")
+	if err := ExportSession(sessions, mappings, "C1", "T1", archivePath, zerolog.Nop()); err != nil {
+		t.Fatal(err)
+	}
+
+	destAgents := t.TempDir()
+	destSessions, err := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := ImportSession(destSessions, destAgents, archivePath, "", zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ChannelID != "C1" || manifest.ThreadTS != "T1" || manifest.TaskName != "mytask" {
+		t.Fatalf("manifest = %+v, want channel C1, thread T1, task mytask", manifest)
+	}
+
+	imported := destSessions.Get("C1", "T1")
+	if imported == nil {
+		t.Fatal("expected an imported session mapping")
+	}
+	wantTaskPath := filepath.Join(destAgents, "mytask")
+	if imported.TaskPath != wantTaskPath {
+		t.Fatalf("imported.TaskPath = %q, want %q", imported.TaskPath, wantTaskPath)
+	}
+
+	for _, rel := range []string{"input.txt", filepath.Join("sub", "output.txt"), "photo.png"} {
+		if _, err := os.Stat(filepath.Join(wantTaskPath, rel)); err != nil {
+			t.Errorf("expected %q to exist in imported task directory: %v", rel, err)
+		}
+	}
+}
+
+func TestExportSessionUnknownThread(t *testing.T) {
+	sessions, err := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ExportSession(sessions, nil, "C1", "T1", filepath.Join(t.TempDir(), "archive.zip"), zerolog.Nop())
+	if err == nil {
+		t.Fatal("expected an error exporting a thread with no session mapping")
+	}
+}
+
+// TestImportSessionRejectsZipSlip crafts an archive whose manifest TaskName
+// is itself a traversal ("../../escaped") and whose tasks/ entry also
+// escapes its own task directory via "../", and checks ImportSession
+// refuses to extract it outside agentsPath.
+func TestImportSessionRejectsZipSlip(t *testing.T) {
+	outerDir := t.TempDir()
+	agentsPath := filepath.Join(outerDir, "agents")
+	if err := os.MkdirAll(agentsPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	canary := filepath.Join(outerDir, "canary.txt")
+
+	archivePath := filepath.Join(t.TempDir(), "evil.zip")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+
+	manifest := SessionArchiveManifest{ChannelID: "C1", ThreadTS: "T1", TaskName: "evil"}
+	if err := writeZipJSON(zw, archiveManifestEntry, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeZipJSON(zw, archiveSessionsEntry, []*SessionMapping{{ChannelID: "C1", ThreadTS: "T1", SessionID: "S1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := zw.Create(archiveTasksDir + "evil/" + "../../canary.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.Write([]byte("pwned")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := NewSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportSession(sessions, agentsPath, archivePath, "", zerolog.Nop()); err == nil {
+		t.Fatal("expected ImportSession to reject a zip-slip entry")
+	}
+
+	if _, err := os.Stat(canary); err == nil {
+		t.Fatal("zip-slip entry was extracted outside agentsPath")
+	}
+}
+
+func TestIsContainedIn(t *testing.T) {
+	cases := []struct {
+		dir, path string
+		want      bool
+	}{
+		{"/tasks/t1", "/tasks/t1", true},
+		{"/tasks/t1", "/tasks/t1/out.txt", true},
+		{"/tasks/t1", "/tasks/t1/sub/out.txt", true},
+		{"/tasks/t1", "/tasks/t1../escaped", false},
+		{"/tasks/t1", "/tasks/t2/out.txt", false},
+		{"/tasks/t1", "/etc/passwd", false},
+	}
+
+	for _, c := range cases {
+		if got := isContainedIn(c.dir, c.path); got != c.want {
+			t.Errorf("isContainedIn(%q, %q) = %v, want %v", c.dir, c.path, got, c.want)
+		}
+	}
+}
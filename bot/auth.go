@@ -1,8 +1,58 @@
 package main
 
-// Authorizer manages user authorization via an allowlist.
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"gopkg.in/yaml.v3"
+)
+
+// usergroupCacheTTL is how long a resolved Slack subteam's member list is
+// cached before being re-fetched.
+const usergroupCacheTTL = 5 * time.Minute
+
+// AuthConfig is the on-disk shape of the authorization rules. It can be
+// loaded as JSON or YAML (selected by file extension) and is hot-reloaded
+// whenever the file backing it changes (see Authorizer.Watch).
+type AuthConfig struct {
+	// AllowedUsers is the flat allowlist of Slack user IDs.
+	AllowedUsers []string `json:"allowed_users" yaml:"allowed_users"`
+	// Roles maps a role name (e.g. "admin") to the Slack user IDs and/or
+	// subteam (usergroup) IDs granted that role. Members of a role are
+	// implicitly added to the allowlist.
+	Roles map[string][]string `json:"roles" yaml:"roles"`
+}
+
+// cachedGroup is a Slack subteam's resolved membership, cached to avoid a
+// round-trip on every authorization check.
+type cachedGroup struct {
+	members map[string]bool
+	expires time.Time
+}
+
+// Authorizer manages user authorization via an allowlist, plus optional
+// role-scoped permissions (e.g. "admin") whose members can be Slack
+// subteams as well as individual users.
 type Authorizer struct {
+	logger zerolog.Logger
+
+	mu      sync.RWMutex
 	allowed map[string]bool
+	roles   map[string][]string
+	slack   *slack.Client // resolves subteam membership; nil until SetSlackClient
+
+	groupsMu sync.Mutex
+	groups   map[string]cachedGroup
 }
 
 // NewAuthorizer creates a new Authorizer with the given allowed user IDs.
@@ -11,19 +61,210 @@ func NewAuthorizer(allowedUsers []string) *Authorizer {
 	for _, userID := range allowedUsers {
 		allowed[userID] = true
 	}
-	return &Authorizer{allowed: allowed}
+	return &Authorizer{
+		allowed: allowed,
+		roles:   make(map[string][]string),
+		groups:  make(map[string]cachedGroup),
+	}
+}
+
+// SetSlackClient supplies the Slack client used to resolve subteam
+// (usergroup) membership in role rules. Role rules that name a subteam are
+// treated as having no members until this is called.
+func (a *Authorizer) SetSlackClient(client *slack.Client) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.slack = client
+}
+
+// LoadConfig (re)loads the authorization rules from a JSON or YAML file
+// (selected by extension), replacing the current allowlist and roles.
+func (a *Authorizer) LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	var config AuthConfig
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return oops.Trace(err)
+		}
+	default:
+		if err := json.Unmarshal(data, &config); err != nil {
+			return oops.Trace(err)
+		}
+	}
+
+	allowed := make(map[string]bool, len(config.AllowedUsers))
+	for _, userID := range config.AllowedUsers {
+		allowed[userID] = true
+	}
+	for _, members := range config.Roles {
+		for _, id := range members {
+			if !isSubteamID(id) {
+				allowed[id] = true
+			}
+		}
+	}
+
+	a.mu.Lock()
+	a.allowed = allowed
+	a.roles = config.Roles
+	a.mu.Unlock()
+
+	return nil
+}
+
+// SetAllowedUsers replaces the flat allowlist in place, leaving any
+// role-based rules loaded via LoadConfig untouched. It's used by CLI.Run to
+// apply a freshly re-read ALLOWED_USERS environment variable on reload,
+// independent of file-backed config.
+func (a *Authorizer) SetAllowedUsers(userIDs []string) {
+	allowed := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		allowed[userID] = true
+	}
+
+	a.mu.Lock()
+	for _, members := range a.roles {
+		for _, id := range members {
+			if !isSubteamID(id) {
+				allowed[id] = true
+			}
+		}
+	}
+	a.allowed = allowed
+	a.mu.Unlock()
 }
 
-// IsAuthorized returns true if the user ID is in the allowlist.
-// If the allowlist is empty, all users are denied.
-func (a *Authorizer) IsAuthorized(userID string) bool {
-	if len(a.allowed) == 0 {
+// Watch reloads the config at path whenever it changes, until ctx is
+// cancelled. It's meant to be run in its own goroutine, e.g. `go
+// auth.Watch(ctx, path, logger)`.
+func (a *Authorizer) Watch(ctx context.Context, path string, logger zerolog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer watcher.Close()
+
+	// Watch the containing directory rather than the file itself so the
+	// watch survives editors that replace the file (write to a temp file,
+	// then rename over it) instead of writing in place.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return oops.Trace(err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := a.LoadConfig(path); err != nil {
+				logger.Error().Err(err).Str("path", path).Msg("failed to reload auth config")
+				continue
+			}
+			logger.Info().Str("path", path).Msg("reloaded auth config")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error().Err(err).Msg("auth config watcher error")
+		}
+	}
+}
+
+// isSubteamID reports whether id looks like a Slack subteam (usergroup) ID
+// rather than a user ID; subteam IDs start with "S".
+func isSubteamID(id string) bool {
+	return strings.HasPrefix(id, "S")
+}
+
+// IsAuthorized returns true if the user ID is in the allowlist. If role is
+// non-empty, the allowlist is bypassed and the user is authorized purely by
+// belonging to that role, either directly or via a Slack subteam granted
+// the role — a role member is implicitly authorized even if they were never
+// added to the flat allowlist, which is the only way a subteam-only role
+// (no individually-listed AllowedUsers) can ever grant access, since
+// subteam membership is resolved against the live Slack API rather than
+// baked into the allowlist at LoadConfig time. If the allowlist is empty
+// and role is empty, all users are denied.
+func (a *Authorizer) IsAuthorized(userID, role string) bool {
+	a.mu.RLock()
+	allowed := len(a.allowed) > 0 && a.allowed[userID]
+	members, hasRole := a.roles[role]
+	a.mu.RUnlock()
+
+	if role == "" {
+		return allowed
+	}
+	if !hasRole {
 		return false
 	}
-	return a.allowed[userID]
+
+	for _, id := range members {
+		if id == userID {
+			return true
+		}
+		if isSubteamID(id) && a.subteamHasMember(id, userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin is a convenience wrapper for IsAuthorized(userID, "admin").
+func (a *Authorizer) IsAdmin(userID string) bool {
+	return a.IsAuthorized(userID, "admin")
 }
 
-// RejectMessage returns a friendly message for unauthorized users.
-func (a *Authorizer) RejectMessage() string {
+// subteamHasMember resolves a Slack subteam's membership, caching the
+// result for usergroupCacheTTL to avoid a round-trip on every check.
+func (a *Authorizer) subteamHasMember(subteamID, userID string) bool {
+	a.mu.RLock()
+	client := a.slack
+	a.mu.RUnlock()
+	if client == nil {
+		return false
+	}
+
+	a.groupsMu.Lock()
+	defer a.groupsMu.Unlock()
+
+	if cached, ok := a.groups[subteamID]; ok && time.Now().Before(cached.expires) {
+		return cached.members[userID]
+	}
+
+	userIDs, err := client.GetUserGroupMembers(subteamID)
+	if err != nil {
+		a.logger.Error().Err(err).Str("subteam", subteamID).Msg("failed to resolve subteam members")
+		return false
+	}
+
+	members := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		members[id] = true
+	}
+	a.groups[subteamID] = cachedGroup{members: members, expires: time.Now().Add(usergroupCacheTTL)}
+
+	return members[userID]
+}
+
+// RejectMessage returns a friendly message for unauthorized users. If role
+// is non-empty, the message names the role required.
+func (a *Authorizer) RejectMessage(role string) string {
+	if role != "" {
+		return fmt.Sprintf("Sorry, you need the %q role to do that. Please contact an administrator if you need access.", role)
+	}
 	return "Sorry, you're not authorized to use this bot. Please contact an administrator if you need access."
 }
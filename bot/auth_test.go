@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestIsAuthorizedFlatAllowlist(t *testing.T) {
+	a := NewAuthorizer([]string{"U1"})
+
+	if !a.IsAuthorized("U1", "") {
+		t.Fatal("expected U1 to be authorized")
+	}
+	if a.IsAuthorized("U2", "") {
+		t.Fatal("expected U2 to be denied")
+	}
+}
+
+func TestIsAuthorizedEmptyAllowlistDeniesEveryone(t *testing.T) {
+	a := NewAuthorizer(nil)
+
+	if a.IsAuthorized("U1", "") {
+		t.Fatal("expected an empty allowlist to deny everyone")
+	}
+}
+
+func TestIsAuthorizedRoleByDirectMember(t *testing.T) {
+	a := NewAuthorizer(nil)
+	a.roles = map[string][]string{"admin": {"U1"}}
+
+	if !a.IsAuthorized("U1", "admin") {
+		t.Fatal("expected U1 to be authorized for the admin role")
+	}
+	if a.IsAuthorized("U2", "admin") {
+		t.Fatal("expected U2 to be denied the admin role")
+	}
+}
+
+// TestIsAuthorizedSubteamOnlyRole covers a role defined purely via a Slack
+// subteam ID with no individually-listed AllowedUsers: the field doc on
+// AuthConfig.Roles says members of a role are implicitly authorized, and
+// that must hold even though subteam membership is resolved against the
+// live Slack API rather than baked into the flat allowlist at LoadConfig
+// time.
+func TestIsAuthorizedSubteamOnlyRole(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"ok":    true,
+			"users": []string{"U1"},
+		})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "auth.json")
+	config := AuthConfig{Roles: map[string][]string{"admin": {"S1"}}}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthorizer(nil)
+	if err := a.LoadConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+	a.SetSlackClient(slack.New("xoxb-fake", slack.OptionAPIURL(srv.URL+"/")))
+
+	if len(a.allowed) != 0 {
+		t.Fatalf("expected a subteam-only role to add nothing to the flat allowlist, got %v", a.allowed)
+	}
+	if !a.IsAuthorized("U1", "admin") {
+		t.Fatal("expected U1 (a subteam member) to be authorized for the admin role")
+	}
+	if a.IsAuthorized("U2", "admin") {
+		t.Fatal("expected U2 (not a subteam member) to be denied the admin role")
+	}
+}
@@ -2,87 +2,126 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
 
-	"github.com/calebcase/oops"
+	"github.com/calebcase/clod/bot/chat"
 	"github.com/rs/zerolog"
-	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/slackevents"
-	"github.com/slack-go/slack/socketmode"
 )
 
-// Bot manages the Slack connection and event handling.
+// Bot manages the chat connection and event handling. It is transport
+// agnostic: chat.ChatTransport decides whether events come from Slack,
+// another chat backend, or a local terminal (see transport/slack and
+// transport/terminal).
 type Bot struct {
-	client        *slack.Client
-	socket        *socketmode.Client
-	socketHandler *socketmode.SocketmodeHandler
-	auth          *Authorizer
-	tasks         *TaskRegistry
-	sessions      *SessionStore
-	runner        *Runner
-	files         *FileHandler
-	logger        zerolog.Logger
-	handler       *Handler
+	transport chat.ChatTransport
+	auth      *Authorizer
+	sessions  SessionStore
+	runner    *Runner
+	policy    *PolicyEngine
+	quorum    *QuorumPolicy // nil when no quorum ruleset is configured
+	files     *FileHandler // nil when the transport has no file-sharing equivalent (e.g. terminal)
+	notifier  *Notifier
+	cache     *SlackCache // nil when the transport has no Slack client to cache calls to (e.g. terminal)
+	logger    zerolog.Logger
+	handler   *Handler
+
+	// tasksMu guards tasks and commands, which are swapped out wholesale
+	// on a config reload (see CLI.Run's SIGHUP/fsnotify handling) while
+	// the bot keeps running against its existing Socket Mode connection.
+	tasksMu  sync.RWMutex
+	tasks    *TaskRegistry
+	commands *CommandRouter
+
+	// messageThreads maps a posted message's own ts to the ts of the thread
+	// it was posted into (itself, for a thread root), so a reaction landing
+	// on any bot message can be resolved back to the thread's progressKey
+	// (see trackMessage and ThreadForMessage).
+	messageThreads sync.Map // key(channelID, messageTS) -> threadTS
 }
 
-// NewBot creates a new Bot instance.
+// NewBot creates a new Bot instance around a chat.ChatTransport. files and
+// cache may be nil for transports with no file-sharing equivalent or Slack
+// client, respectively (e.g. terminal).
 func NewBot(
-	botToken string,
-	appToken string,
+	transport chat.ChatTransport,
 	auth *Authorizer,
 	tasks *TaskRegistry,
-	sessions *SessionStore,
+	commands *CommandRouter,
+	sessions SessionStore,
 	runner *Runner,
+	policy *PolicyEngine,
+	quorum *QuorumPolicy,
+	files *FileHandler,
+	notifier *Notifier,
+	cache *SlackCache,
 	verboseTools []string,
 	logger zerolog.Logger,
 ) (*Bot, error) {
-	client := slack.New(
-		botToken,
-		slack.OptionAppLevelToken(appToken),
-	)
-
-	socket := socketmode.New(
-		client,
-		socketmode.OptionDebug(logger.GetLevel() <= zerolog.DebugLevel),
-	)
-
-	// Create the socketmode handler for registering event callbacks
-	socketHandler := socketmode.NewSocketmodeHandler(socket)
-
 	bot := &Bot{
-		client:        client,
-		socket:        socket,
-		socketHandler: socketHandler,
-		auth:          auth,
-		tasks:         tasks,
-		sessions:      sessions,
-		runner:        runner,
-		files:         NewFileHandler(client, logger),
-		logger:        logger.With().Str("component", "bot").Logger(),
+		transport: transport,
+		auth:      auth,
+		tasks:     tasks,
+		commands:  commands,
+		sessions:  sessions,
+		runner:    runner,
+		policy:    policy,
+		quorum:    quorum,
+		files:     files,
+		notifier:  notifier,
+		cache:     cache,
+		logger:    logger.With().Str("component", "bot").Logger(),
 	}
 
 	bot.handler = NewHandler(bot, verboseTools)
 
-	// Register event handlers using the socketmode handler pattern
-	bot.registerEventHandlers()
-
 	return bot, nil
 }
 
-// Run starts the bot and processes events until the context is cancelled.
+// Run dispatches chat events until the transport's Events channel closes or
+// ctx is cancelled. Reconnection, if the transport's backend needs it
+// (Socket Mode, etc.), is the transport's own responsibility; Run just
+// consumes whatever it delivers.
 func (b *Bot) Run(ctx context.Context) error {
-	b.logger.Info().Msg("starting socket mode connection")
-
-	// Use the socketmode handler instead of manually reading from Events channel
-	err := b.socketHandler.RunEventLoopContext(ctx)
-	if err != nil && ctx.Err() == nil {
-		return oops.Trace(err)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case evt, ok := <-b.transport.Events():
+			if !ok {
+				return nil
+			}
+			b.transport.Ack(evt)
+			b.dispatch(ctx, evt)
+		}
 	}
+}
 
-	return nil
+// dispatch routes one ChatEvent to the right Handler method.
+func (b *Bot) dispatch(ctx context.Context, evt chat.ChatEvent) {
+	switch evt.Type {
+	case chat.EventAppMention:
+		b.handler.HandleAppMention(ctx, evt)
+	case chat.EventMessage:
+		b.handler.HandleMessage(ctx, evt)
+	case chat.EventMessageChanged:
+		b.handler.HandleMessageChanged(ctx, evt)
+	case chat.EventMessageDeleted:
+		b.handler.HandleMessageDeleted(ctx, evt)
+	case chat.EventReactionAdded:
+		b.handler.HandleReactionAdded(ctx, evt)
+	case chat.EventInteraction:
+		b.handler.HandleInteraction(ctx, evt)
+	case chat.EventSlashCommand:
+		b.handler.HandleSlashCommand(ctx, evt)
+	case chat.EventConnection:
+		b.handler.HandleConnectionState(evt.State)
+	case chat.EventUserChange, chat.EventTeamJoin, chat.EventChannelRename:
+		b.handler.HandleCacheInvalidation(evt)
+	case chat.EventFileDeleted:
+		b.handler.HandleFileDeleted(ctx, evt)
+	default:
+		b.logger.Debug().Str("type", string(evt.Type)).Msg("unhandled chat event type")
+	}
 }
 
 // Shutdown gracefully shuts down the bot.
@@ -90,255 +129,99 @@ func (b *Bot) Shutdown() {
 	b.logger.Info().Msg("shutting down bot")
 }
 
-// registerEventHandlers sets up all the socketmode handler callbacks.
-func (b *Bot) registerEventHandlers() {
-	// Handle Events API events (app_mention, message, etc.)
-	b.socketHandler.Handle(socketmode.EventTypeEventsAPI, b.handleEventsAPIMiddleware)
-
-	// Handle interactive events (button clicks, etc.)
-	b.socketHandler.Handle(socketmode.EventTypeInteractive, b.handleInteractiveMiddleware)
-
-	// Handle connection events
-	b.socketHandler.Handle(socketmode.EventTypeConnecting, func(evt *socketmode.Event, client *socketmode.Client) {
-		b.logger.Info().Msg("connecting to Slack...")
-	})
-
-	b.socketHandler.Handle(socketmode.EventTypeConnected, func(evt *socketmode.Event, client *socketmode.Client) {
-		b.logger.Info().Msg("connected to Slack")
-	})
-
-	b.socketHandler.Handle(socketmode.EventTypeConnectionError, func(evt *socketmode.Event, client *socketmode.Client) {
-		b.logger.Error().Msg("connection error")
-	})
-
-	b.socketHandler.Handle(socketmode.EventTypeHello, func(evt *socketmode.Event, client *socketmode.Client) {
-		b.logger.Debug().Msg("received hello from Slack")
-	})
+// Tasks returns the currently active TaskRegistry. Safe for concurrent use
+// with ReplaceTasks.
+func (b *Bot) Tasks() *TaskRegistry {
+	b.tasksMu.RLock()
+	defer b.tasksMu.RUnlock()
+	return b.tasks
 }
 
-// handleEventsAPIMiddleware is the socketmode handler for Events API events.
-func (b *Bot) handleEventsAPIMiddleware(evt *socketmode.Event, client *socketmode.Client) {
-	fmt.Printf(">>> EVENTS API: %+v\n", evt.Type)
-
-	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
-	if !ok {
-		b.logger.Warn().
-			Interface("data", evt.Data).
-			Msg("failed to cast EventsAPI event")
-		return
-	}
-
-	client.Ack(*evt.Request)
-	b.handleEventsAPIEvent(context.Background(), eventsAPIEvent)
+// Commands returns the currently active CommandRouter. Safe for concurrent
+// use with ReplaceTasks.
+func (b *Bot) Commands() *CommandRouter {
+	b.tasksMu.RLock()
+	defer b.tasksMu.RUnlock()
+	return b.commands
 }
 
-// handleInteractiveMiddleware is the socketmode handler for interactive events.
-func (b *Bot) handleInteractiveMiddleware(evt *socketmode.Event, client *socketmode.Client) {
-	fmt.Printf(">>> INTERACTIVE EVENT: %+v\n", evt.Type)
-	b.logger.Info().Msg("received interactive event via socketmode handler")
+// ReplaceTasks atomically swaps in a freshly discovered TaskRegistry and its
+// corresponding CommandRouter, so in-flight handlers never observe a
+// registry paired with a mismatched router. Used by CLI.Run to reload tasks
+// on SIGHUP or an AgentsPath change without restarting the bot.
+func (b *Bot) ReplaceTasks(tasks *TaskRegistry, commands *CommandRouter) {
+	b.tasksMu.Lock()
+	b.tasks = tasks
+	b.commands = commands
+	b.tasksMu.Unlock()
+}
 
-	callback, ok := evt.Data.(slack.InteractionCallback)
-	if !ok {
-		b.logger.Warn().
-			Interface("data", evt.Data).
-			Msg("failed to cast interactive callback")
+// trackMessage records that ts was just posted to channelID as part of
+// threadTS's thread (or, if threadTS is empty, that ts itself is starting a
+// new thread), so a later reaction on it can be resolved back to the thread
+// via ThreadForMessage. A no-op if the post itself failed.
+func (b *Bot) trackMessage(channelID, ts, threadTS string, err error) {
+	if err != nil || ts == "" {
 		return
 	}
-
-	client.Ack(*evt.Request)
-	b.handleInteractiveCallback(context.Background(), callback)
-}
-
-// handleEventsAPIEvent processes Events API events.
-func (b *Bot) handleEventsAPIEvent(ctx context.Context, evt slackevents.EventsAPIEvent) {
-	b.logger.Debug().
-		Str("type", evt.Type).
-		Str("inner_type", evt.InnerEvent.Type).
-		Msg("handling Events API event")
-
-	switch evt.Type {
-	case slackevents.CallbackEvent:
-		b.handleCallbackEvent(ctx, evt.InnerEvent)
-	default:
-		b.logger.Debug().
-			Str("type", evt.Type).
-			Msg("unhandled Events API event type")
+	if threadTS == "" {
+		threadTS = ts
 	}
+	b.messageThreads.Store(key(channelID, ts), threadTS)
 }
 
-// handleCallbackEvent processes callback events.
-func (b *Bot) handleCallbackEvent(ctx context.Context, innerEvent slackevents.EventsAPIInnerEvent) {
-	switch ev := innerEvent.Data.(type) {
-	case *slackevents.AppMentionEvent:
-		b.handler.HandleAppMention(ctx, ev)
-	case *slackevents.MessageEvent:
-		b.handler.HandleMessage(ctx, ev)
-	case *slackevents.ReactionAddedEvent:
-		b.handler.HandleReactionAdded(ctx, ev)
-	case *slackevents.ReactionRemovedEvent:
-		b.handler.HandleReactionRemoved(ctx, ev)
-	default:
-		b.logger.Debug().
-			Str("type", innerEvent.Type).
-			Msg("unhandled callback event type")
+// ThreadForMessage resolves a message's ts (e.g. from a reaction_added
+// event's Item) back to the threadTS of the thread the bot posted it into,
+// for handlers that only see a reacted-to message, not the thread it lives
+// in (see Handler.HandleReactionAdded).
+func (b *Bot) ThreadForMessage(channelID, ts string) (string, bool) {
+	v, ok := b.messageThreads.Load(key(channelID, ts))
+	if !ok {
+		return "", false
 	}
+	return v.(string), true
 }
 
 // PostMessage sends a message to a channel.
-func (b *Bot) PostMessage(channelID, text string, threadTS string) (string, error) {
-	opts := []slack.MsgOption{
-		slack.MsgOptionText(text, false),
-	}
-	if threadTS != "" {
-		opts = append(opts, slack.MsgOptionTS(threadTS))
-	}
-
-	_, ts, err := b.client.PostMessage(channelID, opts...)
-	if err != nil {
-		return "", oops.Trace(err)
-	}
-	return ts, nil
+func (b *Bot) PostMessage(channelID, text, threadTS string) (string, error) {
+	ts, err := b.transport.PostMessage(channelID, text, threadTS)
+	b.trackMessage(channelID, ts, threadTS, err)
+	return ts, err
 }
 
 // UpdateMessage updates an existing message.
 func (b *Bot) UpdateMessage(channelID, ts, text string) error {
-	_, _, _, err := b.client.UpdateMessage(
-		channelID,
-		ts,
-		slack.MsgOptionText(text, false),
-	)
-	if err != nil {
-		return oops.Trace(err)
-	}
-	return nil
+	return b.transport.UpdateMessage(channelID, ts, text)
 }
 
-// UpdateMessageBlocks updates an existing message with blocks.
-func (b *Bot) UpdateMessageBlocks(channelID, ts string, blocks []slack.Block) error {
-	_, _, _, err := b.client.UpdateMessage(
-		channelID,
-		ts,
-		slack.MsgOptionBlocks(blocks...),
-	)
-	if err != nil {
-		return oops.Trace(err)
-	}
-	return nil
+// PostMessageBlocks sends a non-interactive formatted message to a channel.
+func (b *Bot) PostMessageBlocks(channelID string, blocks []chat.Block, threadTS string) (string, error) {
+	ts, err := b.transport.PostBlocks(channelID, blocks, threadTS)
+	b.trackMessage(channelID, ts, threadTS, err)
+	return ts, err
 }
 
-// PostMessageBlocks sends a message with blocks to a channel.
-func (b *Bot) PostMessageBlocks(channelID string, blocks []slack.Block, threadTS string) (string, error) {
-	opts := []slack.MsgOption{
-		slack.MsgOptionBlocks(blocks...),
-	}
-	if threadTS != "" {
-		opts = append(opts, slack.MsgOptionTS(threadTS))
-	}
-
-	_, ts, err := b.client.PostMessage(channelID, opts...)
-	if err != nil {
-		return "", oops.Trace(err)
-	}
-	return ts, nil
+// UpdateMessageBlocks updates an existing message's blocks.
+func (b *Bot) UpdateMessageBlocks(channelID, ts string, blocks []chat.Block) error {
+	return b.transport.UpdateBlocks(channelID, ts, blocks)
 }
 
-// handleInteractiveCallback processes interactive component callbacks (button clicks, etc).
-func (b *Bot) handleInteractiveCallback(ctx context.Context, callback slack.InteractionCallback) {
-	b.logger.Info().
-		Str("type", string(callback.Type)).
-		Str("callback_id", callback.CallbackID).
-		Int("num_actions", len(callback.ActionCallback.BlockActions)).
-		Str("channel_id", callback.Channel.ID).
-		Str("user_id", callback.User.ID).
-		Msg("handling interactive callback")
-
-	switch callback.Type {
-	case slack.InteractionTypeBlockActions:
-		if len(callback.ActionCallback.BlockActions) == 0 {
-			b.logger.Warn().Msg("no block actions found in callback")
-			return
-		}
-		for _, action := range callback.ActionCallback.BlockActions {
-			b.logger.Info().
-				Str("action_id", action.ActionID).
-				Str("value", action.Value).
-				Msg("processing block action")
-			b.handler.HandleBlockAction(ctx, &callback, action)
-		}
-	default:
-		b.logger.Debug().
-			Str("type", string(callback.Type)).
-			Msg("unhandled interactive callback type")
-	}
+// SendInteractive posts a formatted message expecting a button response
+// (e.g. a permission prompt).
+func (b *Bot) SendInteractive(channelID string, blocks []chat.Block, threadTS string) (string, error) {
+	ts, err := b.transport.SendInteractive(channelID, blocks, threadTS)
+	b.trackMessage(channelID, ts, threadTS, err)
+	return ts, err
 }
 
-// savePermissionRule saves a permission pattern to the task's claude.json file.
-// This allows the permission to be remembered for future requests.
-func (b *Bot) savePermissionRule(taskPath, pattern string) error {
-	configPath := filepath.Join(taskPath, ".clod", "claude", "claude.json")
-
-	// Read existing config
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return oops.Trace(err)
-	}
-
-	// Parse as generic JSON to preserve all fields
-	var config map[string]any
-	if err := json.Unmarshal(data, &config); err != nil {
-		return oops.Trace(err)
-	}
-
-	// Get or create projects map
-	projects, ok := config["projects"].(map[string]any)
-	if !ok {
-		projects = make(map[string]any)
-		config["projects"] = projects
-	}
-
-	// Get or create project entry for this task path
-	project, ok := projects[taskPath].(map[string]any)
-	if !ok {
-		project = map[string]any{
-			"allowedTools": []any{},
-		}
-		projects[taskPath] = project
-	}
-
-	// Get or create allowedTools array
-	allowedTools, ok := project["allowedTools"].([]any)
-	if !ok {
-		allowedTools = []any{}
-	}
-
-	// Check if pattern already exists
-	for _, t := range allowedTools {
-		if t == pattern {
-			b.logger.Debug().
-				Str("pattern", pattern).
-				Msg("permission pattern already exists, skipping")
-			return nil
-		}
-	}
-
-	// Add the new pattern
-	allowedTools = append(allowedTools, pattern)
-	project["allowedTools"] = allowedTools
-
-	// Write back to file with nice formatting
-	newData, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return oops.Trace(err)
-	}
-
-	if err := os.WriteFile(configPath, newData, 0644); err != nil {
-		return oops.Trace(err)
-	}
-
-	b.logger.Info().
-		Str("pattern", pattern).
-		Str("config_path", configPath).
-		Msg("saved permission rule to claude.json")
+// PostEphemeral sends a message visible only to userID (e.g. slash command
+// help/usage).
+func (b *Bot) PostEphemeral(channelID, userID, text string) error {
+	return b.transport.PostEphemeral(channelID, userID, text)
+}
 
-	return nil
+// Notify fans a task lifecycle event out to every configured notify-url
+// sink (see Notifier); a no-op if none are configured.
+func (b *Bot) Notify(evt NotifyEvent) {
+	b.notifier.Notify(evt)
 }
@@ -0,0 +1,169 @@
+// Package chat abstracts the bot's chat backend behind a ChatTransport
+// interface, so Bot, Handler, PermissionFIFO, and Runner don't need to know
+// whether they're talking to Slack, another chat backend, or a local
+// terminal. See transport/slack and transport/terminal for implementations.
+package chat
+
+// EventType identifies what kind of event a ChatEvent carries.
+type EventType string
+
+const (
+	EventAppMention      EventType = "app_mention"
+	EventMessage         EventType = "message"
+	EventMessageChanged  EventType = "message_changed"
+	EventMessageDeleted  EventType = "message_deleted"
+	EventReactionAdded   EventType = "reaction_added"
+	EventReactionRemoved EventType = "reaction_removed"
+	EventInteraction     EventType = "interaction"
+	EventConnection      EventType = "connection"
+	EventSlashCommand    EventType = "slash_command"
+	EventUserChange      EventType = "user_change"
+	EventTeamJoin        EventType = "team_join"
+	EventChannelRename   EventType = "channel_rename"
+	EventFileDeleted     EventType = "file_deleted"
+)
+
+// ConnectionState reports how a ChatTransport's connection to its backend is
+// doing, mirrored into a ChatEvent so Handler can warn pending threads
+// without knowing which backend it's talking to.
+type ConnectionState string
+
+const (
+	ConnectionStateConnected    ConnectionState = "connected"
+	ConnectionStateReconnecting ConnectionState = "reconnecting"
+	ConnectionStateDisconnected ConnectionState = "disconnected"
+)
+
+// ChatEvent is the transport-agnostic envelope delivered through
+// ChatTransport.Events(): messages, reactions, interactive component
+// callbacks, and connection state changes. Handler switches on Type and
+// only reads the fields that type populates.
+type ChatEvent struct {
+	Type EventType
+
+	// Channel and User carry the renamed channel's ID (EventChannelRename)
+	// or the changed/joined user's ID (EventUserChange, EventTeamJoin), in
+	// addition to their usual per-message meaning.
+	Channel string
+	User    string
+	BotID   string // non-empty if this message was posted by a bot (including us)
+	Text    string
+	// TimeStamp is the message's own ts. For EventMessageChanged it's the
+	// edited message's (unchanged) ts and Text carries the new text; for
+	// EventMessageDeleted it's the ts of the message that was deleted and
+	// Text is empty.
+	TimeStamp       string
+	ThreadTimeStamp string
+
+	// Reaction is the emoji name (e.g. "white_check_mark", without colons),
+	// set when Type == EventReactionAdded or EventReactionRemoved.
+	Reaction string
+
+	// Command is the invoked subcommand name (e.g. "/clod"), set when
+	// Type == EventSlashCommand. Text carries the rest of the command line.
+	Command string
+
+	// FileID is the deleted file's Slack ID, set when Type ==
+	// EventFileDeleted. Slack's file_deleted event carries no channel or
+	// thread, so Handler looks the ID up in its own file mapping store.
+	FileID string
+
+	Interaction *InteractionEvent // set when Type == EventInteraction
+	State       ConnectionState   // set when Type == EventConnection
+
+	// AckToken carries whatever a transport needs to acknowledge this
+	// event in Ack; transports that don't require acking leave it nil.
+	AckToken any
+}
+
+// InteractionEvent is a generic interactive-component callback (a button
+// click), regardless of whether it arrived over Slack, Matrix, Discord, or
+// the terminal transport.
+type InteractionEvent struct {
+	ActionID  string
+	Value     string
+	UserID    string
+	UserName  string
+	Channel   string
+	MessageTS string
+}
+
+// Block is a transport-agnostic building block for formatted messages
+// (permission prompts, stats summaries). Each ChatTransport renders these
+// into whatever its backend needs (Slack Block Kit JSON, ANSI escapes for
+// the terminal transport, etc).
+type Block interface {
+	isBlock()
+}
+
+// Text is inline markdown text, rendered by each transport according to its
+// own markup rules.
+type Text struct {
+	Markdown string
+}
+
+func (Text) isBlock() {}
+
+// Section is a block of body text, the workhorse of permission prompts and
+// stats summaries.
+type Section struct {
+	Text Text
+}
+
+func (Section) isBlock() {}
+
+// Context is a row of small, inline text elements, used for compact stats
+// summaries.
+type Context struct {
+	Elements []Text
+}
+
+func (Context) isBlock() {}
+
+// Button is a single clickable action within an Actions block.
+type Button struct {
+	ActionID string
+	Value    string
+	Label    string
+	Style    string // "primary", "danger", or "" for default
+}
+
+// Actions is a row of buttons (e.g. Allow/Deny for a permission prompt).
+type Actions struct {
+	BlockID string
+	Buttons []Button
+}
+
+func (Actions) isBlock() {}
+
+// ChatTransport abstracts how the bot exchanges messages with a chat
+// backend. The current Slack Socket Mode implementation lives in
+// transport/slack; transport/terminal renders the same calls to a local TTY
+// for development without a Slack workspace.
+type ChatTransport interface {
+	// PostMessage sends a plain text message to a channel, optionally as a
+	// thread reply, and returns the new message's timestamp/ID.
+	PostMessage(channelID, text, threadTS string) (string, error)
+	// UpdateMessage replaces the text of a previously posted message.
+	UpdateMessage(channelID, ts, text string) error
+	// PostBlocks sends a formatted, non-interactive message built from
+	// Blocks (e.g. a stats summary).
+	PostBlocks(channelID string, blocks []Block, threadTS string) (string, error)
+	// UpdateBlocks replaces the Blocks of a previously posted message.
+	UpdateBlocks(channelID, ts string, blocks []Block) error
+	// SendInteractive posts a formatted message that expects a button
+	// response (a permission prompt). It's distinguished from PostBlocks so
+	// a transport can track it for correlation with the InteractionEvent
+	// the response later arrives as.
+	SendInteractive(channelID string, blocks []Block, threadTS string) (string, error)
+	// PostEphemeral sends text visible only to userID in channelID (e.g. a
+	// slash command's usage/help response), with nothing persisted for
+	// other members of the channel.
+	PostEphemeral(channelID, userID, text string) error
+	// Events returns the channel of incoming events: messages, reactions,
+	// interactions, and connection state changes.
+	Events() <-chan ChatEvent
+	// Ack acknowledges receipt of an event that requires it (e.g. a Slack
+	// Socket Mode envelope); a no-op for transports that don't need it.
+	Ack(evt ChatEvent)
+}
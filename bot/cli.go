@@ -4,24 +4,37 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/calebcase/clod/bot/chat"
+	"github.com/calebcase/clod/bot/metrics"
+	transportslack "github.com/calebcase/clod/bot/transport/slack"
+	"github.com/calebcase/clod/bot/transport/terminal"
+	"github.com/calebcase/oops"
+	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
 )
 
+// reloadDebounce coalesces bursts of AgentsPath filesystem events (e.g. an
+// editor's write-temp-then-rename save) into a single task reload.
+const reloadDebounce = 500 * time.Millisecond
+
 type Flags struct {
-	Log struct {
-		Level  zerolog.Level `kong:"default='info',enum='trace,debug,info,warn,error,fatal,panic',env='LOG_LEVEL'"`
-		Format string        `kong:"default='json',enum='json,console',env='LOG_FORMAT'"`
-	} `kong:"embed,prefix='log.'"`
+	Transport string `kong:"default='slack',enum='slack,terminal',env='CHAT_TRANSPORT',help='Chat backend to run against: slack or terminal (local stdin/stdout, no Slack workspace needed)'"`
+
+	SlackBotToken string `kong:"env='SLACK_BOT_TOKEN',help='Slack bot token (xoxb-...); required for -transport=slack'"`
+	SlackAppToken string `kong:"env='SLACK_APP_TOKEN',help='Slack app token for Socket Mode (xapp-...); required for -transport=slack'"`
 
-	SlackBotToken string `kong:"required,env='SLACK_BOT_TOKEN',help='Slack bot token (xoxb-...)'"`
-	SlackAppToken string `kong:"required,env='SLACK_APP_TOKEN',help='Slack app token for Socket Mode (xapp-...)'"`
+	SlackEventsAddr    string `kong:"name='slack-events-addr',env='SLACK_EVENTS_ADDR',help='Address to serve the Slack Events API over HTTP (e.g. :3000), run alongside Socket Mode; disabled if empty'"`
+	SlackSigningSecret string `kong:"name='slack-signing-secret',env='SLACK_SIGNING_SECRET',help='Slack signing secret used to verify requests to -slack-events-addr; required if it is set'"`
 
 	AllowedUsers []string `kong:"env='ALLOWED_USERS',sep=',',help='Comma-separated list of allowed Slack user IDs'"`
 
-	SessionStorePath string `kong:"default='sessions.json',env='SESSION_STORE_PATH',help='Path to session store JSON file'"`
+	SessionStore string `kong:"default='sessions.json',env='SESSION_STORE',help='Session store backend URL: a bare path or file://path for a local JSON file (default), bolt://path/to/db for an embedded indexed store, consul://host:8500/key/prefix, etcd://host:2379/key/prefix, or redis://host:6379/db'"`
 
 	AgentsPath string `kong:"default='.',env='AGENTS_PATH',help='Base path to search for agent directories'"`
 
@@ -29,26 +42,199 @@ type Flags struct {
 
 	PermissionMode string `kong:"default='default',env='PERMISSION_MODE',help='Claude permission mode (default, acceptEdits, bypassPermissions)'"`
 
+	RPC bool `kong:"name='rpc',help='Use the experimental JSON-RPC 2.0 transport instead of PTY/stream-json (canary); equivalent to CLOD_TRANSPORT=jsonrpc2'"`
+
+	AuthConfigPath string `kong:"env='AUTH_CONFIG_PATH',help='Path to a JSON or YAML file with allowed_users/roles, hot-reloaded on change'"`
+
+	PolicyConfigPath   string `kong:"env='POLICY_CONFIG_PATH',help='Path to a JSON or YAML policy ruleset, hot-reloaded on change'"`
+	PolicyAuditLogPath string `kong:"default='policy_audit.log',env='POLICY_AUDIT_LOG_PATH',help='Path to the append-only policy decision audit log'"`
+
+	QuorumConfigPath string `kong:"name='quorum-config',env='QUORUM_CONFIG_PATH',help='Path to a JSON or YAML multi-approver quorum ruleset, hot-reloaded on change; disabled if empty'"`
+
+	FileCacheDir      string        `kong:"name='file-cache-dir',env='FILE_CACHE_DIR',help='Directory for the disk-backed Slack file download cache; defaults to a clod subdirectory of the OS user cache dir'"`
+	FileCacheBytes    int64         `kong:"name='file-cache-bytes',default='1073741824',env='FILE_CACHE_BYTES',help='Total size cap for the disk-backed Slack file download cache'"`
+	FileCacheDedupTTL time.Duration `kong:"name='file-cache-dedup-ttl',default='60s',env='FILE_CACHE_DEDUP_TTL',help='How long a just-fetched file is remembered in memory to collapse a burst of requests into one download'"`
+
+	FileMappingStorePath string `kong:"default='file_mappings.json',name='file-mapping-store',env='FILE_MAPPING_STORE',help='Path to the JSON file tracking Slack file ID <-> local path mappings, used to propagate file_deleted events and removed output files across the bridge'"`
+
 	VerboseTools []string `kong:"default='Read,Glob,Grep,WebFetch,WebSearch',env='VERBOSE_TOOLS',sep=',',help='Tools affected by verbosity toggle'"`
 
 	GracefulShutdownTTL time.Duration `kong:"default='30s',env='GRACEFUL_SHUTDOWN_TTL',help='Time to wait for graceful shutdown'"`
+
+	NotifyURL []string `kong:"name='notify-url',env='NOTIFY_URLS',sep=',',help='Outbound URL(s) to notify of task lifecycle events (repeatable, or comma-separated via NOTIFY_URLS); generic HTTPS JSON POST, or a Slack Incoming Webhook URL'"`
+
+	MetricsAddr string `kong:"name='metrics-addr',env='METRICS_ADDR',help='Address to serve /metrics, /healthz, /readyz on (e.g. :9090); disabled if empty'"`
+
+	OTelEndpoint string `kong:"name='otel-endpoint',env='OTEL_EXPORTER_OTLP_ENDPOINT',help='OTLP/HTTP collector address for task/tool-call traces (e.g. localhost:4318); tracing disabled if empty'"`
+
+	TenantsConfigPath string `kong:"name='tenants',env='TENANTS_CONFIG_PATH',help='Path to a YAML file configuring multiple Slack workspaces (tenants) served by this process; when set, overrides --slack-bot-token, --slack-app-token, --allowed-users, --agents-path, and --session-store'"`
 }
 
+// CLI is kong's root command. Serve is the default: running clod with no
+// subcommand (or with any of Flags's flags) serves the bot, exactly as it
+// always has; export and import are the archive subcommands (see
+// archive.go).
 type CLI struct {
+	Log struct {
+		Level  zerolog.Level `kong:"default='info',enum='trace,debug,info,warn,error,fatal,panic',env='LOG_LEVEL'"`
+		Format string        `kong:"default='json',enum='json,console',env='LOG_FORMAT'"`
+	} `kong:"embed,prefix='log.'"`
+
+	Serve  ServeCmd  `kong:"cmd,default='withargs',help='Run the clod bot (default command)'"`
+	Export ExportCmd `kong:"cmd,help='Export a Slack thread session as a zip archive'"`
+	Import ImportCmd `kong:"cmd,help='Import a zip archive produced by clod export (or compatible tooling)'"`
+}
+
+// ServeCmd runs the bot itself; its flags are exactly what used to be CLI's
+// own flags before export/import were added.
+type ServeCmd struct {
 	Flags
 }
 
-func (cli *CLI) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
+func (cmd *ServeCmd) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
+	GlobalChildReaper().SetLogger(logger)
+
+	// Components shared across every tenant: the policy engine's audit
+	// log and the runner's subprocess pool aren't workspace-specific, and
+	// splitting them per tenant would just multiply subprocess overhead
+	// for no isolation benefit.
+	policy := NewPolicyEngine(cmd.PolicyAuditLogPath, logger)
+	if cmd.PolicyConfigPath != "" {
+		if err := policy.LoadConfig(cmd.PolicyConfigPath); err != nil {
+			return err
+		}
+		go func() {
+			if err := policy.Watch(*ctx, cmd.PolicyConfigPath, logger); err != nil {
+				logger.Error().Err(err).Msg("policy config watcher stopped")
+			}
+		}()
+	}
+
+	quorum := NewQuorumPolicy()
+	if cmd.QuorumConfigPath != "" {
+		if err := quorum.LoadConfig(cmd.QuorumConfigPath); err != nil {
+			return err
+		}
+		go func() {
+			if err := quorum.Watch(*ctx, cmd.QuorumConfigPath, logger); err != nil {
+				logger.Error().Err(err).Msg("quorum config watcher stopped")
+			}
+		}()
+	}
+
+	runner := NewRunner(cmd.ClodTimeout, cmd.PermissionMode, "", logger)
+	if cmd.RPC {
+		runner.TransportKind = transportJSONRPC2
+	}
+
+	notifier := NewNotifier(cmd.NotifyURL, logger)
+
+	fileCacheDir := cmd.FileCacheDir
+	if fileCacheDir == "" {
+		userCacheDir, cacheDirErr := os.UserCacheDir()
+		if cacheDirErr != nil {
+			return oops.Trace(cacheDirErr)
+		}
+		fileCacheDir = filepath.Join(userCacheDir, "clod", "files")
+	}
+	diskCache := NewFileCache(fileCacheDir, cmd.FileCacheBytes, cmd.FileCacheDedupTTL)
+
+	fileMappings, err := NewFileMappingStore(cmd.FileMappingStorePath)
+	if err != nil {
+		return err
+	}
+	logger.Info().
+		Int("file_mapping_count", fileMappings.Count()).
+		Str("path", cmd.FileMappingStorePath).
+		Msg("loaded file mappings from storage")
+
+	if cmd.MetricsAddr != "" {
+		metricsServer := newMetricsServer(cmd.MetricsAddr)
+		go runMetricsServer(*ctx, metricsServer, logger)
+	}
+
+	if cmd.OTelEndpoint != "" {
+		shutdown, err := newTracerProvider(*ctx, cmd.OTelEndpoint)
+		if err != nil {
+			return oops.Trace(err)
+		}
+		defer func() {
+			if shutdownErr := shutdown(context.Background()); shutdownErr != nil {
+				logger.Error().Err(shutdownErr).Msg("failed to flush trace exporter")
+			}
+		}()
+	}
+
+	if cmd.TenantsConfigPath != "" {
+		return cmd.runTenants(*ctx, runner, policy, quorum, notifier, diskCache, fileMappings, logger)
+	}
+
+	return cmd.runSingleTenant(*ctx, runner, policy, quorum, notifier, diskCache, fileMappings, logger)
+}
+
+// runSingleTenant runs the process the way it always has: one Slack
+// workspace (or the terminal transport) configured straight off Flags, with
+// no --tenants file involved.
+func (cmd *ServeCmd) runSingleTenant(ctx context.Context, runner *Runner, policy *PolicyEngine, quorum *QuorumPolicy, notifier *Notifier, diskCache *FileCache, fileMappings *FileMappingStore, logger zerolog.Logger) (err error) {
 	logger.Info().
-		Str("agents_path", cli.AgentsPath).
-		Str("session_store", cli.SessionStorePath).
-		Int("allowed_users", len(cli.AllowedUsers)).
+		Str("agents_path", cmd.AgentsPath).
+		Str("session_store", cmd.SessionStore).
+		Int("allowed_users", len(cmd.AllowedUsers)).
 		Msg("starting clod slack bot")
 
+	// Build the chat transport first: it determines whether file sharing
+	// (Slack-specific) is available, and whether the authorizer can
+	// resolve Slack subteam membership.
+	var transport chat.ChatTransport
+	var files *FileHandler
+	var slackClient *slack.Client
+	var cache *SlackCache
+
+	switch cmd.Transport {
+	case "terminal":
+		transport = terminal.New(os.Stdin, os.Stdout)
+	case "slack":
+		if cmd.SlackBotToken == "" || cmd.SlackAppToken == "" {
+			return oops.New("-transport=slack requires --slack-bot-token and --slack-app-token")
+		}
+		st := transportslack.New(cmd.SlackBotToken, cmd.SlackAppToken, logger)
+		slackClient = st.Client()
+		cache = NewSlackCache(slackClient, logger)
+		files = NewFileHandler(slackClient, cache, diskCache, fileMappings, logger)
+		transport = st
+
+		if cmd.SlackEventsAddr != "" {
+			if cmd.SlackSigningSecret == "" {
+				return oops.New("-slack-events-addr requires --slack-signing-secret")
+			}
+			go func() {
+				if err := st.RunHTTP(ctx, cmd.SlackEventsAddr, cmd.SlackSigningSecret); err != nil {
+					logger.Error().Err(err).Msg("slack events api http server stopped")
+				}
+			}()
+		}
+	default:
+		return oops.New("unknown transport %q", cmd.Transport)
+	}
+
 	// Initialize components
-	auth := NewAuthorizer(cli.AllowedUsers)
+	auth := NewAuthorizer(cmd.AllowedUsers)
+	if slackClient != nil {
+		auth.SetSlackClient(slackClient)
+	}
 
-	tasks, err := NewTaskRegistry(cli.AgentsPath)
+	if cmd.AuthConfigPath != "" {
+		if err := auth.LoadConfig(cmd.AuthConfigPath); err != nil {
+			return err
+		}
+		go func() {
+			if err := auth.Watch(ctx, cmd.AuthConfigPath, logger); err != nil {
+				logger.Error().Err(err).Msg("auth config watcher stopped")
+			}
+		}()
+	}
+
+	tasks, err := NewTaskRegistry(cmd.AgentsPath)
 	if err != nil {
 		return err
 	}
@@ -56,26 +242,31 @@ func (cli *CLI) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
 	taskNames := tasks.List()
 	logger.Info().Strs("tasks", taskNames).Msg("discovered tasks")
 
-	sessions, err := NewSessionStore(cli.SessionStorePath)
+	commands := NewCommandRouter(tasks)
+
+	sessions, err := NewSessionStore(cmd.SessionStore)
 	if err != nil {
 		return err
 	}
 	logger.Info().
 		Int("session_count", sessions.Count()).
-		Str("path", cli.SessionStorePath).
+		Str("path", cmd.SessionStore).
 		Msg("loaded sessions from storage")
 
-	runner := NewRunner(cli.ClodTimeout, cli.PermissionMode, logger)
-
 	// Create and start the bot
 	bot, err := NewBot(
-		cli.SlackBotToken,
-		cli.SlackAppToken,
+		transport,
 		auth,
 		tasks,
+		commands,
 		sessions,
 		runner,
-		cli.VerboseTools,
+		policy,
+		quorum,
+		files,
+		notifier,
+		cache,
+		cmd.VerboseTools,
 		logger,
 	)
 	if err != nil {
@@ -85,9 +276,27 @@ func (cli *CLI) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
 	// Run bot in background
 	errors := make(chan error, 1)
 	go func() {
-		errors <- bot.Run(*ctx)
+		errors <- bot.Run(ctx)
 	}()
 
+	// Transports that need their own connection supervisor (e.g. Slack
+	// Socket Mode reconnection) implement Run themselves; the terminal
+	// transport has no connection to supervise.
+	if runnable, ok := transport.(interface{ Run(context.Context) error }); ok {
+		go func() {
+			if err := runnable.Run(ctx); err != nil {
+				logger.Error().Err(err).Msg("chat transport stopped")
+			}
+		}()
+	}
+
+	// Reload tasks (and the ALLOWED_USERS allowlist) on SIGHUP or when
+	// AgentsPath changes, without dropping the Socket Mode connection or
+	// in-flight tasks.
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go cmd.watchReload(ctx, bot, auth, cmd.AgentsPath, true, reloadSignals, logger)
+
 	// Signal handling (buffer of 2 to catch second signal for force exit)
 	signals := make(chan os.Signal, 2)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
@@ -96,7 +305,7 @@ func (cli *CLI) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
 	case <-signals:
 		start := time.Now()
 		logger.Warn().
-			Float64("ttl", cli.GracefulShutdownTTL.Seconds()).
+			Float64("ttl", cmd.GracefulShutdownTTL.Seconds()).
 			Msg("shutting down gracefully (send again to force)")
 
 		bot.Shutdown()
@@ -108,13 +317,15 @@ func (cli *CLI) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
 				Float64("elapsed", time.Since(start).Seconds()).
 				Msg("received second signal: forcing immediate exit")
 			os.Exit(1)
-		case <-time.After(cli.GracefulShutdownTTL):
+		case <-time.After(cmd.GracefulShutdownTTL):
 			logger.Error().
 				Float64("elapsed", time.Since(start).Seconds()).
 				Msg("graceful shutdown timeout: forcing exit")
 			os.Exit(1)
 		}
 
+		metrics.ShutdownDuration.Observe(time.Since(start).Seconds())
+
 		logger.Info().
 			Float64("elapsed", time.Since(start).Seconds()).
 			Msg("graceful shutdown complete")
@@ -135,3 +346,327 @@ func (cli *CLI) Run(ctx *context.Context, logger zerolog.Logger) (err error) {
 
 	return err
 }
+
+// tenant bundles the per-workspace resources CLI.runTenants needs to start,
+// supervise, and gracefully shut down one Bot.
+type tenant struct {
+	id       string
+	bot      *Bot
+	sessions SessionStore
+	errs     chan error
+}
+
+// runTenants serves every workspace listed in --tenants out of this one
+// process: each gets its own Bot, Slack connection, Authorizer, task
+// registry, and session store, but shares the Runner (subprocess pool),
+// PolicyEngine, QuorumPolicy, and Notifier passed in from Run.
+func (cmd *ServeCmd) runTenants(ctx context.Context, runner *Runner, policy *PolicyEngine, quorum *QuorumPolicy, notifier *Notifier, diskCache *FileCache, fileMappings *FileMappingStore, logger zerolog.Logger) (err error) {
+	config, loadErr := LoadTenantsConfig(cmd.TenantsConfigPath)
+	if loadErr != nil {
+		return loadErr
+	}
+
+	logger.Info().
+		Str("tenants_config", cmd.TenantsConfigPath).
+		Int("tenants", len(config.Tenants)).
+		Msg("starting clod slack bot (multi-tenant)")
+
+	tenants := make([]*tenant, 0, len(config.Tenants))
+	for _, tc := range config.Tenants {
+		t, startErr := cmd.startTenant(ctx, tc, runner, policy, quorum, notifier, diskCache, fileMappings, logger)
+		if startErr != nil {
+			err = oops.Trace(startErr)
+			break
+		}
+		tenants = append(tenants, t)
+	}
+
+	// Signal handling (buffer of 2 to catch second signal for force exit)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	if err == nil {
+		// Each tenant's errs is buffered and written to exactly once (by
+		// the goroutine bot.Run runs in, see startTenant); fan them all
+		// into a single results channel so both branches below drain
+		// each tenant's result exactly once, however shutdown started.
+		type tenantResult struct {
+			id  string
+			err error
+		}
+		results := make(chan tenantResult, len(tenants))
+		for _, t := range tenants {
+			t := t
+			go func() {
+				results <- tenantResult{id: t.id, err: <-t.errs}
+			}()
+		}
+
+		select {
+		case <-signals:
+			start := time.Now()
+			logger.Warn().
+				Float64("ttl", cmd.GracefulShutdownTTL.Seconds()).
+				Msg("shutting down gracefully (send again to force)")
+
+			for _, t := range tenants {
+				t.bot.Shutdown()
+			}
+
+			done := make(chan struct{})
+			go func() {
+				for range tenants {
+					<-results
+				}
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-signals:
+				logger.Warn().
+					Float64("elapsed", time.Since(start).Seconds()).
+					Msg("received second signal: forcing immediate exit")
+				os.Exit(1)
+			case <-time.After(cmd.GracefulShutdownTTL):
+				logger.Error().
+					Float64("elapsed", time.Since(start).Seconds()).
+					Msg("graceful shutdown timeout: forcing exit")
+				os.Exit(1)
+			}
+
+			metrics.ShutdownDuration.Observe(time.Since(start).Seconds())
+
+			logger.Info().
+				Float64("elapsed", time.Since(start).Seconds()).
+				Msg("graceful shutdown complete")
+
+		case r := <-results:
+			// One tenant stopped on its own; treat that like a
+			// single-tenant bot.Run error would and bring the rest down
+			// with it rather than leaving them running headless.
+			if r.err != nil {
+				logger.Error().Err(r.err).Str("tenant", r.id).Msg("tenant bot error")
+			}
+			err = r.err
+
+			for _, t := range tenants {
+				t.bot.Shutdown()
+			}
+			for i := 1; i < len(tenants); i++ {
+				<-results
+			}
+		}
+	}
+
+	for _, t := range tenants {
+		if saveErr := t.sessions.Save(); saveErr != nil {
+			logger.Error().Err(saveErr).Str("tenant", t.id).Msg("failed to save sessions")
+			if err == nil {
+				err = saveErr
+			}
+		}
+	}
+
+	return err
+}
+
+// startTenant builds and starts everything one tenant needs: its own Slack
+// connection, Authorizer, task registry, command router, and session store,
+// wired into a Bot that shares runner/policy/quorum/notifier/diskCache/
+// fileMappings with every other tenant. The returned tenant's errs channel
+// receives bot.Run's result when ctx is cancelled or the Bot stops on its
+// own.
+func (cmd *ServeCmd) startTenant(
+	ctx context.Context,
+	tc TenantConfig,
+	runner *Runner,
+	policy *PolicyEngine,
+	quorum *QuorumPolicy,
+	notifier *Notifier,
+	diskCache *FileCache,
+	fileMappings *FileMappingStore,
+	logger zerolog.Logger,
+) (*tenant, error) {
+	logger = logger.With().Str("tenant", tc.ID).Logger()
+
+	st := transportslack.New(tc.SlackBotToken, tc.SlackAppToken, logger)
+	slackClient := st.Client()
+	cache := NewSlackCache(slackClient, logger)
+	files := NewFileHandler(slackClient, cache, diskCache, fileMappings, logger)
+
+	auth := NewAuthorizer(tc.AllowedUsers)
+	auth.SetSlackClient(slackClient)
+
+	agentsPath := tc.AgentsPath
+	if agentsPath == "" {
+		agentsPath = cmd.AgentsPath
+	}
+	tasks, err := NewTaskRegistry(agentsPath)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().Strs("tasks", tasks.List()).Msg("discovered tasks")
+
+	commands := NewCommandRouter(tasks)
+
+	sessionStoreURL := tc.SessionStore
+	if sessionStoreURL == "" {
+		sessionStoreURL = cmd.SessionStore
+	}
+	sessions, err := NewSessionStore(sessionStoreURL)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().
+		Int("session_count", sessions.Count()).
+		Str("path", sessionStoreURL).
+		Msg("loaded sessions from storage")
+
+	bot, err := NewBot(st, auth, tasks, commands, sessions, runner, policy, quorum, files, notifier, cache, cmd.VerboseTools, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tenant{id: tc.ID, bot: bot, sessions: sessions, errs: make(chan error, 1)}
+
+	go func() {
+		t.errs <- bot.Run(ctx)
+	}()
+
+	go func() {
+		if err := st.Run(ctx); err != nil {
+			logger.Error().Err(err).Msg("chat transport stopped")
+		}
+	}()
+
+	if tc.SlackEventsAddr != "" {
+		go func() {
+			if err := st.RunHTTP(ctx, tc.SlackEventsAddr, tc.SlackSigningSecret); err != nil {
+				logger.Error().Err(err).Msg("slack events api http server stopped")
+			}
+		}()
+	}
+
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go cmd.watchReload(ctx, bot, auth, agentsPath, false, reloadSignals, logger)
+
+	return t, nil
+}
+
+// watchReload re-discovers tasks under agentsPath whenever signals delivers
+// a SIGHUP or agentsPath changes on disk, debouncing filesystem events by
+// reloadDebounce so an editor's save storm triggers a single reload. When
+// reloadEnvAllowedUsers is true, it also re-reads ALLOWED_USERS from the
+// environment on each reload; tenants sourced from a --tenants file manage
+// their allowlist via that file instead, so it's false for those. It runs
+// until ctx is cancelled.
+func (cmd *ServeCmd) watchReload(ctx context.Context, bot *Bot, auth *Authorizer, agentsPath string, reloadEnvAllowedUsers bool, signals <-chan os.Signal, logger zerolog.Logger) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to start agents path watcher; SIGHUP reload still works")
+	} else {
+		defer watcher.Close()
+		if err := watcher.Add(agentsPath); err != nil {
+			logger.Error().Err(err).Str("path", agentsPath).Msg("failed to watch agents path")
+		}
+	}
+
+	var debounce *time.Timer
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
+
+	reload := make(chan struct{}, 1)
+	trigger := func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+		debounce = time.AfterFunc(reloadDebounce, func() {
+			select {
+			case reload <- struct{}{}:
+			default:
+			}
+		})
+	}
+
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if watcher != nil {
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			logger.Info().Msg("received SIGHUP: reloading tasks")
+			cmd.reloadTasks(bot, auth, agentsPath, reloadEnvAllowedUsers, logger)
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			trigger()
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			logger.Error().Err(err).Msg("agents path watcher error")
+		case <-reload:
+			logger.Info().Msg("agents path changed: reloading tasks")
+			cmd.reloadTasks(bot, auth, agentsPath, reloadEnvAllowedUsers, logger)
+		}
+	}
+}
+
+// reloadTasks re-discovers tasks under agentsPath, swapping them into the
+// running bot atomically. If reloadEnvAllowedUsers is true, it also
+// re-reads ALLOWED_USERS from the environment and swaps that into auth. A
+// discovery failure is logged and the previous task registry is left in
+// place.
+func (cmd *ServeCmd) reloadTasks(bot *Bot, auth *Authorizer, agentsPath string, reloadEnvAllowedUsers bool, logger zerolog.Logger) {
+	tasks, err := NewTaskRegistry(agentsPath)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to reload task registry")
+		return
+	}
+
+	logger = logger.With().Strs("tasks", tasks.List()).Logger()
+
+	if reloadEnvAllowedUsers {
+		allowedUsers := parseAllowedUsersEnv()
+		auth.SetAllowedUsers(allowedUsers)
+		logger = logger.With().Int("allowed_users", len(allowedUsers)).Logger()
+	}
+
+	bot.ReplaceTasks(tasks, NewCommandRouter(tasks))
+
+	logger.Info().Msg("reloaded tasks")
+}
+
+// parseAllowedUsersEnv re-reads ALLOWED_USERS the same way kong's
+// sep=',' parsing handles the --allowed-users flag, so a reload picks up
+// operator edits to the environment without a restart.
+func parseAllowedUsersEnv() []string {
+	raw := os.Getenv("ALLOWED_USERS")
+	if raw == "" {
+		return nil
+	}
+
+	parts := strings.Split(raw, ",")
+	users := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			users = append(users, p)
+		}
+	}
+	return users
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/calebcase/oops"
+)
+
+// RoutedCommand is a slash command invocation resolved to a runnable task.
+type RoutedCommand struct {
+	TaskName     string
+	TaskPath     string
+	Instructions string
+	Flags        map[string]string
+}
+
+// CommandRouter resolves a slash command's subcommand to a task in a
+// TaskRegistry, giving Slack users a discoverable alternative to the
+// `@bot task_name: instructions` mention syntax. Unlike ParseMention,
+// invocations carry typed flag arguments (see ParseSlashCommand) rather
+// than a single free-form instruction string.
+type CommandRouter struct {
+	tasks *TaskRegistry
+}
+
+// NewCommandRouter creates a CommandRouter backed by tasks.
+func NewCommandRouter(tasks *TaskRegistry) *CommandRouter {
+	return &CommandRouter{tasks: tasks}
+}
+
+// Route parses a slash command's text (e.g. "deprecation upstream.md
+// --branch=main") and resolves it to a task. The error, when non-nil, is an
+// ephemeral help/usage message suitable for posting straight back to the
+// user: missing subcommand, unknown task, or missing instructions.
+func (r *CommandRouter) Route(text string) (*RoutedCommand, error) {
+	parsed := ParseSlashCommand(text)
+	if parsed == nil {
+		return nil, oops.New("Usage: `/clod <task> <instructions> [--flag=value ...]`\n\n%s", r.tasks.ListFormatted())
+	}
+
+	taskPath, err := r.tasks.Get(parsed.SubCommand)
+	if err != nil {
+		return nil, oops.New("Unknown task: `%s`\n\n%s", parsed.SubCommand, r.tasks.ListFormatted())
+	}
+
+	if parsed.Instructions == "" {
+		return nil, oops.New("Usage: `/clod %s <instructions> [--flag=value ...]`", parsed.SubCommand)
+	}
+
+	return &RoutedCommand{
+		TaskName:     parsed.SubCommand,
+		TaskPath:     taskPath,
+		Instructions: parsed.Instructions,
+		Flags:        parsed.Flags,
+	}, nil
+}
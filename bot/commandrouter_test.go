@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestTaskRegistry creates a TaskRegistry over a temp dir containing one
+// discoverable task named "deprecation".
+func newTestTaskRegistry(t *testing.T) *TaskRegistry {
+	t.Helper()
+
+	dir := t.TempDir()
+	runPath := filepath.Join(dir, "deprecation", ".clod", "system", "run")
+	if err := os.MkdirAll(filepath.Dir(runPath), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(runPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tasks, err := NewTaskRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewTaskRegistry: %v", err)
+	}
+	return tasks
+}
+
+func TestCommandRouterRoutesKnownTask(t *testing.T) {
+	router := NewCommandRouter(newTestTaskRegistry(t))
+
+	routed, err := router.Route("deprecation upstream-deprecation.md --branch=main --verbose")
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	if routed.TaskName != "deprecation" {
+		t.Errorf("TaskName = %q, want %q", routed.TaskName, "deprecation")
+	}
+	if routed.Instructions != "upstream-deprecation.md" {
+		t.Errorf("Instructions = %q, want %q", routed.Instructions, "upstream-deprecation.md")
+	}
+	if routed.Flags["branch"] != "main" {
+		t.Errorf("Flags[branch] = %q, want %q", routed.Flags["branch"], "main")
+	}
+	if routed.Flags["verbose"] != "true" {
+		t.Errorf("Flags[verbose] = %q, want %q", routed.Flags["verbose"], "true")
+	}
+}
+
+func TestCommandRouterRejectsUnknownTask(t *testing.T) {
+	router := NewCommandRouter(newTestTaskRegistry(t))
+
+	if _, err := router.Route("nope do something"); err == nil {
+		t.Fatal("expected error for unknown task")
+	}
+}
+
+func TestCommandRouterRejectsMissingInstructions(t *testing.T) {
+	router := NewCommandRouter(newTestTaskRegistry(t))
+
+	if _, err := router.Route("deprecation --verbose"); err == nil {
+		t.Fatal("expected error for missing instructions")
+	}
+}
+
+func TestCommandRouterRejectsEmptyText(t *testing.T) {
+	router := NewCommandRouter(newTestTaskRegistry(t))
+
+	if _, err := router.Route(""); err == nil {
+		t.Fatal("expected error for empty command text")
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/calebcase/clod/bot/chat"
+)
+
+// maxDiffPreviewLines bounds how many lines of a unified diff are shown
+// inline in a permission prompt; the full diff is always available via the
+// "View Full Diff" button regardless of this cap.
+const maxDiffPreviewLines = 20
+
+// maxDiffableLines bounds the line counts a diff is attempted for at all
+// (old_lines * new_lines is the cost of the LCS below); beyond this, a
+// preview would be too slow to compute for a permission prompt's latency
+// budget, so we fall back to a byte-count summary.
+const maxDiffableLines = 4000
+
+// diffPreviewText renders a truncated unified diff for a Write or Edit
+// permission request as markdown. ok is false for any other tool, or if the
+// diff couldn't be computed (e.g. old_string/content missing from
+// toolInput).
+func diffPreviewText(toolName string, toolInput map[string]any, taskPath string) (text string, truncated, ok bool) {
+	oldContent, newContent, ok := loadDiffContents(toolName, toolInput, taskPath)
+	if !ok {
+		return "", false, false
+	}
+
+	oldLines := strings.Split(oldContent, "\n")
+	newLines := strings.Split(newContent, "\n")
+	if len(oldLines)*len(newLines) > maxDiffableLines*maxDiffableLines {
+		return fmt.Sprintf("*Diff:* file too large to preview (%d -> %d lines)", len(oldLines), len(newLines)), false, true
+	}
+
+	diff := unifiedDiff(oldLines, newLines)
+	if diff == "" {
+		return "*Diff:* no changes", false, true
+	}
+
+	preview, truncated := truncateDiff(diff, maxDiffPreviewLines)
+	return fmt.Sprintf("*Diff:*\n```%s```", preview), truncated, true
+}
+
+// diffPreviewBlocks renders diffPreviewText as blocks, plus, if it was
+// truncated, a "View Full Diff" button that uploads the complete patch
+// (see handleViewFullDiff). Used by buildPermissionBlocks, where
+// progressKey can still be resolved back to the pending request on click;
+// updatePermissionMessage uses diffPreviewText directly instead, since by
+// the time it runs the prompt (and its progressKey) is already resolved.
+func (h *Handler) diffPreviewBlocks(toolName string, toolInput map[string]any, taskPath, progressKey string) (blocks []chat.Block, ok bool) {
+	text, truncated, ok := diffPreviewText(toolName, toolInput, taskPath)
+	if !ok {
+		return nil, false
+	}
+	blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: text}})
+
+	if truncated {
+		viewDiffValue, _ := json.Marshal(PermissionActionValue{ThreadKey: progressKey, Behavior: "view_diff"})
+		blocks = append(blocks, chat.Actions{
+			BlockID: "permission_actions_diff",
+			Buttons: []chat.Button{
+				{ActionID: "permission_view_diff", Value: string(viewDiffValue), Label: "View Full Diff"},
+			},
+		})
+	}
+
+	return blocks, true
+}
+
+// loadDiffContents resolves the old (on-disk) and new (post-edit) contents
+// for a Write or Edit tool call, so diffPreviewBlocks can diff them. ok is
+// false for any other tool, or if the inputs don't have the expected
+// shape/encoding.
+func loadDiffContents(toolName string, toolInput map[string]any, taskPath string) (oldContent, newContent string, ok bool) {
+	path, _ := toolInput["file_path"].(string)
+	if path == "" {
+		return "", "", false
+	}
+	resolved := resolveUnderTaskPath(taskPath, path)
+
+	existing, err := os.ReadFile(resolved)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", false
+	}
+	oldContent = string(existing)
+
+	switch toolName {
+	case "Write":
+		content, ok := toolInput["content"].(string)
+		if !ok {
+			return "", "", false
+		}
+		return oldContent, content, true
+	case "Edit":
+		oldString, _ := toolInput["old_string"].(string)
+		newString, ok := toolInput["new_string"].(string)
+		if !ok || oldString == "" {
+			return "", "", false
+		}
+		return oldContent, strings.Replace(oldContent, oldString, newString, 1), true
+	default:
+		return "", "", false
+	}
+}
+
+// unifiedDiff renders a minimal unified-diff-style rendering of the
+// line-by-line differences between oldLines and newLines, using a
+// line-based LCS (see lcsLines) to find the matching lines. It's not meant
+// to byte-match `diff -u` output, just to give an approver enough context
+// to judge a Write/Edit call.
+func unifiedDiff(oldLines, newLines []string) string {
+	matches := lcsLines(oldLines, newLines)
+
+	var b strings.Builder
+	oi, ni, mi := 0, 0, 0
+	changed := false
+	for oi < len(oldLines) || ni < len(newLines) {
+		if mi < len(matches) && oi == matches[mi][0] && ni == matches[mi][1] {
+			b.WriteString(" " + oldLines[oi] + "\n")
+			oi++
+			ni++
+			mi++
+			continue
+		}
+
+		nextOi, nextNi := len(oldLines), len(newLines)
+		if mi < len(matches) {
+			nextOi, nextNi = matches[mi][0], matches[mi][1]
+		}
+		for oi < nextOi {
+			b.WriteString("-" + oldLines[oi] + "\n")
+			oi++
+			changed = true
+		}
+		for ni < nextNi {
+			b.WriteString("+" + newLines[ni] + "\n")
+			ni++
+			changed = true
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// lcsLines returns the longest common subsequence of a and b as a sequence
+// of (a-index, b-index) pairs, via the standard O(len(a)*len(b)) dynamic
+// program. Callers bound len(a)*len(b) themselves (see maxDiffableLines).
+func lcsLines(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	var matches [][2]int
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			matches = append(matches, [2]int{i, j})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// truncateDiff keeps at most maxLines lines of a unified diff, reporting
+// whether anything was cut.
+func truncateDiff(diff string, maxLines int) (preview string, truncated bool) {
+	lines := strings.Split(diff, "\n")
+	if len(lines) <= maxLines {
+		return diff, false
+	}
+	return strings.Join(lines[:maxLines], "\n") + "\n... (truncated)", true
+}
+
+// bashHazardPattern pairs a regexp matched against a Bash command with the
+// warning shown when it hits, for bashHazards' static analysis.
+type bashHazardPattern struct {
+	re      *regexp.Regexp
+	warning string
+}
+
+var bashHazardPatterns = []bashHazardPattern{
+	{regexp.MustCompile(`\brm\s+(-\w*r\w*f\w*|-\w*f\w*r\w*)\b`), "destructive delete (`rm -rf` or similar)"},
+	{regexp.MustCompile(`\bsudo\b`), "runs as another user via `sudo`"},
+	{regexp.MustCompile(`curl[^|]*\|\s*(sudo\s+)?(ba)?sh\b|wget[^|]*\|\s*(sudo\s+)?(ba)?sh\b`), "pipes a remote download straight into a shell"},
+	{regexp.MustCompile(`\b(curl|wget|nc|ncat|ssh|scp|rsync)\b`), "network egress"},
+	{regexp.MustCompile(`>\s*/etc/|>\s*/usr/|>\s*/bin/|>\s*/sbin/`), "writes outside the task directory"},
+}
+
+// bashHazards runs a lightweight static analysis over a Bash command,
+// flagging a fixed set of risky patterns (destructive deletes, piping a
+// download into a shell, sudo, network egress, writes outside the task
+// directory) so an approver has more to go on than the bare command text.
+// It's a heuristic, not a sandbox: it can both miss real hazards (e.g.
+// obfuscated commands) and flag safe ones.
+func bashHazards(command string) []string {
+	var hazards []string
+	for _, p := range bashHazardPatterns {
+		if p.re.MatchString(command) {
+			hazards = append(hazards, p.warning)
+		}
+	}
+	return hazards
+}
+
+// bashHazardBlock renders bashHazards' findings as a warning context block,
+// or returns ok=false if toolName isn't "Bash" or no hazards were found.
+func bashHazardBlock(toolName string, toolInput map[string]any) (block chat.Block, ok bool) {
+	if toolName != "Bash" {
+		return nil, false
+	}
+	command, _ := toolInput["command"].(string)
+	if command == "" {
+		return nil, false
+	}
+
+	hazards := bashHazards(command)
+	if len(hazards) == 0 {
+		return nil, false
+	}
+
+	lines := make([]string, len(hazards))
+	for i, h := range hazards {
+		lines[i] = "- " + h
+	}
+	text := ":rotating_light: *Flagged by static analysis:*\n" + strings.Join(lines, "\n")
+	return chat.Section{Text: chat.Text{Markdown: text}}, true
+}
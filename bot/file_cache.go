@@ -0,0 +1,279 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/calebcase/oops"
+)
+
+// defaultFileCacheBytes is the default total size cap for the disk-backed
+// Slack file download cache (see FileCache).
+const defaultFileCacheBytes = 1 * 1024 * 1024 * 1024 // 1 GiB
+
+// defaultFileCacheDedupTTL is how long a just-fetched file is remembered in
+// memory, so a burst of events referencing the same file (e.g. an
+// app_mention immediately followed by its thread_reply) collapses into one
+// download instead of one per event.
+const defaultFileCacheDedupTTL = 60 * time.Second
+
+// fileCacheEntry is one blob's metadata, persisted in the cache directory's
+// sidecar index.json.
+type fileCacheEntry struct {
+	ID         string    `json:"id"`
+	ETag       string    `json:"etag"` // sha256 of URLPrivateDownload, standing in for a real Slack ETag
+	Size       int64     `json:"size"`
+	MimeType   string    `json:"mimetype"`
+	ModTime    time.Time `json:"mtime"`
+	AccessTime time.Time `json:"atime"`
+}
+
+// dedupEntry is a short-lived record that file ID was fetched (or is being
+// fetched) recently, so callers within FileCache.dedupTTL reuse its blob
+// path instead of going back to Slack.
+type dedupEntry struct {
+	path      string
+	etag      string
+	expiresAt time.Time
+}
+
+// FileCache is a two-tier cache for Slack file downloads, fronting
+// FileHandler.DownloadToTask/DownloadToMemory: a short-lived in-memory dedup
+// layer (dedupEntry) in front of a size-bounded LRU of already-downloaded
+// blobs on disk, persisted under dir/blobs/<file-id> with a sidecar
+// index.json recording size, mimetype, mtime, and last-access time (the
+// same on-disk-index-plus-LRU shape as snippetCache, but keyed on Slack
+// file ID + an ETag surrogate instead of a content hash, since the content
+// itself is never hashed here). On an eviction, the oldest entries are
+// deleted until the cache is back under maxBytes.
+type FileCache struct {
+	dir      string
+	maxBytes int64
+	dedupTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*fileCacheEntry
+	order   []string // file IDs, least to most recently used
+	total   int64
+
+	dedup sync.Map // file ID -> *dedupEntry
+}
+
+// NewFileCache opens (or creates) a disk-backed file cache rooted at dir,
+// loading its index.json if one already exists from a previous run.
+func NewFileCache(dir string, maxBytes int64, dedupTTL time.Duration) *FileCache {
+	c := &FileCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		dedupTTL: dedupTTL,
+		entries:  make(map[string]*fileCacheEntry),
+	}
+	c.loadIndex()
+	return c
+}
+
+// indexPath is the sidecar file recording every entry's metadata.
+func (c *FileCache) indexPath() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+// blobPath is where a file ID's downloaded content lives on disk.
+func (c *FileCache) blobPath(id string) string {
+	return filepath.Join(c.dir, "blobs", id)
+}
+
+// loadIndex populates entries/order/total from index.json, oldest
+// AccessTime first. Entries whose blob is missing (e.g. the index survived
+// but the blob directory was cleared) are dropped.
+func (c *FileCache) loadIndex() {
+	data, err := os.ReadFile(c.indexPath())
+	if err != nil {
+		return
+	}
+
+	var entries []*fileCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].AccessTime.Before(entries[j].AccessTime) })
+
+	for _, e := range entries {
+		if _, err := os.Stat(c.blobPath(e.ID)); err != nil {
+			continue
+		}
+		c.entries[e.ID] = e
+		c.order = append(c.order, e.ID)
+		c.total += e.Size
+	}
+}
+
+// saveIndex persists entries to index.json. c.mu must be held.
+func (c *FileCache) saveIndex() error {
+	entries := make([]*fileCacheEntry, 0, len(c.entries))
+	for _, id := range c.order {
+		entries = append(entries, c.entries[id])
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return oops.Trace(err)
+	}
+	return oops.Trace(os.WriteFile(c.indexPath(), data, 0o644))
+}
+
+// ETag hashes a file's URLPrivateDownload as a stand-in for a real Slack
+// ETag: Slack doesn't expose one, but the signed download URL changes if
+// the underlying file is replaced, so it's a reasonable surrogate for
+// "is this still the same content".
+func ETag(urlPrivateDownload string) string {
+	sum := sha256.Sum256([]byte(urlPrivateDownload))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached blob's path for (id, etag), bumping its LRU
+// position and refreshing the in-memory dedup entry, or ok=false on a miss
+// (not cached at all, or cached under a different etag, i.e. the file
+// changed since it was last fetched).
+func (c *FileCache) Get(id, etag string) (path string, ok bool) {
+	if d, found := c.dedup.Load(id); found {
+		dd := d.(*dedupEntry)
+		if dd.etag == etag && time.Now().Before(dd.expiresAt) {
+			return dd.path, true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, found := c.entries[id]
+	if !found || e.ETag != etag {
+		return "", false
+	}
+	path = c.blobPath(id)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+
+	e.AccessTime = time.Now()
+	c.touch(id)
+	c.dedup.Store(id, &dedupEntry{path: path, etag: etag, expiresAt: time.Now().Add(c.dedupTTL)})
+
+	return path, true
+}
+
+// Put stores content read from src under (id, etag), evicting the
+// least-recently-used entries if the cache is now over maxBytes, and
+// returns the blob's on-disk path. Also seeds the in-memory dedup entry, so
+// a burst of requests for the same file within dedupTTL don't even reach
+// Get's disk stat.
+func (c *FileCache) Put(id, etag, mimetype string, src io.Reader) (path string, err error) {
+	path = c.blobPath(id)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", oops.Trace(err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return "", oops.Trace(err)
+	}
+	n, copyErr := io.Copy(out, src)
+	if closeErr := out.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		os.Remove(path)
+		return "", oops.Trace(copyErr)
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if _, exists := c.entries[id]; exists {
+		c.total -= c.entries[id].Size
+		c.removeFromOrder(id)
+	}
+	c.entries[id] = &fileCacheEntry{
+		ID:         id,
+		ETag:       etag,
+		Size:       n,
+		MimeType:   mimetype,
+		ModTime:    now,
+		AccessTime: now,
+	}
+	c.order = append(c.order, id)
+	c.total += n
+	c.evict()
+	saveErr := c.saveIndex()
+	c.mu.Unlock()
+
+	c.dedup.Store(id, &dedupEntry{path: path, etag: etag, expiresAt: now.Add(c.dedupTTL)})
+
+	return path, oops.Trace(saveErr)
+}
+
+// touch moves id to the most-recently-used end of the eviction order. c.mu
+// must be held.
+func (c *FileCache) touch(id string) {
+	c.removeFromOrder(id)
+	c.order = append(c.order, id)
+}
+
+// removeFromOrder deletes id from the eviction order, if present. c.mu must
+// be held.
+func (c *FileCache) removeFromOrder(id string) {
+	for i, existing := range c.order {
+		if existing == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// evict removes the least-recently-used entries until the cache is back
+// under maxBytes. c.mu must be held.
+func (c *FileCache) evict() {
+	for c.total > c.maxBytes && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.total -= c.entries[oldest].Size
+		_ = os.Remove(c.blobPath(oldest))
+		delete(c.entries, oldest)
+		c.dedup.Delete(oldest)
+	}
+}
+
+// linkOrCopy hardlinks src to dst, falling back to a byte copy if src and
+// dst are on different filesystems (os.Link returns syscall.EXDEV) or
+// hardlinks aren't supported, so a cached blob can be placed into a task
+// directory without doubling disk usage in the common case.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	_, copyErr := io.Copy(out, in)
+	if closeErr := out.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	return oops.Trace(copyErr)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/calebcase/oops"
+)
+
+// FileRef records one file tracked across the Slack<->task-directory
+// bridge: either a file downloaded from Slack into a task's directory (see
+// FileHandler.DownloadToTask) or a task output uploaded to Slack (see
+// FileHandler.UploadFromTaskOutputs). FileMappingStore keeps these around
+// so a later file_deleted event (Handler.HandleFileDeleted) or a
+// disappeared local file (FileHandler.WatchOutputs) can find its
+// counterpart and remove it too.
+type FileRef struct {
+	SlackFileID string    `json:"slack_file_id"`
+	LocalPath   string    `json:"local_path"`
+	TaskPath    string    `json:"task_path,omitempty"` // Task directory LocalPath must stay under; see FileHandler.HandleDeletedFile
+	ChannelID   string    `json:"channel_id"`
+	ThreadTS    string    `json:"thread_ts"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FileMappingStore persists FileRefs to a single JSON file, mirroring
+// fileSessionStore's in-memory-map-plus-atomic-rewrite shape (see
+// SessionStore) rather than SessionStore itself, since file mappings are
+// looked up by either SlackFileID or LocalPath and don't belong to any one
+// channel/thread the way a SessionMapping does.
+type FileMappingStore struct {
+	path string
+
+	mu          sync.RWMutex
+	bySlackID   map[string]*FileRef
+	byLocalPath map[string]*FileRef
+}
+
+// NewFileMappingStore opens (or creates) a FileMappingStore at path,
+// loading any mappings saved by a previous run.
+func NewFileMappingStore(path string) (*FileMappingStore, error) {
+	s := &FileMappingStore{
+		path:        path,
+		bySlackID:   make(map[string]*FileRef),
+		byLocalPath: make(map[string]*FileRef),
+	}
+
+	if err := s.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Count returns the number of tracked file mappings.
+func (s *FileMappingStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.bySlackID)
+}
+
+// LookupBySlackID returns the tracked FileRef for a Slack file ID, or
+// ok=false if none is tracked.
+func (s *FileMappingStore) LookupBySlackID(slackFileID string) (ref FileRef, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found, ok := s.bySlackID[slackFileID]
+	if !ok {
+		return FileRef{}, false
+	}
+	return *found, true
+}
+
+// LookupByLocalPath returns the tracked FileRef for a local path, or
+// ok=false if none is tracked.
+func (s *FileMappingStore) LookupByLocalPath(localPath string) (ref FileRef, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found, ok := s.byLocalPath[localPath]
+	if !ok {
+		return FileRef{}, false
+	}
+	return *found, true
+}
+
+// Track records ref (stamping its CreatedAt) and persists the store,
+// replacing any existing mapping for the same SlackFileID or LocalPath.
+func (s *FileMappingStore) Track(ref FileRef) error {
+	ref.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	s.bySlackID[ref.SlackFileID] = &ref
+	s.byLocalPath[ref.LocalPath] = &ref
+	s.mu.Unlock()
+
+	return s.save()
+}
+
+// UntrackBySlackID removes the mapping for slackFileID, if any, and
+// persists the store. ok is false if nothing was tracked under that ID.
+func (s *FileMappingStore) UntrackBySlackID(slackFileID string) (ref FileRef, ok bool, err error) {
+	s.mu.Lock()
+	found, exists := s.bySlackID[slackFileID]
+	if exists {
+		delete(s.bySlackID, slackFileID)
+		delete(s.byLocalPath, found.LocalPath)
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return FileRef{}, false, nil
+	}
+	return *found, true, s.save()
+}
+
+// UntrackByLocalPath removes the mapping for localPath, if any, and
+// persists the store. ok is false if nothing was tracked under that path.
+func (s *FileMappingStore) UntrackByLocalPath(localPath string) (ref FileRef, ok bool, err error) {
+	s.mu.Lock()
+	found, exists := s.byLocalPath[localPath]
+	if exists {
+		delete(s.byLocalPath, localPath)
+		delete(s.bySlackID, found.SlackFileID)
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return FileRef{}, false, nil
+	}
+	return *found, true, s.save()
+}
+
+// ListByThread returns every FileRef tracked for a channel/thread, in no
+// particular order. Used by ExportSession to bundle a thread's files into
+// its archive.
+func (s *FileMappingStore) ListByThread(channelID, threadTS string) []FileRef {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	refs := make([]FileRef, 0)
+	for _, ref := range s.bySlackID {
+		if ref.ChannelID == channelID && ref.ThreadTS == threadTS {
+			refs = append(refs, *ref)
+		}
+	}
+	return refs
+}
+
+// load reads mappings from the JSON file. Returns an os.IsNotExist error if
+// the file doesn't exist yet (fresh start).
+func (s *FileMappingStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var refs []*FileRef
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ref := range refs {
+		s.bySlackID[ref.SlackFileID] = ref
+		s.byLocalPath[ref.LocalPath] = ref
+	}
+
+	return nil
+}
+
+// save writes mappings to the JSON file atomically (temp file + rename),
+// the same way fileSessionStore.Save does.
+func (s *FileMappingStore) save() error {
+	s.mu.RLock()
+	refs := make([]*FileRef, 0, len(s.bySlackID))
+	for _, ref := range s.bySlackID {
+		refs = append(refs, ref)
+	}
+	s.mu.RUnlock()
+
+	data, err := json.MarshalIndent(refs, "", "  ")
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	dir := filepath.Dir(s.path)
+	tmpFile, err := os.CreateTemp(dir, "file-mappings-*.json.tmp")
+	if err != nil {
+		return oops.Trace(err)
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpPath)
+		return oops.Trace(err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return oops.Trace(err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return oops.Trace(err)
+	}
+
+	return nil
+}
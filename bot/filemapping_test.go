@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMappingStoreTrackLookupUntrack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file_mappings.json")
+	store, err := NewFileMappingStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := FileRef{SlackFileID: "F1", LocalPath: "/tasks/t1/image.png", ChannelID: "C1", ThreadTS: "T1"}
+	if err := store.Track(ref); err != nil {
+		t.Fatal(err)
+	}
+	if store.Count() != 1 {
+		t.Fatalf("Count = %d, want 1", store.Count())
+	}
+
+	if got, ok := store.LookupBySlackID("F1"); !ok || got.LocalPath != ref.LocalPath {
+		t.Fatalf("LookupBySlackID = %+v, %v, want %+v, true", got, ok, ref)
+	}
+	if got, ok := store.LookupByLocalPath(ref.LocalPath); !ok || got.SlackFileID != "F1" {
+		t.Fatalf("LookupByLocalPath = %+v, %v, want %+v, true", got, ok, ref)
+	}
+
+	untracked, ok, err := store.UntrackBySlackID("F1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || untracked.LocalPath != ref.LocalPath {
+		t.Fatalf("UntrackBySlackID = %+v, %v, want %+v, true", untracked, ok, ref)
+	}
+
+	if _, ok := store.LookupBySlackID("F1"); ok {
+		t.Fatal("LookupBySlackID after Untrack should miss")
+	}
+	if _, ok := store.LookupByLocalPath(ref.LocalPath); ok {
+		t.Fatal("LookupByLocalPath after Untrack should miss")
+	}
+}
+
+func TestFileMappingStoreSurvivesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file_mappings.json")
+	store, err := NewFileMappingStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := FileRef{SlackFileID: "F2", LocalPath: "/tasks/t1/out.txt", ChannelID: "C2", ThreadTS: "T2"}
+	if err := store.Track(ref); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileMappingStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reloaded.LookupBySlackID("F2"); !ok || got.LocalPath != ref.LocalPath {
+		t.Fatalf("LookupBySlackID after reload = %+v, %v, want %+v, true", got, ok, ref)
+	}
+}
+
+func TestFileMappingStoreUntrackByLocalPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file_mappings.json")
+	store, err := NewFileMappingStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := FileRef{SlackFileID: "F3", LocalPath: "/tasks/t1/out.txt", ChannelID: "C3", ThreadTS: "T3"}
+	if err := store.Track(ref); err != nil {
+		t.Fatal(err)
+	}
+
+	untracked, ok, err := store.UntrackByLocalPath(ref.LocalPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || untracked.SlackFileID != "F3" {
+		t.Fatalf("UntrackByLocalPath = %+v, %v, want %+v, true", untracked, ok, ref)
+	}
+	if _, ok, _ := store.UntrackByLocalPath(ref.LocalPath); ok {
+		t.Fatal("UntrackByLocalPath twice should miss the second time")
+	}
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"time"
 
 	"github.com/calebcase/oops"
 	"github.com/rs/zerolog"
@@ -14,15 +14,27 @@ import (
 
 // FileHandler manages file transfers between Slack and task directories.
 type FileHandler struct {
-	client *slack.Client
-	logger zerolog.Logger
+	client    *slack.Client
+	cache     *SlackCache       // nil disables memoizing GetMessageFiles/GetThreadReplyFiles
+	diskCache *FileCache        // nil disables the disk-backed download cache (see DownloadToTask)
+	mapping   *FileMappingStore // nil disables delete propagation (see HandleDeletedFile, WatchOutputs)
+	logger    zerolog.Logger
 }
 
-// NewFileHandler creates a new FileHandler.
-func NewFileHandler(client *slack.Client, logger zerolog.Logger) *FileHandler {
+// NewFileHandler creates a new FileHandler. cache may be nil, in which case
+// GetMessageFiles and GetThreadReplyFiles hit conversations.history /
+// conversations.replies on every call. diskCache may be nil, in which case
+// every DownloadToTask/DownloadToMemory call re-fetches the file from
+// Slack, the way it always has. mapping may be nil, in which case deleting
+// a file on either side of the bridge no longer deletes it on the other
+// (see HandleDeletedFile, WatchOutputs).
+func NewFileHandler(client *slack.Client, cache *SlackCache, diskCache *FileCache, mapping *FileMappingStore, logger zerolog.Logger) *FileHandler {
 	return &FileHandler{
-		client: client,
-		logger: logger.With().Str("component", "files").Logger(),
+		client:    client,
+		cache:     cache,
+		diskCache: diskCache,
+		mapping:   mapping,
+		logger:    logger.With().Str("component", "files").Logger(),
 	}
 }
 
@@ -34,14 +46,10 @@ type DownloadedFile struct {
 	LocalPath string // Only set if saved to disk
 }
 
-// uploadedFile tracks upload state for output file watching.
-type uploadedFile struct {
-	modTime        time.Time // Last modification time when uploaded
-	lastUploadTime time.Time // When the file was last uploaded (for rate limiting)
-}
-
 // DownloadToMemory downloads a Slack file to memory using the slack-go client.
-// Returns the file data and metadata without writing to disk.
+// Returns the file data and metadata without writing to disk. If a disk
+// cache is configured and already has this file under a matching ETag (see
+// FileCache), it's read from there instead of re-fetched from Slack.
 func (f *FileHandler) DownloadToMemory(file slack.File) (*DownloadedFile, error) {
 	f.logger.Info().
 		Str("file_id", file.ID).
@@ -59,6 +67,17 @@ func (f *FileHandler) DownloadToMemory(file slack.File) (*DownloadedFile, error)
 		return nil, oops.New("no download URL available for file %s", file.ID)
 	}
 
+	if f.diskCache != nil {
+		if path, ok := f.diskCache.Get(file.ID, ETag(url)); ok {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				f.logger.Debug().Str("file_id", file.ID).Msg("served file from disk cache")
+				return &DownloadedFile{Name: file.Name, MimeType: file.Mimetype, Data: data}, nil
+			}
+			f.logger.Warn().Err(err).Str("file_id", file.ID).Msg("disk cache hit but blob unreadable, re-fetching")
+		}
+	}
+
 	f.logger.Debug().
 		Str("url", url).
 		Msg("fetching file via client.GetFile")
@@ -71,6 +90,12 @@ func (f *FileHandler) DownloadToMemory(file slack.File) (*DownloadedFile, error)
 
 	data := buf.Bytes()
 
+	if f.diskCache != nil {
+		if _, err := f.diskCache.Put(file.ID, ETag(url), file.Mimetype, bytes.NewReader(data)); err != nil {
+			f.logger.Warn().Err(err).Str("file_id", file.ID).Msg("failed to populate disk cache")
+		}
+	}
+
 	f.logger.Info().
 		Int("bytes_read", len(data)).
 		Str("mimetype", file.Mimetype).
@@ -86,37 +111,44 @@ func (f *FileHandler) DownloadToMemory(file slack.File) (*DownloadedFile, error)
 // DownloadToTask downloads a Slack file to the task directory.
 // Returns the local file path where the file was saved.
 // If a file with the same name already exists, an auto-incrementing number is added
-// (e.g., image.png, image-1.png, image-2.png).
-func (f *FileHandler) DownloadToTask(file slack.File, taskPath string) (localPath string, err error) {
-	// Determine the filename (use Slack's filename, sanitize if needed).
-	filename := file.Name
-	if filename == "" {
+// (e.g., image.png, image-1.png, image-2.png). If a disk cache is
+// configured and already has this file under a matching ETag, the cached
+// blob is hardlinked (or copied, cross-device) into the task directory
+// instead of being re-fetched from Slack (see FileCache). channelID and
+// threadTS are recorded in the file mapping store (if configured) so a
+// later file_deleted event for file.ID can find localPath again (see
+// HandleDeletedFile).
+func (f *FileHandler) DownloadToTask(file slack.File, taskPath, channelID, threadTS string) (localPath string, err error) {
+	// Determine the filename. file.Name is attacker-controlled (whatever the
+	// uploading Slack user named it), so strip any directory components
+	// before using it, and re-verify below that it still resolves under
+	// taskPath.
+	filename := filepath.Base(file.Name)
+	if filename == "" || filename == "." {
 		filename = file.ID
 	}
-	localPath = filepath.Join(taskPath, filename)
+	localPath, err = safeJoin(taskPath, filename)
+	if err != nil {
+		return "", err
+	}
 
 	// If file already exists, add auto-incrementing number before extension.
-	if _, err := os.Stat(localPath); err == nil {
+	if _, statErr := os.Stat(localPath); statErr == nil {
 		ext := filepath.Ext(filename)
 		base := filename[:len(filename)-len(ext)]
 		for i := 1; ; i++ {
 			newFilename := fmt.Sprintf("%s-%d%s", base, i, ext)
-			localPath = filepath.Join(taskPath, newFilename)
-			if _, err := os.Stat(localPath); os.IsNotExist(err) {
+			localPath, err = safeJoin(taskPath, newFilename)
+			if err != nil {
+				return "", err
+			}
+			if _, statErr := os.Stat(localPath); os.IsNotExist(statErr) {
 				filename = newFilename
 				break
 			}
 		}
 	}
 
-	f.logger.Info().
-		Str("file_id", file.ID).
-		Str("filename", filename).
-		Str("local_path", localPath).
-		Int("size", file.Size).
-		Str("mimetype", file.Mimetype).
-		Msg("downloading file from Slack to disk")
-
 	// Use URLPrivateDownload which is the download-specific URL.
 	url := file.URLPrivateDownload
 	if url == "" {
@@ -126,6 +158,29 @@ func (f *FileHandler) DownloadToTask(file slack.File, taskPath string) (localPat
 		return "", oops.New("no download URL available for file %s", file.ID)
 	}
 
+	if f.diskCache != nil {
+		if blobPath, ok := f.diskCache.Get(file.ID, ETag(url)); ok {
+			if linkErr := linkOrCopy(blobPath, localPath); linkErr == nil {
+				f.logger.Info().
+					Str("file_id", file.ID).
+					Str("local_path", localPath).
+					Msg("linked file from disk cache")
+				f.trackFile(file.ID, localPath, taskPath, channelID, threadTS)
+				return localPath, nil
+			} else {
+				f.logger.Warn().Err(linkErr).Str("file_id", file.ID).Msg("disk cache hit but link/copy failed, re-fetching")
+			}
+		}
+	}
+
+	f.logger.Info().
+		Str("file_id", file.ID).
+		Str("filename", filename).
+		Str("local_path", localPath).
+		Int("size", file.Size).
+		Str("mimetype", file.Mimetype).
+		Msg("downloading file from Slack to disk")
+
 	f.logger.Debug().
 		Str("url", url).
 		Msg("fetching file via client.GetFile")
@@ -151,17 +206,81 @@ func (f *FileHandler) DownloadToTask(file slack.File, taskPath string) (localPat
 		return "", oops.Trace(err)
 	}
 
+	if f.diskCache != nil {
+		if _, cacheErr := out.Seek(0, io.SeekStart); cacheErr == nil {
+			if _, cacheErr := f.diskCache.Put(file.ID, ETag(url), file.Mimetype, out); cacheErr != nil {
+				f.logger.Warn().Err(cacheErr).Str("file_id", file.ID).Msg("failed to populate disk cache")
+			}
+		}
+	}
+
 	f.logger.Info().
 		Str("local_path", localPath).
 		Int64("bytes_written", info.Size()).
 		Msg("file downloaded successfully")
 
+	f.trackFile(file.ID, localPath, taskPath, channelID, threadTS)
+
 	return
 }
 
-// UploadFromTaskOutputs uploads a file from the task's outputs directory to Slack.
+// trackFile records a downloaded or uploaded file in the mapping store, if
+// one is configured. taskPath is the task directory localPath must stay
+// under; HandleDeletedFile re-checks that containment before removing the
+// local file, as defense in depth against a tampered or stale mapping.
+// Failures are logged, not returned: losing delete propagation for one file
+// isn't worth failing the download/upload over.
+func (f *FileHandler) trackFile(slackFileID, localPath, taskPath, channelID, threadTS string) {
+	if f.mapping == nil {
+		return
+	}
+	if err := f.mapping.Track(FileRef{
+		SlackFileID: slackFileID,
+		LocalPath:   localPath,
+		TaskPath:    taskPath,
+		ChannelID:   channelID,
+		ThreadTS:    threadTS,
+	}); err != nil {
+		f.logger.Warn().Err(err).Str("file_id", slackFileID).Msg("failed to persist file mapping")
+	}
+}
+
+// HandleDeletedFile removes the local copy of a Slack file that was just
+// deleted (a file_deleted event), if one was ever downloaded into a task
+// directory via DownloadToTask and is still tracked in the file mapping
+// store. ok is false if nothing is tracked for slackFileID (mapping is
+// disabled, the file was never downloaded, or it's an uploaded output --
+// see WatchOutputs for that direction). As defense in depth against a
+// tampered or corrupted mapping file, ref.LocalPath is re-verified to still
+// be contained in ref.TaskPath before it's removed.
+func (f *FileHandler) HandleDeletedFile(slackFileID string) (ref FileRef, ok bool, err error) {
+	if f.mapping == nil {
+		return FileRef{}, false, nil
+	}
+
+	ref, ok, err = f.mapping.UntrackBySlackID(slackFileID)
+	if !ok || err != nil {
+		return ref, ok, err
+	}
+
+	if ref.TaskPath != "" && !isContainedIn(ref.TaskPath, ref.LocalPath) {
+		return ref, true, oops.New("refusing to remove %q: not contained in task directory %q", ref.LocalPath, ref.TaskPath)
+	}
+
+	if removeErr := os.Remove(ref.LocalPath); removeErr != nil && !os.IsNotExist(removeErr) {
+		return ref, true, oops.Trace(removeErr)
+	}
+
+	return ref, true, nil
+}
+
+// UploadFromTaskOutputs uploads a file from the task's outputs directory to
+// Slack. taskPath is recorded alongside the tracked mapping so a later
+// file_deleted event can verify containment before removing it (see
+// HandleDeletedFile).
 func (f *FileHandler) UploadFromTaskOutputs(
 	localPath string,
+	taskPath string,
 	channelID string,
 	threadTS string,
 	comment string,
@@ -199,22 +318,28 @@ func (f *FileHandler) UploadFromTaskOutputs(
 		Str("title", summary.Title).
 		Msg("file uploaded successfully")
 
+	f.trackFile(summary.ID, localPath, taskPath, channelID, threadTS)
+
 	return summary, nil
 }
 
 // UploadSnippet uploads text content as a collapsible snippet to Slack.
 // This is useful for tool output that would be too long for inline display.
-// The comment parameter is shown as a message alongside the file.
+// The comment parameter is shown as a message alongside the file. language
+// is a Slack snippet_type hint (e.g. "go", "diff", "json"; see
+// snippetLanguage) for syntax highlighting, or "" for none.
 func (f *FileHandler) UploadSnippet(
 	content string,
 	title string,
 	comment string,
+	language string,
 	channelID string,
 	threadTS string,
 ) (*slack.FileSummary, error) {
 	f.logger.Debug().
 		Int("content_len", len(content)).
 		Str("title", title).
+		Str("language", language).
 		Str("channel", channelID).
 		Msg("uploading snippet to Slack")
 
@@ -226,6 +351,7 @@ func (f *FileHandler) UploadSnippet(
 		InitialComment:  comment,
 		Channel:         channelID,
 		ThreadTimestamp: threadTS,
+		SnippetType:     language,
 	}
 
 	summary, err := f.client.UploadFileV2(params)
@@ -240,190 +366,156 @@ func (f *FileHandler) UploadSnippet(
 	return summary, nil
 }
 
-// GetMessageFiles fetches the full message to get file information.
-// This is needed because app_mention events don't include the files array.
-func (f *FileHandler) GetMessageFiles(channelID, messageTS string) ([]slack.File, error) {
-	// Use conversations.history with a very small window around the message.
-	params := &slack.GetConversationHistoryParameters{
-		ChannelID: channelID,
-		Latest:    messageTS,
-		Oldest:    messageTS,
-		Inclusive: true,
-		Limit:     1,
+// UploadRawFile uploads a file from disk unmodified (e.g. a cached tool
+// snippet, for a "Download raw" action), streaming directly from path
+// instead of reading it into memory first the way UploadSnippet's Content
+// field requires.
+func (f *FileHandler) UploadRawFile(path, filename, comment, channelID, threadTS string) (*slack.FileSummary, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, oops.Trace(err)
 	}
 
-	history, err := f.client.GetConversationHistory(params)
+	f.logger.Debug().
+		Str("path", path).
+		Str("filename", filename).
+		Str("channel", channelID).
+		Msg("uploading raw file to Slack")
+
+	params := slack.UploadFileV2Parameters{
+		File:            path,
+		FileSize:        int(info.Size()),
+		Filename:        filename,
+		Title:           filename,
+		InitialComment:  comment,
+		Channel:         channelID,
+		ThreadTimestamp: threadTS,
+	}
+
+	summary, err := f.client.UploadFileV2(params)
 	if err != nil {
 		return nil, oops.Trace(err)
 	}
 
-	if len(history.Messages) == 0 {
-		return nil, nil
+	f.logger.Debug().
+		Str("file_id", summary.ID).
+		Msg("raw file uploaded successfully")
+
+	return summary, nil
+}
+
+// GetMessageFiles fetches the full message to get file information.
+// This is needed because app_mention events don't include the files array.
+// If f.cache is set, the result is memoized by (channelID, messageTS) so a
+// retried delivery of the same event doesn't repeat the conversations.
+// history call.
+func (f *FileHandler) GetMessageFiles(channelID, messageTS string) ([]slack.File, error) {
+	fetch := func() ([]slack.File, error) {
+		// Use conversations.history with a very small window around the message.
+		params := &slack.GetConversationHistoryParameters{
+			ChannelID: channelID,
+			Latest:    messageTS,
+			Oldest:    messageTS,
+			Inclusive: true,
+			Limit:     1,
+		}
+
+		history, err := f.client.GetConversationHistory(params)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+
+		if len(history.Messages) == 0 {
+			return nil, nil
+		}
+
+		return history.Messages[0].Files, nil
+	}
+
+	files, err := f.fetchMessageFiles(channelID, messageTS, fetch)
+	if err != nil {
+		return nil, err
 	}
 
-	msg := history.Messages[0]
-	if len(msg.Files) > 0 {
+	if len(files) > 0 {
 		f.logger.Debug().
-			Int("num_files", len(msg.Files)).
+			Int("num_files", len(files)).
 			Str("message_ts", messageTS).
 			Msg("found files in message")
 	}
 
-	return msg.Files, nil
+	return files, nil
 }
 
-// GetThreadReplyFiles fetches files from a thread reply.
+// GetThreadReplyFiles fetches files from a thread reply. If f.cache is set,
+// the result is memoized by (channelID, messageTS), same as
+// GetMessageFiles.
 func (f *FileHandler) GetThreadReplyFiles(channelID, threadTS, messageTS string) ([]slack.File, error) {
-	// Use conversations.replies to get the specific message in the thread.
-	params := &slack.GetConversationRepliesParameters{
-		ChannelID: channelID,
-		Timestamp: threadTS,
-		Latest:    messageTS,
-		Oldest:    messageTS,
-		Inclusive: true,
-		Limit:     1,
-	}
+	fetch := func() ([]slack.File, error) {
+		// Use conversations.replies to get the specific message in the thread.
+		params := &slack.GetConversationRepliesParameters{
+			ChannelID: channelID,
+			Timestamp: threadTS,
+			Latest:    messageTS,
+			Oldest:    messageTS,
+			Inclusive: true,
+			Limit:     1,
+		}
 
-	msgs, _, _, err := f.client.GetConversationReplies(params)
-	if err != nil {
-		return nil, oops.Trace(err)
-	}
+		msgs, _, _, err := f.client.GetConversationReplies(params)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
 
-	// Find the specific message by timestamp.
-	for _, msg := range msgs {
-		if msg.Timestamp == messageTS {
-			if len(msg.Files) > 0 {
-				f.logger.Debug().
-					Int("num_files", len(msg.Files)).
-					Str("message_ts", messageTS).
-					Msg("found files in thread reply")
+		// Find the specific message by timestamp.
+		for _, msg := range msgs {
+			if msg.Timestamp == messageTS {
+				return msg.Files, nil
 			}
-			return msg.Files, nil
 		}
+
+		return nil, nil
 	}
 
-	return nil, nil
-}
+	files, err := f.fetchMessageFiles(channelID, messageTS, fetch)
+	if err != nil {
+		return nil, err
+	}
 
-// WatchOutputs monitors the task directory for new files and uploads them.
-// This is intended to run in a goroutine during task execution.
-func (f *FileHandler) WatchOutputs(
-	taskPath string,
-	channelID string,
-	threadTS string,
-	done <-chan struct{},
-) {
-	// Track files we've already uploaded with their modification times.
-	uploaded := make(map[string]*uploadedFile)
-
-	// Get initial file list to avoid uploading pre-existing files.
-	entries, _ := os.ReadDir(taskPath)
-	for _, e := range entries {
-		if info, err := e.Info(); err == nil {
-			uploaded[e.Name()] = &uploadedFile{
-				modTime:        info.ModTime(),
-				lastUploadTime: time.Now(),
-			}
-		}
+	if len(files) > 0 {
+		f.logger.Debug().
+			Int("num_files", len(files)).
+			Str("message_ts", messageTS).
+			Msg("found files in thread reply")
 	}
 
-	f.logger.Debug().
-		Str("task_path", taskPath).
-		Int("existing_files", len(uploaded)).
-		Msg("starting output file watcher")
-
-	// Poll for new files until done.
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-done:
-			f.logger.Debug().Msg("output file watcher stopping")
-			// Do one final check for new files.
-			f.uploadNewFiles(taskPath, channelID, threadTS, uploaded)
-			return
-		case <-ticker.C:
-			f.uploadNewFiles(taskPath, channelID, threadTS, uploaded)
-		}
+	return files, nil
+}
+
+// fetchMessageFiles routes through f.cache when one is configured, falling
+// back to calling fetch directly otherwise.
+func (f *FileHandler) fetchMessageFiles(channelID, messageTS string, fetch func() ([]slack.File, error)) ([]slack.File, error) {
+	if f.cache == nil {
+		return fetch()
 	}
+	return f.cache.GetOrFetchMessageFiles(channelID, messageTS, fetch)
 }
 
-// uploadNewFiles checks for and uploads any new or modified files in the task directory.
-func (f *FileHandler) uploadNewFiles(
+// WatchOutputs monitors the task directory for new files and uploads them.
+// This is intended to run in a goroutine during task execution. See
+// newOutputWatcher for the fsnotify-driven implementation.
+func (f *FileHandler) WatchOutputs(
 	taskPath string,
 	channelID string,
 	threadTS string,
-	uploaded map[string]*uploadedFile,
+	done <-chan struct{},
 ) {
-	entries, err := os.ReadDir(taskPath)
+	ow, err := newOutputWatcher(f, taskPath, channelID, threadTS)
 	if err != nil {
-		// Directory might not exist yet, that's ok.
+		f.logger.Error().Err(err).Str("task_path", taskPath).Msg("failed to start output file watcher")
+		<-done
 		return
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		name := entry.Name()
-		localPath := filepath.Join(taskPath, name)
-
-		// Get file info to check modification time.
-		info1, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		// Check if file should be uploaded (new or modified).
-		tracked, exists := uploaded[name]
-		shouldUpload := false
-
-		if !exists {
-			// New file - upload it.
-			shouldUpload = true
-		} else if info1.ModTime().After(tracked.modTime) {
-			// File has been modified since last upload.
-			// Apply cooldown period to prevent rapid re-uploads.
-			cooldownPeriod := 10 * time.Second
-			if time.Since(tracked.lastUploadTime) >= cooldownPeriod {
-				shouldUpload = true
-				f.logger.Debug().
-					Str("file", name).
-					Time("old_modtime", tracked.modTime).
-					Time("new_modtime", info1.ModTime()).
-					Msg("file modified, re-uploading")
-			}
-		}
-
-		if !shouldUpload {
-			continue
-		}
-
-		// Wait a moment and check again.
-		time.Sleep(500 * time.Millisecond)
-		info2, err := os.Stat(localPath)
-		if err != nil {
-			continue
-		}
-
-		if info1.Size() != info2.Size() {
-			// File is still being written, skip for now.
-			continue
-		}
-
-		// Upload the file.
-		_, err = f.UploadFromTaskOutputs(localPath, channelID, threadTS, fmt.Sprintf(":outbox_tray: Output: `%s`", name))
-		if err != nil {
-			f.logger.Error().Err(err).Str("file", name).Msg("failed to upload output file")
-			continue
-		}
-
-		// Track the upload with current modification time and timestamp.
-		uploaded[name] = &uploadedFile{
-			modTime:        info2.ModTime(),
-			lastUploadTime: time.Now(),
-		}
-	}
+	ow.run(done)
 }
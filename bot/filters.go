@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// RedactRule maps a regex to a replacement token name (e.g. {Pattern:
+// `AKIA[0-9A-Z]{16}`, Replacement: "aws_key"} redacts matches to tokens like
+// "[REDACTED:aws_key_1]").
+type RedactRule struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
+// InputFiltersConfig is the input_filters: section of a task's
+// .clod/task.yaml: include/exclude regexes gate whether a prompt (or a
+// downloaded file's contents) is let through at all, and redact rules
+// substitute matches with a stable per-thread token before anything reaches
+// Claude (see FilterPipeline).
+type InputFiltersConfig struct {
+	Include []string     `yaml:"include,omitempty"`
+	Exclude []string     `yaml:"exclude,omitempty"`
+	Redact  []RedactRule `yaml:"redact,omitempty"`
+}
+
+// taskConfigFile is the on-disk shape of a task's .clod/task.yaml. Only
+// input_filters: is recognized today.
+type taskConfigFile struct {
+	InputFilters InputFiltersConfig `yaml:"input_filters,omitempty"`
+}
+
+// builtinRedactRules are always applied, in addition to whatever a task's
+// task.yaml configures, so secrets of these common shapes never reach Claude
+// even for tasks with no input_filters section at all.
+var builtinRedactRules = []RedactRule{
+	{Pattern: `AKIA[0-9A-Z]{16}`, Replacement: "aws_key"},
+	{Pattern: `eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`, Replacement: "jwt"},
+	{Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, Replacement: "email"},
+}
+
+// compiledRedactRule is a RedactRule with its pattern pre-compiled.
+type compiledRedactRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// FilterPipeline applies a task's input_filters (plus the built-in
+// redactors) to prompts and downloaded file contents before they reach
+// runClod. It's built once per task (see Handler.filterPipeline) and reused
+// across calls so redaction tokens stay stable for the lifetime of the bot
+// process.
+type FilterPipeline struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	redact  []compiledRedactRule
+
+	mu     sync.Mutex
+	tokens map[string]map[string]string // threadKey -> (matched text -> token)
+	counts map[string]map[string]int    // threadKey -> (redactor name -> next index)
+}
+
+// loadInputFilters reads <taskPath>/.clod/task.yaml and returns its
+// input_filters section. A missing file is not an error - task.yaml is
+// entirely optional - but a malformed one is logged and treated as empty.
+func loadInputFilters(taskPath string, logger zerolog.Logger) InputFiltersConfig {
+	path := filepath.Join(taskPath, ".clod", "task.yaml")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error().Err(err).Str("path", path).Msg("failed to read task.yaml")
+		}
+		return InputFiltersConfig{}
+	}
+
+	var cfg taskConfigFile
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		logger.Error().Err(err).Str("path", path).Msg("failed to parse task.yaml")
+		return InputFiltersConfig{}
+	}
+
+	return cfg.InputFilters
+}
+
+// NewFilterPipeline builds the FilterPipeline for taskPath, combining its
+// task.yaml input_filters (if any) with the built-in redactors. Regexes that
+// fail to compile are logged and skipped rather than failing the whole
+// pipeline, since a typo in one rule shouldn't block every task run.
+func NewFilterPipeline(taskPath string, logger zerolog.Logger) *FilterPipeline {
+	cfg := loadInputFilters(taskPath, logger)
+
+	p := &FilterPipeline{
+		tokens: make(map[string]map[string]string),
+		counts: make(map[string]map[string]int),
+	}
+
+	for _, pattern := range cfg.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Error().Err(err).Str("pattern", pattern).Msg("invalid input_filters.include pattern, skipping")
+			continue
+		}
+		p.include = append(p.include, re)
+	}
+
+	for _, pattern := range cfg.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Error().Err(err).Str("pattern", pattern).Msg("invalid input_filters.exclude pattern, skipping")
+			continue
+		}
+		p.exclude = append(p.exclude, re)
+	}
+
+	rules := append(append([]RedactRule{}, builtinRedactRules...), cfg.Redact...)
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			logger.Error().Err(err).Str("pattern", rule.Pattern).Msg("invalid input_filters.redact pattern, skipping")
+			continue
+		}
+		name := rule.Replacement
+		if name == "" {
+			name = "match"
+		}
+		p.redact = append(p.redact, compiledRedactRule{name: name, re: re})
+	}
+
+	return p
+}
+
+// Apply runs text (a prompt or a downloaded file's contents) through the
+// pipeline for the given thread key: an exclude match rejects it outright,
+// an include list (if any rules are configured) requires at least one
+// match, and redact rules replace matches with a stable per-thread token so
+// the same secret maps to the same token across the thread. blocked is true
+// when the caller should drop the input instead of forwarding result.
+func (p *FilterPipeline) Apply(threadKey, text string) (result string, blocked bool) {
+	for _, re := range p.exclude {
+		if re.MatchString(text) {
+			return "", true
+		}
+	}
+
+	if len(p.include) > 0 {
+		matched := false
+		for _, re := range p.include {
+			if re.MatchString(text) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "", true
+		}
+	}
+
+	result = text
+	for _, rule := range p.redact {
+		result = rule.re.ReplaceAllStringFunc(result, func(match string) string {
+			return p.tokenFor(threadKey, rule.name, match)
+		})
+	}
+
+	return result, false
+}
+
+// tokenFor returns the stable redaction token for match within threadKey,
+// minting a new one (e.g. "[REDACTED:aws_key_1]") the first time this
+// particular secret is seen in this thread.
+func (p *FilterPipeline) tokenFor(threadKey, name, match string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seen := p.tokens[threadKey]
+	if seen == nil {
+		seen = make(map[string]string)
+		p.tokens[threadKey] = seen
+	}
+	if token, ok := seen[match]; ok {
+		return token
+	}
+
+	counts := p.counts[threadKey]
+	if counts == nil {
+		counts = make(map[string]int)
+		p.counts[threadKey] = counts
+	}
+	counts[name]++
+	token := fmt.Sprintf("[REDACTED:%s_%d]", name, counts[name])
+	seen[match] = token
+
+	return token
+}
@@ -7,13 +7,16 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/calebcase/clod/bot/chat"
+	"github.com/calebcase/clod/bot/metrics"
+	"github.com/calebcase/clod/bot/mrkdwn"
+	"github.com/calebcase/oops"
 	"github.com/rs/zerolog"
-	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/slackevents"
 )
 
 // PendingPermission tracks a permission request waiting for user response.
@@ -23,33 +26,169 @@ type PendingPermission struct {
 	ThreadTS  string
 	ToolName  string         // Tool that requested permission
 	ToolInput map[string]any // Tool input parameters (for display)
+	TaskPath  string         // Task directory, for resolving the Write/Edit diff preview
+	SessionID string         // Claude session ID, for session-scoped policy rules
+	ToolUseID string         // Claude tool_use ID, for de-duping across reconnects
+
+	// Quorum tracks an in-flight multi-approver vote when a QuorumRule
+	// matched this request (see Handler.resolveQuorumVote); nil otherwise.
+	Quorum *QuorumVote
 }
 
-// Handler processes Slack events.
+// Handler processes chat events, independent of which ChatTransport they
+// came from.
 type Handler struct {
 	bot    *Bot
 	logger zerolog.Logger
 
+	// verboseTools lists the tools whose output is always posted as a
+	// snippet, regardless of a thread's verbosity setting (see
+	// SessionStore.IsVerbose).
+	verboseTools []string
+
 	// Track running tasks by thread key ("channelID:threadTS")
 	runningTasks sync.Map // key -> *RunningTask
 
 	// Track threads waiting for permission responses
 	pendingPermissions sync.Map // key -> *PendingPermission
+
+	// Track in-flight permission requests by ToolUseID, so a transport
+	// reconnect mid-prompt doesn't cause the same request to be posted
+	// twice (see the permRequests case in runClod).
+	seenToolUseIDs sync.Map // ToolUseID -> struct{}
+
+	// Per-task PermissionRuleStore, lazily created (see ruleStore).
+	ruleStores sync.Map // taskPath -> *PermissionRuleStore
+
+	// Per-task PermissionStore, lazily created (see permissionStore).
+	permissionStores sync.Map // taskPath -> *PermissionStore
+
+	// Per-task FilterPipeline, lazily created (see filterPipeline).
+	filterPipelines sync.Map // taskPath -> *FilterPipeline
+}
+
+// ruleStore returns the PermissionRuleStore for taskPath's claude.json,
+// creating it on first use. The same instance is reused across calls so
+// its coalesced-write cache (see PermissionRuleStore) actually coalesces.
+func (h *Handler) ruleStore(taskPath string) *PermissionRuleStore {
+	if store, ok := h.ruleStores.Load(taskPath); ok {
+		return store.(*PermissionRuleStore)
+	}
+
+	configPath := filepath.Join(taskPath, ".clod", "claude", "claude.json")
+	store, _ := h.ruleStores.LoadOrStore(taskPath, NewPermissionRuleStore(configPath, taskPath, h.logger))
+	return store.(*PermissionRuleStore)
+}
+
+// permissionStore returns the PermissionStore for taskPath's
+// .clod-runtime/permissions.json, creating it on first use. The same
+// instance is reused across calls so its in-memory cache stays consistent.
+func (h *Handler) permissionStore(taskPath string) *PermissionStore {
+	if store, ok := h.permissionStores.Load(taskPath); ok {
+		return store.(*PermissionStore)
+	}
+
+	path := filepath.Join(taskPath, ".clod-runtime", "permissions.json")
+	store, _ := h.permissionStores.LoadOrStore(taskPath, NewPermissionStore(path, taskPath, h.logger))
+	return store.(*PermissionStore)
+}
+
+// filterPipeline returns the FilterPipeline for taskPath's task.yaml,
+// creating it on first use. The same instance is reused across calls so its
+// redaction tokens stay stable across a thread's lifetime (see
+// FilterPipeline.tokenFor).
+// storePendingPermission records a permission request awaiting a decision
+// and updates the PendingPermissionQueueDepth gauge to match.
+func (h *Handler) storePendingPermission(key string, pending *PendingPermission) {
+	h.pendingPermissions.Store(key, pending)
+	metrics.PendingPermissionQueueDepth.Inc()
+}
+
+// deletePendingPermission removes a permission request (resolved or stale)
+// and updates the PendingPermissionQueueDepth gauge to match. A no-op, gauge
+// included, if key wasn't pending.
+func (h *Handler) deletePendingPermission(key string) {
+	if _, ok := h.pendingPermissions.LoadAndDelete(key); ok {
+		metrics.PendingPermissionQueueDepth.Dec()
+	}
+}
+
+func (h *Handler) filterPipeline(taskPath string) *FilterPipeline {
+	if pipeline, ok := h.filterPipelines.Load(taskPath); ok {
+		return pipeline.(*FilterPipeline)
+	}
+
+	pipeline, _ := h.filterPipelines.LoadOrStore(taskPath, NewFilterPipeline(taskPath, h.logger))
+	return pipeline.(*FilterPipeline)
+}
+
+// isAuthorized checks userID against h.bot.auth, consulting h.bot.cache's
+// negative cache first so a user who keeps retrying after being denied
+// doesn't force fresh work on every single event; a denial is remembered
+// there afterward. Behaves exactly like auth.IsAuthorized(userID, "") when
+// no SlackCache is configured (e.g. the terminal transport).
+func (h *Handler) isAuthorized(userID string) bool {
+	cache := h.bot.cache
+	if cache != nil && cache.IsDeniedRecently(userID) {
+		return false
+	}
+
+	if h.bot.auth.IsAuthorized(userID, "") {
+		return true
+	}
+
+	if cache != nil {
+		cache.RememberDenied(userID)
+	}
+	return false
+}
+
+// HandleCacheInvalidation drops h.bot.cache's stale entry for a user_change,
+// team_join, or channel_rename event. It's a no-op when no SlackCache is
+// configured.
+func (h *Handler) HandleCacheInvalidation(ev chat.ChatEvent) {
+	cache := h.bot.cache
+	if cache == nil {
+		return
+	}
+
+	switch ev.Type {
+	case chat.EventUserChange, chat.EventTeamJoin:
+		cache.InvalidateUser(ev.User)
+	case chat.EventChannelRename:
+		cache.InvalidateChannel(ev.Channel)
+	}
 }
 
 // NewHandler creates a new Handler.
-func NewHandler(bot *Bot) *Handler {
+func NewHandler(bot *Bot, verboseTools []string) *Handler {
 	return &Handler{
-		bot:    bot,
-		logger: bot.logger.With().Str("component", "handler").Logger(),
+		bot:          bot,
+		logger:       bot.logger.With().Str("component", "handler").Logger(),
+		verboseTools: verboseTools,
 	}
 }
 
+// HandleConnectionState reacts to a ChatTransport's connection state
+// changes, posting a warning to every thread with a pending permission
+// prompt when the transport starts reconnecting, so a user waiting on an
+// Allow/Deny button knows why the bot's gone quiet.
+func (h *Handler) HandleConnectionState(state chat.ConnectionState) {
+	if state != chat.ConnectionStateReconnecting {
+		return
+	}
+	h.pendingPermissions.Range(func(_, v any) bool {
+		pending := v.(*PendingPermission)
+		h.bot.PostMessage(pending.ChannelID, ":warning: Lost connection, reconnecting...", pending.ThreadTS)
+		return true
+	})
+}
+
 // mentionPattern matches @mentions at the start of a message
 var otherMentionPattern = regexp.MustCompile(`^<@([A-Z0-9]+)>`)
 
 // HandleAppMention processes app mention events.
-func (h *Handler) HandleAppMention(ctx context.Context, ev *slackevents.AppMentionEvent) {
+func (h *Handler) HandleAppMention(ctx context.Context, ev chat.ChatEvent) {
 	// Use thread_ts if in a thread, otherwise use the message ts as thread root
 	threadTS := ev.ThreadTimeStamp
 	if threadTS == "" {
@@ -66,9 +205,17 @@ func (h *Handler) HandleAppMention(ctx context.Context, ev *slackevents.AppMenti
 	logger.Info().Msg("received app mention")
 
 	// Check authorization
-	if !h.bot.auth.IsAuthorized(ev.User) {
+	if !h.isAuthorized(ev.User) {
 		logger.Warn().Msg("unauthorized user")
-		h.bot.PostMessage(ev.Channel, h.bot.auth.RejectMessage(), threadTS)
+		h.bot.PostMessage(ev.Channel, h.bot.auth.RejectMessage(""), threadTS)
+		return
+	}
+
+	// "@bot permissions ..." meta-commands manage the task's PermissionStore
+	// directly and are never forwarded to Claude as task input, running
+	// task or not.
+	if cmd := ParsePermissionsCommand(ParseContinuation(ev.Text)); cmd != nil {
+		h.handlePermissionsCommand(ev, threadTS, cmd, logger)
 		return
 	}
 
@@ -82,6 +229,8 @@ func (h *Handler) HandleAppMention(ctx context.Context, ev *slackevents.AppMenti
 			logger.Debug().Str("input", input).Msg("sending input to running task")
 			if err := task.SendInput(input); err != nil {
 				logger.Error().Err(err).Msg("failed to send input to task")
+			} else {
+				task.RecordInputTS(ev.TimeStamp)
 			}
 		}
 		return
@@ -99,8 +248,77 @@ func (h *Handler) HandleAppMention(ctx context.Context, ev *slackevents.AppMenti
 	}
 }
 
+// HandleSlashCommand processes a slash command invocation (e.g. "/clod
+// deprecation upstream-deprecation.md --branch=main"), regardless of which
+// ChatTransport it came from. Unlike HandleAppMention, a slash command has
+// no message of its own to anchor a thread to, so on success this posts a
+// new status message and uses it as the thread root.
+func (h *Handler) HandleSlashCommand(ctx context.Context, ev chat.ChatEvent) {
+	logger := h.logger.With().
+		Str("channel", ev.Channel).
+		Str("user", ev.User).
+		Str("command", ev.Command).
+		Str("text", ev.Text).
+		Logger()
+
+	logger.Info().Msg("received slash command")
+
+	if !h.isAuthorized(ev.User) {
+		logger.Warn().Msg("unauthorized user")
+		h.bot.PostEphemeral(ev.Channel, ev.User, h.bot.auth.RejectMessage(""))
+		return
+	}
+
+	// "rules" is a reserved subcommand (like "permissions" in "@bot"
+	// mentions) for auditing/managing a task's PermissionStore, rather
+	// than a task name to route to.
+	text := strings.TrimSpace(ev.Text)
+	if text == "rules" || strings.HasPrefix(strings.ToLower(text), "rules ") {
+		h.handleRulesSlashCommand(ev, strings.TrimSpace(text[len("rules"):]), logger)
+		return
+	}
+
+	routed, err := h.bot.Commands().Route(ev.Text)
+	if err != nil {
+		h.bot.PostEphemeral(ev.Channel, ev.User, err.Error())
+		return
+	}
+
+	logger = logger.With().
+		Str("task", routed.TaskName).
+		Str("instructions", routed.Instructions).
+		Logger()
+
+	logger.Info().Str("task_path", routed.TaskPath).Msg("starting new task from slash command")
+
+	// Post the initial status message and use it as the thread root; a
+	// slash command arrives with no message of its own to thread off of.
+	threadTS, err := h.bot.PostMessage(
+		ev.Channel,
+		fmt.Sprintf(":rocket: Starting a `%s` task...", routed.TaskName),
+		"",
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to post slash command status message")
+		h.bot.PostEphemeral(ev.Channel, ev.User, fmt.Sprintf(":x: Failed to start task: %v", err))
+		return
+	}
+
+	h.runClod(
+		ctx,
+		ev.Channel,
+		ev.User,
+		routed.TaskPath,
+		routed.TaskName,
+		routed.Instructions,
+		"",
+		threadTS,
+		logger,
+	)
+}
+
 // HandleMessage processes regular message events (for thread replies).
-func (h *Handler) HandleMessage(ctx context.Context, ev *slackevents.MessageEvent) {
+func (h *Handler) HandleMessage(ctx context.Context, ev chat.ChatEvent) {
 	// Ignore bot messages
 	if ev.BotID != "" {
 		return
@@ -146,7 +364,8 @@ func (h *Handler) HandleMessage(ctx context.Context, ev *slackevents.MessageEven
 					Msg("received permission response from user (text)")
 
 				task.SendPermissionResponse(*resp)
-				h.pendingPermissions.Delete(progressKey)
+				h.deletePendingPermission(progressKey)
+				h.seenToolUseIDs.Delete(perm.ToolUseID)
 
 				// Update the permission message to show it was handled
 				h.updatePermissionMessage(perm, resp.Behavior, ev.User, "")
@@ -165,6 +384,8 @@ func (h *Handler) HandleMessage(ctx context.Context, ev *slackevents.MessageEven
 		logger.Debug().Str("input", ev.Text).Msg("sending thread reply to running task")
 		if err := task.SendInput(ev.Text); err != nil {
 			logger.Error().Err(err).Msg("failed to send input to task")
+		} else {
+			task.RecordInputTS(ev.TimeStamp)
 		}
 		return
 	}
@@ -183,7 +404,7 @@ func (h *Handler) HandleMessage(ctx context.Context, ev *slackevents.MessageEven
 	}
 
 	// Check authorization
-	if !h.bot.auth.IsAuthorized(ev.User) {
+	if !h.isAuthorized(ev.User) {
 		logger.Warn().Msg("unauthorized user trying to resume session")
 		return
 	}
@@ -198,40 +419,50 @@ func (h *Handler) HandleMessage(ctx context.Context, ev *slackevents.MessageEven
 	logger.Info().Msg("resuming session from thread reply")
 
 	// Check for files attached to the message and download them to .clod-runtime/inputs.
-	slackFiles, err := h.bot.files.GetThreadReplyFiles(ev.Channel, threadTS, ev.TimeStamp)
-	if err != nil {
-		logger.Warn().Err(err).Msg("failed to check for thread reply files")
-	}
-
-	// Download files to disk for Claude to read.
 	var downloadedFiles []string
-	if len(slackFiles) > 0 {
-		h.bot.PostMessage(
-			ev.Channel,
-			fmt.Sprintf(":inbox_tray: Downloading %d file(s)...", len(slackFiles)),
-			threadTS,
-		)
-		for _, file := range slackFiles {
-			localPath, err := h.bot.files.DownloadToTask(file, session.TaskPath)
-			if err != nil {
-				logger.Error().Err(err).Str("file_id", file.ID).Msg("failed to download file")
-				h.bot.PostMessage(
-					ev.Channel,
-					fmt.Sprintf(":warning: Failed to download `%s`: %v", file.Name, err),
-					threadTS,
-				)
-				continue
+	if h.bot.files != nil {
+		slackFiles, err := h.bot.files.GetThreadReplyFiles(ev.Channel, threadTS, ev.TimeStamp)
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to check for thread reply files")
+		}
+
+		// Download files to disk for Claude to read.
+		if len(slackFiles) > 0 {
+			h.bot.PostMessage(
+				ev.Channel,
+				fmt.Sprintf(":inbox_tray: Downloading %d file(s)...", len(slackFiles)),
+				threadTS,
+			)
+			for _, file := range slackFiles {
+				localPath, err := h.bot.files.DownloadToTask(file, session.TaskPath, ev.Channel, threadTS)
+				if err != nil {
+					logger.Error().Err(err).Str("file_id", file.ID).Msg("failed to download file")
+					h.bot.PostMessage(
+						ev.Channel,
+						fmt.Sprintf(":warning: Failed to download `%s`: %v", file.Name, err),
+						threadTS,
+					)
+					continue
+				}
+				logger.Info().
+					Str("file_id", file.ID).
+					Str("local_path", localPath).
+					Msg("file downloaded to task inputs")
+
+				if !h.filterDownloadedFile(ev.Channel, threadTS, session.TaskPath, localPath, logger) {
+					continue
+				}
+				downloadedFiles = append(downloadedFiles, localPath)
 			}
-			logger.Info().
-				Str("file_id", file.ID).
-				Str("local_path", localPath).
-				Msg("file downloaded to task inputs")
-			downloadedFiles = append(downloadedFiles, localPath)
 		}
 	}
 
+	prompt, blocked := h.applyInputFilters(ev.Channel, threadTS, session.TaskPath, ev.Text)
+	if blocked {
+		return
+	}
+
 	// Build the prompt, appending file paths if any were downloaded.
-	prompt := ev.Text
 	if len(downloadedFiles) > 0 {
 		prompt += "\n\nAttached files have been saved to:\n"
 		for _, path := range downloadedFiles {
@@ -260,10 +491,263 @@ func (h *Handler) HandleMessage(ctx context.Context, ev *slackevents.MessageEven
 	)
 }
 
+// HandleMessageChanged processes a message_changed event. If the edited
+// message was a thread reply routed to a RunningTask, its new text is sent
+// along as a correction (prefixed `[edit]`) rather than silently ignored,
+// since the task already consumed the original wording.
+func (h *Handler) HandleMessageChanged(ctx context.Context, ev chat.ChatEvent) {
+	if ev.BotID != "" {
+		return
+	}
+
+	threadTS := ev.ThreadTimeStamp
+	if threadTS == "" {
+		return
+	}
+
+	progressKey := key(ev.Channel, threadTS)
+	taskVal, ok := h.runningTasks.Load(progressKey)
+	if !ok {
+		return
+	}
+	task := taskVal.(*RunningTask)
+
+	logger := h.logger.With().
+		Str("channel", ev.Channel).
+		Str("thread_ts", threadTS).
+		Str("user", ev.User).
+		Str("text", ev.Text).
+		Logger()
+
+	logger.Info().Msg("thread reply edited, sending as a correction")
+	if err := task.SendInput(fmt.Sprintf("[edit] %s", ev.Text)); err != nil {
+		logger.Error().Err(err).Msg("failed to send edit correction to task")
+		return
+	}
+	task.RecordInputTS(ev.TimeStamp)
+}
+
+// HandleMessageDeleted processes a message_deleted event. Deleting the
+// @mention that started a thread stops its task and revokes the saved
+// session; deleting the most recent thread-reply input asks
+// RunningTask.CancelPendingInput whether it's still pending and, if so,
+// tells the task to disregard it (clod has already read whatever was
+// written to its stdin by this point, so there's nothing to literally
+// un-send).
+func (h *Handler) HandleMessageDeleted(ctx context.Context, ev chat.ChatEvent) {
+	threadTS := ev.ThreadTimeStamp
+	if threadTS == "" {
+		return
+	}
+
+	logger := h.logger.With().
+		Str("channel", ev.Channel).
+		Str("thread_ts", threadTS).
+		Str("deleted_ts", ev.TimeStamp).
+		Logger()
+
+	progressKey := key(ev.Channel, threadTS)
+	taskVal, ok := h.runningTasks.Load(progressKey)
+	if ok {
+		task := taskVal.(*RunningTask)
+
+		if ev.TimeStamp == threadTS {
+			logger.Info().Msg("thread-starting message deleted, cancelling task and revoking session")
+			task.Cancel()
+			if err := h.bot.sessions.Delete(ev.Channel, threadTS); err != nil {
+				logger.Error().Err(err).Msg("failed to revoke session")
+			}
+			return
+		}
+
+		if task.CancelPendingInput(ev.TimeStamp) {
+			logger.Info().Msg("most recent input deleted before being acted on, sending cancellation")
+			if err := task.SendInput("[cancelled] disregard the previous message."); err != nil {
+				logger.Error().Err(err).Msg("failed to send cancellation to task")
+			}
+		}
+		return
+	}
+
+	// No running task: if the deleted message was the thread root of a
+	// saved (but not currently running) session, revoke that too.
+	if ev.TimeStamp == threadTS && h.bot.sessions.Get(ev.Channel, threadTS) != nil {
+		logger.Info().Msg("thread-starting message for saved session deleted, revoking session")
+		if err := h.bot.sessions.Delete(ev.Channel, threadTS); err != nil {
+			logger.Error().Err(err).Msg("failed to revoke session")
+		}
+	}
+}
+
+// HandleFileDeleted processes a file_deleted event. Slack's file_deleted
+// event carries only the file's ID, so the corresponding local path (if the
+// file was ever downloaded into a task directory via
+// FileHandler.DownloadToTask) comes from the file mapping store; see
+// FileHandler.HandleDeletedFile.
+func (h *Handler) HandleFileDeleted(ctx context.Context, ev chat.ChatEvent) {
+	if h.bot.files == nil {
+		return
+	}
+
+	logger := h.logger.With().Str("file_id", ev.FileID).Logger()
+
+	ref, ok, err := h.bot.files.HandleDeletedFile(ev.FileID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to remove local copy of deleted Slack file")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	logger.Info().
+		Str("local_path", ref.LocalPath).
+		Str("channel", ref.ChannelID).
+		Str("thread_ts", ref.ThreadTS).
+		Msg("removed local copy of deleted Slack file")
+}
+
+// HandleReactionAdded processes a reaction_added event, giving emoji
+// shortcuts for the same decisions available as permission-prompt buttons
+// (see buildPermissionBlocks) plus two task-lifecycle controls:
+//   - white_check_mark: Allow Once
+//   - x: Deny
+//   - repeat: Allow Similar (reuses generateSimilarPattern)
+//   - lock: Allow Always (all uses of the tool)
+//   - octagonal_sign on a bot message in the thread: cancel the running task
+//   - bookmark on a bot message in the thread: pin the session so it's
+//     exempt from future GC
+//
+// The reacted-to message only tells us its own channel/ts, not which thread
+// it belongs to, so resolution goes through Bot.ThreadForMessage (populated
+// by Bot.trackMessage whenever the bot posts).
+func (h *Handler) HandleReactionAdded(ctx context.Context, ev chat.ChatEvent) {
+	if !h.isAuthorized(ev.User) {
+		return
+	}
+
+	threadTS, ok := h.bot.ThreadForMessage(ev.Channel, ev.TimeStamp)
+	if !ok {
+		return
+	}
+	progressKey := key(ev.Channel, threadTS)
+
+	logger := h.logger.With().
+		Str("channel", ev.Channel).
+		Str("thread_ts", threadTS).
+		Str("user", ev.User).
+		Str("reaction", ev.Reaction).
+		Logger()
+
+	switch ev.Reaction {
+	case "white_check_mark", "x", "repeat", "lock":
+		pendingVal, ok := h.pendingPermissions.Load(progressKey)
+		if !ok {
+			return
+		}
+		pending := pendingVal.(*PendingPermission)
+		if pending.MessageTS != ev.TimeStamp {
+			// The reaction landed on some other bot message in the thread,
+			// not the current permission prompt.
+			return
+		}
+
+		params := resolvePermissionParams{
+			channelID: ev.Channel,
+			messageTS: pending.MessageTS,
+			threadKey: progressKey,
+			userID:    ev.User,
+			source:    "reaction",
+		}
+		switch ev.Reaction {
+		case "white_check_mark":
+			params.behavior = "allow"
+		case "x":
+			params.behavior = "deny"
+		case "repeat":
+			params.behavior = "allow"
+			params.remember = generateSimilarPattern(pending.ToolName, pending.ToolInput)
+			params.scope = "task"
+		case "lock":
+			params.behavior = "allow"
+			params.remember = pending.ToolName
+			params.scope = "task"
+		}
+		if params.remember == "" && (ev.Reaction == "repeat" || ev.Reaction == "lock") {
+			// generateSimilarPattern found nothing to key on; fall through
+			// to a plain allow rather than remembering an empty pattern.
+			logger.Warn().Msg("no pattern to remember for this tool, allowing once instead")
+		}
+		logger.Info().Str("behavior", params.behavior).Msg("handling permission reaction")
+		h.resolvePermission(params, logger)
+
+	case "octagonal_sign":
+		taskVal, ok := h.runningTasks.Load(progressKey)
+		if !ok {
+			return
+		}
+		logger.Info().Msg("cancelling task via reaction")
+		taskVal.(*RunningTask).Cancel()
+
+	case "bookmark":
+		logger.Info().Msg("pinning session via reaction")
+		if err := h.bot.sessions.SetPinned(ev.Channel, threadTS, true); err != nil {
+			logger.Error().Err(err).Msg("failed to pin session")
+		}
+	}
+}
+
 // handleNewTask processes a new task request.
+// applyInputFilters runs text (a prompt about to be passed to runClod)
+// through taskPath's FilterPipeline. If an exclude rule (or a failed include
+// rule) says the input shouldn't reach Claude, it posts a :no_entry: notice
+// to the thread and reports blocked=true so the caller can drop the input
+// instead of forwarding it.
+func (h *Handler) applyInputFilters(channelID, threadTS, taskPath, text string) (filtered string, blocked bool) {
+	filtered, blocked = h.filterPipeline(taskPath).Apply(key(channelID, threadTS), text)
+	if blocked {
+		h.bot.PostMessage(channelID, ":no_entry: Your message was blocked by this task's input filters.", threadTS)
+	}
+	return filtered, blocked
+}
+
+// filterDownloadedFile applies taskPath's FilterPipeline to a just-downloaded
+// file's contents at localPath, rewriting it with redaction tokens in place.
+// If an exclude rule matches, the file is removed and a :no_entry: notice is
+// posted instead; the caller should then drop it from the prompt's file
+// list. Returns false when the file was blocked.
+func (h *Handler) filterDownloadedFile(channelID, threadTS, taskPath, localPath string, logger zerolog.Logger) bool {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		logger.Warn().Err(err).Str("local_path", localPath).Msg("failed to read downloaded file for input filtering")
+		return true
+	}
+
+	filtered, blocked := h.filterPipeline(taskPath).Apply(key(channelID, threadTS), string(data))
+	if blocked {
+		if err := os.Remove(localPath); err != nil {
+			logger.Warn().Err(err).Str("local_path", localPath).Msg("failed to remove file blocked by input filters")
+		}
+		h.bot.PostMessage(
+			channelID,
+			fmt.Sprintf(":no_entry: `%s` was blocked by this task's input filters.", filepath.Base(localPath)),
+			threadTS,
+		)
+		return false
+	}
+
+	if filtered != string(data) {
+		if err := os.WriteFile(localPath, []byte(filtered), 0644); err != nil {
+			logger.Warn().Err(err).Str("local_path", localPath).Msg("failed to write redacted file contents")
+		}
+	}
+
+	return true
+}
+
 func (h *Handler) handleNewTask(
 	ctx context.Context,
-	ev *slackevents.AppMentionEvent,
+	ev chat.ChatEvent,
 	threadTS string,
 	logger zerolog.Logger,
 ) {
@@ -272,7 +756,7 @@ func (h *Handler) handleNewTask(
 	if parsed == nil {
 		msg := fmt.Sprintf(
 			"I didn't understand that. Please use the format: `@bot task_name: your instructions`\n\n%s",
-			h.bot.tasks.ListFormatted(),
+			h.bot.Tasks().ListFormatted(),
 		)
 		h.bot.PostMessage(ev.Channel, msg, threadTS)
 		return
@@ -284,12 +768,12 @@ func (h *Handler) handleNewTask(
 		Logger()
 
 	// Look up the task
-	taskPath, err := h.bot.tasks.Get(parsed.TaskName)
+	taskPath, err := h.bot.Tasks().Get(parsed.TaskName)
 	if err != nil {
 		msg := fmt.Sprintf(
 			"Unknown task: `%s`\n\n%s",
 			parsed.TaskName,
-			h.bot.tasks.ListFormatted(),
+			h.bot.Tasks().ListFormatted(),
 		)
 		h.bot.PostMessage(ev.Channel, msg, threadTS)
 		return
@@ -298,45 +782,55 @@ func (h *Handler) handleNewTask(
 	logger.Info().Str("task_path", taskPath).Msg("starting new task")
 
 	// Check for files attached to the message and download them to .clod-runtime/inputs.
-	logger.Debug().
-		Str("channel", ev.Channel).
-		Str("message_ts", ev.TimeStamp).
-		Msg("checking for files in message")
-	slackFiles, err := h.bot.files.GetMessageFiles(ev.Channel, ev.TimeStamp)
-	if err != nil {
-		logger.Warn().Err(err).Msg("failed to check for message files")
-	}
-	logger.Debug().Int("num_files", len(slackFiles)).Msg("files check complete")
-
-	// Download files to disk for Claude to read.
 	var downloadedFiles []string
-	if len(slackFiles) > 0 {
-		h.bot.PostMessage(
-			ev.Channel,
-			fmt.Sprintf(":inbox_tray: Downloading %d file(s)...", len(slackFiles)),
-			threadTS,
-		)
-		for _, file := range slackFiles {
-			localPath, err := h.bot.files.DownloadToTask(file, taskPath)
-			if err != nil {
-				logger.Error().Err(err).Str("file_id", file.ID).Msg("failed to download file")
-				h.bot.PostMessage(
-					ev.Channel,
-					fmt.Sprintf(":warning: Failed to download `%s`: %v", file.Name, err),
-					threadTS,
-				)
-				continue
+	if h.bot.files != nil {
+		logger.Debug().
+			Str("channel", ev.Channel).
+			Str("message_ts", ev.TimeStamp).
+			Msg("checking for files in message")
+		slackFiles, err := h.bot.files.GetMessageFiles(ev.Channel, ev.TimeStamp)
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to check for message files")
+		}
+		logger.Debug().Int("num_files", len(slackFiles)).Msg("files check complete")
+
+		// Download files to disk for Claude to read.
+		if len(slackFiles) > 0 {
+			h.bot.PostMessage(
+				ev.Channel,
+				fmt.Sprintf(":inbox_tray: Downloading %d file(s)...", len(slackFiles)),
+				threadTS,
+			)
+			for _, file := range slackFiles {
+				localPath, err := h.bot.files.DownloadToTask(file, taskPath, ev.Channel, threadTS)
+				if err != nil {
+					logger.Error().Err(err).Str("file_id", file.ID).Msg("failed to download file")
+					h.bot.PostMessage(
+						ev.Channel,
+						fmt.Sprintf(":warning: Failed to download `%s`: %v", file.Name, err),
+						threadTS,
+					)
+					continue
+				}
+				logger.Info().
+					Str("file_id", file.ID).
+					Str("local_path", localPath).
+					Msg("file downloaded to task inputs")
+
+				if !h.filterDownloadedFile(ev.Channel, threadTS, taskPath, localPath, logger) {
+					continue
+				}
+				downloadedFiles = append(downloadedFiles, localPath)
 			}
-			logger.Info().
-				Str("file_id", file.ID).
-				Str("local_path", localPath).
-				Msg("file downloaded to task inputs")
-			downloadedFiles = append(downloadedFiles, localPath)
 		}
 	}
 
+	prompt, blocked := h.applyInputFilters(ev.Channel, threadTS, taskPath, parsed.Instructions)
+	if blocked {
+		return
+	}
+
 	// Build the prompt, appending file paths if any were downloaded.
-	prompt := parsed.Instructions
 	if len(downloadedFiles) > 0 {
 		prompt += "\n\nAttached files have been saved to:\n"
 		for _, path := range downloadedFiles {
@@ -368,7 +862,7 @@ func (h *Handler) handleNewTask(
 // handleContinuation processes a continuation in an existing thread.
 func (h *Handler) handleContinuation(
 	ctx context.Context,
-	ev *slackevents.AppMentionEvent,
+	ev chat.ChatEvent,
 	session *SessionMapping,
 	threadTS string,
 	logger zerolog.Logger,
@@ -387,6 +881,11 @@ func (h *Handler) handleContinuation(
 
 	logger.Info().Msg("continuing existing session")
 
+	instructions, blocked := h.applyInputFilters(ev.Channel, threadTS, session.TaskPath, instructions)
+	if blocked {
+		return
+	}
+
 	// Post initial status
 	h.bot.PostMessage(
 		ev.Channel,
@@ -408,7 +907,7 @@ func (h *Handler) handleContinuation(
 	)
 }
 
-// runClod executes clod and streams output to Slack.
+// runClod executes clod and streams output to chat.
 func (h *Handler) runClod(
 	ctx context.Context,
 	channelID string,
@@ -428,16 +927,28 @@ func (h *Handler) runClod(
 		return
 	}
 
+	h.bot.Notify(NotifyEvent{
+		Type:      NotifyStarted,
+		TaskName:  taskName,
+		TaskPath:  taskPath,
+		ChannelID: channelID,
+		ThreadTS:  threadTS,
+		SessionID: sessionID,
+	})
+
 	// Register the running task
 	progressKey := key(channelID, threadTS)
 	h.runningTasks.Store(progressKey, task)
 	defer h.runningTasks.Delete(progressKey)
-	defer h.pendingPermissions.Delete(progressKey) // Clean up any pending permission state
-
-	// Start watching for output files to upload to Slack.
-	outputWatchDone := make(chan struct{})
-	go h.bot.files.WatchOutputs(taskPath, channelID, threadTS, outputWatchDone)
-	defer close(outputWatchDone)
+	defer h.deletePendingPermission(progressKey) // Clean up any pending permission state
+
+	// Start watching for output files to upload to chat, if the
+	// transport has a file-sharing equivalent.
+	if h.bot.files != nil {
+		outputWatchDone := make(chan struct{})
+		go h.bot.files.WatchOutputs(taskPath, channelID, threadTS, outputWatchDone)
+		defer close(outputWatchDone)
+	}
 
 	// Output batching
 	const batchInterval = 2 * time.Second
@@ -453,7 +964,7 @@ func (h *Handler) runClod(
 			// Convert GitHub-flavored markdown to Slack's mrkdwn format.
 			msg := strings.TrimSpace(outputBuffer.String())
 			if msg != "" {
-				msg = ConvertMarkdownToMrkdwn(msg)
+				msg = mrkdwn.ConvertMarkdownToMrkdwn(msg)
 				if _, err := h.bot.PostMessage(channelID, msg, threadTS); err != nil {
 					logger.Debug().Err(err).Msg("failed to post output message")
 				}
@@ -477,7 +988,7 @@ func (h *Handler) runClod(
 
 			// Check for special stats message.
 			if strings.HasPrefix(content, "__STATS__") {
-				flushBuffer() // Flush any pending output first.
+				flushBuffer()                                        // Flush any pending output first.
 				h.postStatsMessage(channelID, threadTS, content[9:]) // Skip "__STATS__" prefix.
 				continue
 			}
@@ -485,14 +996,36 @@ func (h *Handler) runClod(
 			// Check for snippet message (tool output to upload as collapsible file).
 			if strings.HasPrefix(content, "__SNIPPET__") {
 				flushBuffer() // Flush any pending output first.
-				// Format: __SNIPPET__toolName\x00inputJSON\x00content
+				// Format: __SNIPPET__toolName\x00inputJSON\x00hash\x00content
 				payload := content[11:] // Skip "__SNIPPET__" prefix.
+				parts := strings.SplitN(payload, "\x00", 4)
+				if len(parts) == 4 {
+					toolName := parts[0]
+					inputJSON := parts[1]
+					hash := parts[2]
+					snippetContent := parts[3]
+					h.postToolSnippet(channelID, threadTS, toolName, inputJSON, hash, snippetContent, taskPath, logger)
+				}
+				continue
+			}
+
+			// Check for a reference to a snippet already cached on disk
+			// (identical content seen earlier in this task).
+			if strings.HasPrefix(content, "__SNIPPET_REF__") {
+				flushBuffer() // Flush any pending output first.
+				// Format: __SNIPPET_REF__toolName\x00inputJSON\x00hash
+				payload := content[15:] // Skip "__SNIPPET_REF__" prefix.
 				parts := strings.SplitN(payload, "\x00", 3)
 				if len(parts) == 3 {
 					toolName := parts[0]
 					inputJSON := parts[1]
-					snippetContent := parts[2]
-					h.postToolSnippet(channelID, threadTS, toolName, inputJSON, snippetContent, logger)
+					hash := parts[2]
+					snippetContent, err := h.bot.runner.SnippetContent(taskPath, hash)
+					if err != nil {
+						logger.Error().Err(err).Str("tool", toolName).Str("hash", hash).Msg("failed to read cached tool snippet")
+					} else {
+						h.postToolSnippet(channelID, threadTS, toolName, inputJSON, hash, string(snippetContent), taskPath, logger)
+					}
 				}
 				continue
 			}
@@ -506,42 +1039,114 @@ func (h *Handler) runClod(
 
 		case req, ok := <-permRequests:
 			if ok {
-				// Check if this permission is already allowed by saved rules.
+				h.bot.Notify(NotifyEvent{
+					Type:      NotifyTool,
+					TaskName:  taskName,
+					TaskPath:  task.taskPath,
+					ChannelID: channelID,
+					ThreadTS:  threadTS,
+					SessionID: sessionID,
+					ToolName:  req.ToolName,
+				})
+
+				// Consult the policy engine first; it covers both the
+				// ops-managed global ruleset and session/task rules granted
+				// from the "remember" buttons, and records every decision
+				// to the audit log.
+				if h.bot.policy != nil {
+					if effect, ruleID, matched := h.bot.policy.Evaluate(req, task.taskPath); matched {
+						logger.Info().
+							Str("tool_name", req.ToolName).
+							Str("rule_id", ruleID).
+							Str("effect", effect).
+							Msg("policy engine decided permission request")
+						if effect == "deny" {
+							metrics.PermissionDenials.WithLabelValues(req.ToolName).Inc()
+							task.SendPermissionResponse(PermissionResponse{
+								Behavior: "deny",
+								Message:  fmt.Sprintf("Denied by policy rule %s", ruleID),
+							})
+						} else {
+							metrics.PermissionGrants.WithLabelValues(req.ToolName).Inc()
+							task.SendPermissionResponse(PermissionResponse{Behavior: "allow"})
+						}
+						continue
+					}
+				}
+
+				// Fall back to the legacy claude.json allowedTools list.
 				if h.isPermissionAllowed(task.taskPath, req.ToolName, req.ToolInput) {
 					logger.Info().
 						Str("tool_name", req.ToolName).
 						Msg("auto-allowing permission based on saved rule")
+					metrics.PermissionGrants.WithLabelValues(req.ToolName).Inc()
 					task.SendPermissionResponse(PermissionResponse{Behavior: "allow"})
 					continue
 				}
 
-				// Post formatted permission prompt with buttons to Slack.
+				// A transport reconnect mid-prompt can hand the same
+				// request back to us; skip re-posting it if it's already
+				// awaiting a response.
+				if req.ToolUseID != "" {
+					if _, dup := h.seenToolUseIDs.LoadOrStore(req.ToolUseID, struct{}{}); dup {
+						logger.Debug().Str("tool_use_id", req.ToolUseID).Msg("duplicate permission request after reconnect, ignoring")
+						continue
+					}
+				}
+
+				// A matching quorum rule gates the request behind N
+				// approvals instead of the usual single decider; it's
+				// consulted last, after the policy engine and legacy
+				// allowedTools list both declined to auto-decide.
+				var quorumVote *QuorumVote
+				if h.bot.quorum != nil {
+					if rule, matched := h.bot.quorum.Evaluate(req); matched {
+						quorumVote = &QuorumVote{Rule: rule, Votes: make(map[string]string)}
+					}
+				}
+
+				// Post formatted permission prompt with buttons.
 				flushBuffer() // Flush any pending output first.
-				blocks := h.buildPermissionBlocks(req, progressKey)
-				msgTS, err := h.bot.PostMessageBlocks(channelID, blocks, threadTS)
+				blocks := h.buildPermissionBlocks(req, progressKey, task.taskPath)
+				if quorumVote != nil {
+					blocks = append([]chat.Block{quorumTallyBlock(quorumVote)}, blocks...)
+				}
+				msgTS, err := h.bot.SendInteractive(channelID, blocks, threadTS)
 				if err != nil {
 					logger.Error().Err(err).Msg("failed to post permission prompt")
 					// Send deny on failure to post.
 					task.SendPermissionResponse(
 						PermissionResponse{Behavior: "deny", Message: "Failed to prompt user"},
 					)
+					h.seenToolUseIDs.Delete(req.ToolUseID)
 					continue
 				}
 
 				// Track the pending permission with its message timestamp and tool details.
-				h.pendingPermissions.Store(progressKey, &PendingPermission{
+				pending := &PendingPermission{
 					MessageTS: msgTS,
 					ChannelID: channelID,
 					ThreadTS:  threadTS,
+					ToolUseID: req.ToolUseID,
 					ToolName:  req.ToolName,
 					ToolInput: req.ToolInput,
-				})
+					TaskPath:  task.taskPath,
+					SessionID: req.SessionID,
+					Quorum:    quorumVote,
+				}
+				h.storePendingPermission(progressKey, pending)
+
+				if quorumVote != nil && quorumVote.Rule.timeout > 0 {
+					quorumVote.timeoutTimer = time.AfterFunc(quorumVote.Rule.timeout, func() {
+						h.quorumTimeoutExpired(progressKey)
+					})
+				}
 
 				logger.Info().
 					Str("tool_name", req.ToolName).
 					Str("tool_use_id", req.ToolUseID).
 					Str("message_ts", msgTS).
-					Msg("posted permission prompt to slack, waiting for response")
+					Msg("posted permission prompt, waiting for response")
 			}
 
 		case <-ticker.C:
@@ -564,6 +1169,7 @@ func (h *Handler) runClod(
 				finalMsg = ":white_check_mark: Task completed!"
 			}
 			h.bot.PostMessage(channelID, finalMsg, threadTS)
+			h.notifyTaskResult(taskName, taskPath, channelID, threadTS, result)
 
 			// Save session mapping
 			if result.SessionID != "" {
@@ -576,8 +1182,9 @@ func (h *Handler) runClod(
 					UserID:    userID,
 					CreatedAt: time.Now(),
 				}
-				h.bot.sessions.Set(session)
-
+				if err := h.bot.sessions.Put(session); err != nil {
+					logger.Error().Err(err).Msg("failed to store session")
+				}
 				if err := h.bot.sessions.Save(); err != nil {
 					logger.Error().Err(err).Msg("failed to save sessions")
 				}
@@ -600,6 +1207,7 @@ done:
 		finalMsg = ":white_check_mark: Task completed!"
 	}
 	h.bot.PostMessage(channelID, finalMsg, threadTS)
+	h.notifyTaskResult(taskName, taskPath, channelID, threadTS, result)
 
 	// Save session mapping
 	if result.SessionID != "" {
@@ -612,82 +1220,80 @@ done:
 			UserID:    userID,
 			CreatedAt: time.Now(),
 		}
-		h.bot.sessions.Set(session)
-
+		if err := h.bot.sessions.Put(session); err != nil {
+			logger.Error().Err(err).Msg("failed to store session")
+		}
 		if err := h.bot.sessions.Save(); err != nil {
 			logger.Error().Err(err).Msg("failed to save sessions")
 		}
 	}
 }
 
+// notifyTaskResult fires the completed/failed/timed_out lifecycle
+// notification for a finished task's Result.
+func (h *Handler) notifyTaskResult(taskName, taskPath, channelID, threadTS string, result *Result) {
+	evt := NotifyEvent{
+		TaskName:  taskName,
+		TaskPath:  taskPath,
+		ChannelID: channelID,
+		ThreadTS:  threadTS,
+		SessionID: result.SessionID,
+	}
+
+	switch {
+	case result.Error == nil:
+		evt.Type = NotifyCompleted
+	case strings.Contains(result.Error.Error(), "timed out"):
+		evt.Type = NotifyTimedOut
+		evt.Message = result.Error.Error()
+	default:
+		evt.Type = NotifyFailed
+		evt.Message = result.Error.Error()
+	}
+
+	h.bot.Notify(evt)
+}
+
 // PermissionActionValue holds the data encoded in button action values.
 type PermissionActionValue struct {
 	ThreadKey string `json:"k"`           // The progressKey for looking up the task
 	Behavior  string `json:"b"`           // "allow" or "deny"
 	Remember  string `json:"r,omitempty"` // Permission pattern to remember (empty = one-time)
+	Scope     string `json:"s,omitempty"` // Policy scope for Remember: "task" or "session"
+	TTL       string `json:"t,omitempty"` // Policy TTL for Remember (time.ParseDuration syntax); empty = no expiry
 }
 
-// buildPermissionBlocks creates Slack blocks for a permission prompt with buttons.
-func (h *Handler) buildPermissionBlocks(req PermissionRequest, progressKey string) []slack.Block {
-	blocks := []slack.Block{}
+// buildPermissionBlocks creates chat Blocks for a permission prompt with
+// buttons. taskPath resolves relative file_paths for the Write/Edit diff
+// preview (see diffPreviewBlocks).
+func (h *Handler) buildPermissionBlocks(req PermissionRequest, progressKey, taskPath string) []chat.Block {
+	blocks := []chat.Block{}
 
 	// Header
-	headerText := slack.NewTextBlockObject("mrkdwn", ":lock: *Permission Required*", false, false)
-	blocks = append(blocks, slack.NewSectionBlock(headerText, nil, nil))
+	blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: ":lock: *Permission Required*"}})
 
 	// Tool name
-	toolText := slack.NewTextBlockObject(
-		"mrkdwn",
-		fmt.Sprintf("*Tool:* `%s`", req.ToolName),
-		false,
-		false,
-	)
-	blocks = append(blocks, slack.NewSectionBlock(toolText, nil, nil))
+	blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: fmt.Sprintf("*Tool:* `%s`", req.ToolName)}})
 
 	// Tool-specific details
-	var detailText string
-	switch req.ToolName {
-	case "Bash":
-		if cmd, ok := req.ToolInput["command"].(string); ok {
-			// Truncate long commands for display
-			if len(cmd) > 500 {
-				cmd = cmd[:500] + "..."
-			}
-			detailText = fmt.Sprintf("*Command:*\n```%s```", cmd)
-		}
-	case "Write", "Edit":
-		if path, ok := req.ToolInput["file_path"].(string); ok {
-			detailText = fmt.Sprintf("*File:* `%s`", path)
-		}
-	case "Read":
-		if path, ok := req.ToolInput["file_path"].(string); ok {
-			detailText = fmt.Sprintf("*File:* `%s`", path)
-		}
-	case "WebFetch":
-		if url, ok := req.ToolInput["url"].(string); ok {
-			detailText = fmt.Sprintf("*URL:* %s", url)
-		}
-	case "WebSearch":
-		if query, ok := req.ToolInput["query"].(string); ok {
-			detailText = fmt.Sprintf("*Query:* `%s`", query)
-		}
-	default:
-		// Generic display of tool input
-		var parts []string
-		for k, v := range req.ToolInput {
-			parts = append(parts, fmt.Sprintf("*%s:* `%v`", k, v))
-		}
-		detailText = strings.Join(parts, "\n")
+	if detailText := permissionDetailText(req.ToolName, req.ToolInput); detailText != "" {
+		blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: detailText}})
 	}
 
-	if detailText != "" {
-		detailBlock := slack.NewTextBlockObject("mrkdwn", detailText, false, false)
-		blocks = append(blocks, slack.NewSectionBlock(detailBlock, nil, nil))
+	// A structured diff preview for Write/Edit, or a static-analysis
+	// hazard warning for Bash, so an approver has more to go on than the
+	// raw file path or command (see diffPreviewBlocks, bashHazardBlock).
+	if diffBlocks, ok := h.diffPreviewBlocks(req.ToolName, req.ToolInput, taskPath, progressKey); ok {
+		blocks = append(blocks, diffBlocks...)
+	}
+	if hazardBlock, ok := bashHazardBlock(req.ToolName, req.ToolInput); ok {
+		blocks = append(blocks, hazardBlock)
 	}
 
 	// Generate permission patterns for "remember" options
 	alwaysPattern := req.ToolName // e.g., "Bash" allows all Bash commands
 	similarPattern := generateSimilarPattern(req.ToolName, req.ToolInput)
+	exactPattern := generateExactPattern(req.ToolName, req.ToolInput)
 
 	// Encode action values
 	allowOnceValue, _ := json.Marshal(PermissionActionValue{
@@ -698,58 +1304,125 @@ func (h *Handler) buildPermissionBlocks(req PermissionRequest, progressKey strin
 		ThreadKey: progressKey,
 		Behavior:  "allow",
 		Remember:  alwaysPattern,
+		Scope:     "task",
 	})
 	denyValue, _ := json.Marshal(PermissionActionValue{
 		ThreadKey: progressKey,
 		Behavior:  "deny",
 	})
 
-	// Action buttons - first row: Allow Once, Deny
-	allowOnceBtn := slack.NewButtonBlockElement(
-		"permission_allow",
-		string(allowOnceValue),
-		slack.NewTextBlockObject("plain_text", "Allow Once", false, false),
-	)
-	allowOnceBtn.Style = "primary"
-
-	denyBtn := slack.NewButtonBlockElement(
-		"permission_deny",
-		string(denyValue),
-		slack.NewTextBlockObject("plain_text", "Deny", false, false),
-	)
-	denyBtn.Style = "danger"
-
-	actionBlock1 := slack.NewActionBlock("permission_actions", allowOnceBtn, denyBtn)
-	blocks = append(blocks, actionBlock1)
+	// First row: Allow Once, Deny
+	blocks = append(blocks, chat.Actions{
+		BlockID: "permission_actions",
+		Buttons: []chat.Button{
+			{ActionID: "permission_allow", Value: string(allowOnceValue), Label: "Allow Once", Style: "primary"},
+			{ActionID: "permission_deny", Value: string(denyValue), Label: "Deny", Style: "danger"},
+		},
+	})
 
 	// Second row: Allow Always, Allow Similar (if pattern is different from always)
-	allowAlwaysBtn := slack.NewButtonBlockElement(
-		"permission_allow_always",
-		string(allowAlwaysValue),
-		slack.NewTextBlockObject("plain_text", fmt.Sprintf("Allow All %s", req.ToolName), false, false),
-	)
-
+	row2 := chat.Actions{
+		BlockID: "permission_actions_2",
+		Buttons: []chat.Button{
+			{ActionID: "permission_allow_always", Value: string(allowAlwaysValue), Label: fmt.Sprintf("Allow All %s", req.ToolName)},
+		},
+	}
 	if similarPattern != "" && similarPattern != alwaysPattern {
 		allowSimilarValue, _ := json.Marshal(PermissionActionValue{
 			ThreadKey: progressKey,
 			Behavior:  "allow",
 			Remember:  similarPattern,
+			Scope:     "task",
+		})
+		row2.Buttons = append(row2.Buttons, chat.Button{
+			ActionID: "permission_allow_similar", Value: string(allowSimilarValue), Label: "Allow Similar",
 		})
-		allowSimilarBtn := slack.NewButtonBlockElement(
-			"permission_allow_similar",
-			string(allowSimilarValue),
-			slack.NewTextBlockObject("plain_text", "Allow Similar", false, false),
-		)
-		actionBlock2 := slack.NewActionBlock("permission_actions_2", allowAlwaysBtn, allowSimilarBtn)
-		blocks = append(blocks, actionBlock2)
-	} else {
-		actionBlock2 := slack.NewActionBlock("permission_actions_2", allowAlwaysBtn)
-		blocks = append(blocks, actionBlock2)
 	}
+	if exactPattern != "" && exactPattern != alwaysPattern && exactPattern != similarPattern {
+		allowExactValue, _ := json.Marshal(PermissionActionValue{
+			ThreadKey: progressKey,
+			Behavior:  "allow",
+			Remember:  exactPattern,
+			Scope:     "task",
+		})
+		row2.Buttons = append(row2.Buttons, chat.Button{
+			ActionID: "permission_allow_exact", Value: string(allowExactValue), Label: "Allow This Exact Call",
+		})
+	}
+	blocks = append(blocks, row2)
+
+	// Third row: session-scoped grants for unattended runs that don't
+	// warrant a standing task rule. Both are scoped to pending.SessionID
+	// (see PolicyRule.Scope), not persisted to disk, and vanish once the
+	// session ends; "Allow for 1h" additionally expires early via TTL.
+	rememberTimeboxed := similarPattern
+	if rememberTimeboxed == "" {
+		rememberTimeboxed = alwaysPattern
+	}
+	allowSessionValue, _ := json.Marshal(PermissionActionValue{
+		ThreadKey: progressKey,
+		Behavior:  "allow",
+		Remember:  rememberTimeboxed,
+		Scope:     "session",
+	})
+	allowHourValue, _ := json.Marshal(PermissionActionValue{
+		ThreadKey: progressKey,
+		Behavior:  "allow",
+		Remember:  rememberTimeboxed,
+		Scope:     "session",
+		TTL:       "1h",
+	})
+	blocks = append(blocks, chat.Actions{
+		BlockID: "permission_actions_3",
+		Buttons: []chat.Button{
+			{ActionID: "permission_allow_session", Value: string(allowSessionValue), Label: "Allow for This Session"},
+			{ActionID: "permission_allow_timeboxed", Value: string(allowHourValue), Label: "Allow for 1h"},
+		},
+	})
 
 	return blocks
 }
 
+// permissionDetailText renders the tool-specific detail line shown in a
+// permission prompt (and, with the decision prefixed, in the updated
+// message once it's resolved).
+func permissionDetailText(toolName string, toolInput map[string]any) string {
+	switch toolName {
+	case "Bash":
+		if cmd, ok := toolInput["command"].(string); ok {
+			// Truncate long commands for display
+			if len(cmd) > 500 {
+				cmd = cmd[:500] + "..."
+			}
+			return fmt.Sprintf("*Command:*\n```%s```", cmd)
+		}
+	case "Write", "Edit":
+		if path, ok := toolInput["file_path"].(string); ok {
+			return fmt.Sprintf("*File:* `%s`", path)
+		}
+	case "Read":
+		if path, ok := toolInput["file_path"].(string); ok {
+			return fmt.Sprintf("*File:* `%s`", path)
+		}
+	case "WebFetch":
+		if url, ok := toolInput["url"].(string); ok {
+			return fmt.Sprintf("*URL:* %s", url)
+		}
+	case "WebSearch":
+		if query, ok := toolInput["query"].(string); ok {
+			return fmt.Sprintf("*Query:* `%s`", query)
+		}
+	default:
+		// Generic display of tool input
+		var parts []string
+		for k, v := range toolInput {
+			parts = append(parts, fmt.Sprintf("*%s:* `%v`", k, v))
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
 // generateSimilarPattern creates a permission pattern for "similar" requests.
 // For example:
 // - Bash: "python script.py" -> "Bash(python:*)"
@@ -796,25 +1469,64 @@ func generateSimilarPattern(toolName string, toolInput map[string]any) string {
 	return ""
 }
 
-// HandleBlockAction processes button click events.
-func (h *Handler) HandleBlockAction(
-	ctx context.Context,
-	callback *slack.InteractionCallback,
-	action *slack.BlockAction,
-) {
+// generateExactPattern creates a permission pattern that matches only this
+// exact tool call, for users who'd rather remember one specific invocation
+// (e.g. one Bash command) than a whole class of them, using the "re:" rule
+// kind matchesPermissionRule understands to anchor a quoted copy of the
+// primary field so nothing else can match it.
+func generateExactPattern(toolName string, toolInput map[string]any) string {
+	field := primaryField(toolName)
+	if field == "" {
+		return ""
+	}
+	value, ok := toolInput[field].(string)
+	if !ok || value == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s(re:^%s$)", toolName, regexp.QuoteMeta(value))
+}
+
+// HandleInteraction processes an interactive component callback (button
+// click), regardless of which ChatTransport it came from.
+func (h *Handler) HandleInteraction(ctx context.Context, evt chat.ChatEvent) {
+	action := evt.Interaction
+	if action == nil {
+		h.logger.Warn().Msg("interaction event missing InteractionEvent payload")
+		return
+	}
+
 	logger := h.logger.With().
 		Str("action_id", action.ActionID).
-		Str("block_id", action.BlockID).
-		Str("user", callback.User.ID).
+		Str("user", action.UserID).
 		Logger()
 
-	logger.Info().Msg("handling block action")
+	logger.Info().Msg("handling interaction")
+
+	// "View Full Diff" doesn't resolve the prompt, just uploads the
+	// complete patch as a snippet, so it's handled separately from the
+	// allow/deny/remember actions below.
+	if action.ActionID == "permission_view_diff" {
+		h.handleViewFullDiff(action, logger)
+		return
+	}
+
+	// Pagination controls on a tool snippet (see snippetPaginationBlock)
+	// don't resolve a permission prompt either; they stream another slice
+	// of the already-posted tool output from the on-disk snippet cache.
+	switch action.ActionID {
+	case snippetActionShowMore, snippetActionHeadTail, snippetActionDownloadRaw:
+		h.handleSnippetAction(action, logger)
+		return
+	}
 
 	// Check if this is a permission action
 	isPermissionAction := action.ActionID == "permission_allow" ||
 		action.ActionID == "permission_deny" ||
 		action.ActionID == "permission_allow_always" ||
-		action.ActionID == "permission_allow_similar"
+		action.ActionID == "permission_allow_similar" ||
+		action.ActionID == "permission_allow_exact" ||
+		action.ActionID == "permission_allow_session" ||
+		action.ActionID == "permission_allow_timeboxed"
 	if !isPermissionAction {
 		logger.Debug().Msg("ignoring non-permission action")
 		return
@@ -833,12 +1545,95 @@ func (h *Handler) HandleBlockAction(
 		Str("remember", actionValue.Remember).
 		Logger()
 
+	h.resolvePermission(resolvePermissionParams{
+		channelID:   action.Channel,
+		messageTS:   action.MessageTS,
+		threadKey:   actionValue.ThreadKey,
+		behavior:    actionValue.Behavior,
+		remember:    actionValue.Remember,
+		scope:       actionValue.Scope,
+		ttl:         actionValue.TTL,
+		userID:      action.UserID,
+		userDisplay: action.UserName,
+		source:      "button click",
+	}, logger)
+}
+
+// handleViewFullDiff responds to the "View Full Diff" button on a truncated
+// diff preview (see diffPreviewBlocks) by recomputing the Write/Edit diff
+// from the still-pending permission request and uploading it as a
+// collapsible snippet, reusing the mechanism postToolSnippet already uses.
+func (h *Handler) handleViewFullDiff(action *chat.InteractionEvent, logger zerolog.Logger) {
+	var actionValue PermissionActionValue
+	if err := json.Unmarshal([]byte(action.Value), &actionValue); err != nil {
+		logger.Error().Err(err).Str("value", action.Value).Msg("failed to decode action value")
+		return
+	}
+
+	pendingVal, ok := h.pendingPermissions.Load(actionValue.ThreadKey)
+	if !ok {
+		logger.Warn().Msg("no pending permission found for view-full-diff")
+		return
+	}
+	pending := pendingVal.(*PendingPermission)
+
+	oldContent, newContent, ok := loadDiffContents(pending.ToolName, pending.ToolInput, pending.TaskPath)
+	if !ok {
+		logger.Warn().Msg("could not recompute diff for view-full-diff")
+		return
+	}
+
+	diff := unifiedDiff(strings.Split(oldContent, "\n"), strings.Split(newContent, "\n"))
+	if diff == "" {
+		diff = "(no changes)"
+	}
+
+	filePath, _ := pending.ToolInput["file_path"].(string)
+	if h.bot.files == nil {
+		return
+	}
+	if _, err := h.bot.files.UploadSnippet(diff, filepath.Base(filePath)+".diff", fmt.Sprintf("Full diff for `%s`", filePath), "diff", action.Channel, pending.ThreadTS); err != nil {
+		logger.Error().Err(err).Msg("failed to upload full diff snippet")
+	}
+}
+
+// resolvePermissionParams bundles a permission decision, however it arrived
+// (button click or reaction), for resolvePermission.
+type resolvePermissionParams struct {
+	channelID string // channel the prompt was posted to, for the stale-prompt notice
+	messageTS string // the prompt message's ts, for the stale-prompt notice
+
+	threadKey   string
+	behavior    string
+	remember    string
+	scope       string
+	ttl         string
+	userID      string
+	userDisplay string // shown in the deny message; falls back to userID if empty
+	source      string // logged, e.g. "button click" or "reaction"
+}
+
+// resolvePermission carries out an allow/deny decision for the pending
+// permission prompt at p.threadKey: it sends the response to Claude via the
+// task's PermissionFIFO, optionally remembers it as a policy/task rule, and
+// updates the prompt message to show the result. Shared by HandleInteraction
+// (button clicks) and HandleReactionAdded (emoji shortcuts), since both
+// resolve the same prompt the same way.
+func (h *Handler) resolvePermission(p resolvePermissionParams, logger zerolog.Logger) {
+	// Remembering a rule (in any scope) is restricted to admins; a plain
+	// allow/deny only affects this request.
+	if p.remember != "" && !h.bot.auth.IsAdmin(p.userID) {
+		logger.Warn().Msg("non-admin tried to remember a permission rule")
+		h.bot.PostMessage(p.channelID, h.bot.auth.RejectMessage("admin"), "")
+		p.remember = ""
+	}
+
 	// Look up the running task
-	taskVal, ok := h.runningTasks.Load(actionValue.ThreadKey)
+	taskVal, ok := h.runningTasks.Load(p.threadKey)
 	if !ok {
 		logger.Warn().Msg("no running task found for permission response")
 		// Update the message to show it's stale
-		h.bot.UpdateMessage(callback.Channel.ID, callback.Message.Timestamp,
+		h.bot.UpdateMessage(p.channelID, p.messageTS,
 			":warning: This permission request is no longer active.")
 		return
 	}
@@ -846,39 +1641,236 @@ func (h *Handler) HandleBlockAction(
 	task := taskVal.(*RunningTask)
 
 	// Check if we were waiting for this permission
-	pendingVal, ok := h.pendingPermissions.Load(actionValue.ThreadKey)
+	pendingVal, ok := h.pendingPermissions.Load(p.threadKey)
+	if !ok {
+		logger.Warn().Msg("no pending permission found")
+		return
+	}
+	pending := pendingVal.(*PendingPermission)
+
+	// A quorum-gated request resolves by tallying votes across multiple
+	// approvers instead of on the first click; hand it off entirely (see
+	// resolveQuorumVote for the short-circuit-deny/threshold-reached
+	// logic it implements in place of the rest of this function).
+	if pending.Quorum != nil {
+		h.resolveQuorumVote(task, pending, p, logger)
+		return
+	}
+
+	userDisplay := p.userDisplay
+	if userDisplay == "" {
+		userDisplay = p.userID
+	}
+
+	// Send the response to Claude via FIFO
+	resp := PermissionResponse{Behavior: p.behavior}
+	if p.behavior == "deny" {
+		resp.Message = fmt.Sprintf("User %s denied permission", userDisplay)
+		metrics.PermissionDenials.WithLabelValues(pending.ToolName).Inc()
+	} else {
+		metrics.PermissionGrants.WithLabelValues(pending.ToolName).Inc()
+	}
+
+	logger.Info().
+		Str("behavior", resp.Behavior).
+		Str("source", p.source).
+		Msg("sending permission response")
+	task.SendPermissionResponse(resp)
+	logger.Info().Msg("permission response sent to FIFO")
+
+	// Add a policy rule if "remember" was selected, scoped and time-boxed
+	// per the button pressed (see buildPermissionBlocks) instead of
+	// forever-allowlisting the pattern in claude.json.
+	if p.remember != "" && p.behavior == "allow" {
+		var ttl time.Duration
+		if p.ttl != "" {
+			parsed, err := time.ParseDuration(p.ttl)
+			if err != nil {
+				logger.Error().Err(err).Str("ttl", p.ttl).Msg("invalid remember TTL, ignoring")
+			} else {
+				ttl = parsed
+			}
+		}
+
+		if h.bot.policy != nil {
+			rule := rememberPatternToPolicyRule(p.remember, p.scope)
+			if _, err := h.bot.policy.AddRule(rule, task.taskPath, pending.SessionID, ttl); err != nil {
+				logger.Error().Err(err).Str("pattern", p.remember).Msg("failed to add policy rule")
+			} else {
+				logger.Info().
+					Str("pattern", p.remember).
+					Str("scope", p.scope).
+					Str("ttl", p.ttl).
+					Msg("added policy rule from remember button")
+			}
+		}
+
+		// Task-scoped rules have no TTL and are meant to survive restarts
+		// (unlike session-scoped ones, which are deliberately ephemeral),
+		// so also persist them to claude.json via the task's
+		// PermissionRuleStore, independent of whether the policy engine is
+		// configured.
+		if p.scope == "task" {
+			if err := h.ruleStore(task.taskPath).AddRule(p.remember); err != nil {
+				logger.Error().Err(err).Str("pattern", p.remember).Msg("failed to persist permission rule")
+			}
+
+			// Also record it as a structured, revokable rule (see the
+			// "@bot permissions" meta-commands), rather than only an
+			// opaque allowedTools string.
+			tool, pattern, deny := splitRememberPattern(p.remember)
+			if _, err := h.permissionStore(task.taskPath).AddRule(tool, pattern, p.scope, p.userID, ttl, 0, deny); err != nil {
+				logger.Error().Err(err).Str("pattern", p.remember).Msg("failed to record structured permission rule")
+			}
+		}
+	}
+
+	// Clear pending state
+	h.deletePendingPermission(p.threadKey)
+	h.seenToolUseIDs.Delete(pending.ToolUseID)
+
+	// Update the permission message to show it was handled
+	h.updatePermissionMessage(pending, p.behavior, p.userID, p.remember)
+}
+
+// quorumTallyBlock renders the current vote count for a quorum-gated
+// permission prompt (see buildPermissionBlocks, which this is prepended to).
+func quorumTallyBlock(v *QuorumVote) chat.Block {
+	v.mu.Lock()
+	allowed := 0
+	for _, vote := range v.Votes {
+		if vote == "allow" {
+			allowed++
+		}
+	}
+	v.mu.Unlock()
+
+	return chat.Section{Text: chat.Text{Markdown: fmt.Sprintf(
+		":ballot_box: *Requires %d/%d approvals from `%s`*",
+		allowed, v.Rule.Required, v.Rule.Role,
+	)}}
+}
+
+// resolveQuorumVote records one approver's vote on a quorum-gated permission
+// request (see QuorumPolicy) and only resolves it — sending the response to
+// Claude and updating the Slack message — once a single Deny vote or
+// pending.Quorum.Rule.Required Allow votes have been cast. It replaces the
+// rest of resolvePermission's logic for quorum-gated requests: "remember"
+// isn't honored here, since a rule gated behind multiple approvers isn't
+// meant to be shortcut by a single click in the future.
+//
+// vote.Tally's shouldResolve, in turn backed by vote.resolved, ensures only
+// the single vote that actually crosses the threshold (or casts the deny)
+// proceeds past this point: without it, votes arriving in quick succession
+// past the threshold (e.g. a third Allow on a Required:2 rule) would each
+// recompute reached as true and re-run the resolution — a second
+// SendPermissionResponse write into the same PermissionFIFO that then sits
+// unconsumed until it's matched against some future, unrelated permission
+// prompt in the session.
+func (h *Handler) resolveQuorumVote(task *RunningTask, pending *PendingPermission, p resolvePermissionParams, logger zerolog.Logger) {
+	vote := pending.Quorum
+
+	if !h.bot.auth.IsAuthorized(p.userID, vote.Rule.Role) {
+		logger.Warn().Str("role", vote.Rule.Role).Msg("user is not an approver for this quorum rule")
+		h.bot.PostMessage(p.channelID, h.bot.auth.RejectMessage(vote.Rule.Role), "")
+		return
+	}
+
+	allowed, denied, reached, shouldResolve := vote.Tally(p.userID, p.behavior)
+
+	if !denied && !reached {
+		logger.Info().
+			Int("votes", allowed).
+			Int("required", vote.Rule.Required).
+			Msg("recorded quorum vote, still waiting for more approvals")
+		h.updateQuorumMessage(pending, p.threadKey)
+		return
+	}
+
+	if !shouldResolve {
+		// Quorum was already reached (or already denied) by another vote;
+		// this one is redundant and must not resolve the request a second
+		// time.
+		logger.Info().Msg("quorum already resolved by another vote, ignoring")
+		return
+	}
+
+	if vote.timeoutTimer != nil {
+		vote.timeoutTimer.Stop()
+	}
+
+	userDisplay := p.userDisplay
+	if userDisplay == "" {
+		userDisplay = p.userID
+	}
+
+	behavior := "allow"
+	resp := PermissionResponse{Behavior: "allow"}
+	if denied {
+		behavior = "deny"
+		resp.Behavior = "deny"
+		resp.Message = fmt.Sprintf("User %s denied permission", userDisplay)
+		metrics.PermissionDenials.WithLabelValues(pending.ToolName).Inc()
+	} else {
+		metrics.PermissionGrants.WithLabelValues(pending.ToolName).Inc()
+	}
+
+	logger.Info().
+		Str("behavior", behavior).
+		Str("source", p.source).
+		Msg("quorum resolved, sending permission response")
+	task.SendPermissionResponse(resp)
+
+	h.deletePendingPermission(p.threadKey)
+	h.seenToolUseIDs.Delete(pending.ToolUseID)
+	h.updatePermissionMessage(pending, behavior, p.userID, "")
+}
+
+// updateQuorumMessage refreshes a quorum-gated prompt to show the current
+// vote tally without resolving it, by rebuilding the same blocks
+// buildPermissionBlocks produced when the prompt was first posted.
+func (h *Handler) updateQuorumMessage(pending *PendingPermission, progressKey string) {
+	req := PermissionRequest{
+		ToolName:  pending.ToolName,
+		ToolInput: pending.ToolInput,
+		SessionID: pending.SessionID,
+		ToolUseID: pending.ToolUseID,
+	}
+	blocks := h.buildPermissionBlocks(req, progressKey, pending.TaskPath)
+	blocks = append([]chat.Block{quorumTallyBlock(pending.Quorum)}, blocks...)
+
+	if err := h.bot.UpdateMessageBlocks(pending.ChannelID, pending.MessageTS, blocks); err != nil {
+		h.logger.Error().Err(err).Msg("failed to update quorum permission message")
+	}
+}
+
+// quorumTimeoutExpired auto-denies a quorum-gated permission request that
+// didn't reach its rule's Required approvals within its Timeout, and tells
+// the thread why. A no-op if the request was already resolved first.
+func (h *Handler) quorumTimeoutExpired(progressKey string) {
+	pendingVal, ok := h.pendingPermissions.Load(progressKey)
 	if !ok {
-		logger.Warn().Msg("no pending permission found")
 		return
 	}
 	pending := pendingVal.(*PendingPermission)
 
-	// Send the response to Claude via FIFO
-	resp := PermissionResponse{Behavior: actionValue.Behavior}
-	if actionValue.Behavior == "deny" {
-		resp.Message = fmt.Sprintf("User %s denied permission", callback.User.Name)
-	}
-
-	logger.Info().
-		Str("behavior", resp.Behavior).
-		Msg("sending permission response from button click")
-	task.SendPermissionResponse(resp)
-	logger.Info().Msg("permission response sent to FIFO")
-
-	// Save the permission pattern if "remember" was selected
-	if actionValue.Remember != "" && actionValue.Behavior == "allow" {
-		if err := h.bot.savePermissionRule(task.taskPath, actionValue.Remember); err != nil {
-			logger.Error().Err(err).Str("pattern", actionValue.Remember).Msg("failed to save permission rule")
-		} else {
-			logger.Info().Str("pattern", actionValue.Remember).Msg("saved permission rule")
-		}
+	taskVal, ok := h.runningTasks.Load(progressKey)
+	if !ok {
+		return
 	}
+	task := taskVal.(*RunningTask)
 
-	// Clear pending state
-	h.pendingPermissions.Delete(actionValue.ThreadKey)
+	h.logger.Info().Str("tool_name", pending.ToolName).Msg("quorum approval timed out, denying")
+	metrics.PermissionDenials.WithLabelValues(pending.ToolName).Inc()
+	task.SendPermissionResponse(PermissionResponse{
+		Behavior: "deny",
+		Message:  "Denied: quorum approval timed out",
+	})
 
-	// Update the permission message to show it was handled
-	h.updatePermissionMessage(pending, actionValue.Behavior, callback.User.ID, actionValue.Remember)
+	h.deletePendingPermission(progressKey)
+	h.seenToolUseIDs.Delete(pending.ToolUseID)
+	h.updatePermissionMessage(pending, "deny", "", "")
+	h.bot.PostMessage(pending.ChannelID, ":alarm_clock: Quorum approval timed out; request denied.", pending.ThreadTS)
 }
 
 // updatePermissionMessage updates a permission prompt message to show the result.
@@ -893,64 +1885,40 @@ func (h *Handler) updatePermissionMessage(perm *PendingPermission, behavior, use
 	}
 
 	// Build updated blocks showing the decision with tool details preserved
-	blocks := []slack.Block{}
-
-	// Result header (includes remembered pattern if set)
+	blocks := []chat.Block{}
+
+	// Result header (includes remembered pattern if set). An empty userID
+	// means the decision wasn't made by a specific person (e.g. a quorum
+	// approval timeout), so it's worded impersonally instead of "by <@>".
+	who := fmt.Sprintf("by <@%s>", userID)
+	if userID == "" {
+		who = "automatically"
+	}
 	var headerStr string
 	if remembered != "" {
-		headerStr = fmt.Sprintf("%s *%s* by <@%s>\n:brain: Remembered: `%s`", emoji, action, userID, remembered)
+		headerStr = fmt.Sprintf("%s *%s* %s\n:brain: Remembered: `%s`", emoji, action, who, remembered)
 	} else {
-		headerStr = fmt.Sprintf("%s *%s* by <@%s>", emoji, action, userID)
+		headerStr = fmt.Sprintf("%s *%s* %s", emoji, action, who)
 	}
-	headerText := slack.NewTextBlockObject("mrkdwn", headerStr, false, false)
-	blocks = append(blocks, slack.NewSectionBlock(headerText, nil, nil))
+	blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: headerStr}})
 
 	// Tool name
-	toolText := slack.NewTextBlockObject(
-		"mrkdwn",
-		fmt.Sprintf("*Tool:* `%s`", perm.ToolName),
-		false,
-		false,
-	)
-	blocks = append(blocks, slack.NewSectionBlock(toolText, nil, nil))
+	blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: fmt.Sprintf("*Tool:* `%s`", perm.ToolName)}})
 
 	// Tool-specific details (same logic as buildPermissionBlocks)
-	var detailText string
-	switch perm.ToolName {
-	case "Bash":
-		if cmd, ok := perm.ToolInput["command"].(string); ok {
-			if len(cmd) > 500 {
-				cmd = cmd[:500] + "..."
-			}
-			detailText = fmt.Sprintf("*Command:*\n```%s```", cmd)
-		}
-	case "Write", "Edit":
-		if path, ok := perm.ToolInput["file_path"].(string); ok {
-			detailText = fmt.Sprintf("*File:* `%s`", path)
-		}
-	case "Read":
-		if path, ok := perm.ToolInput["file_path"].(string); ok {
-			detailText = fmt.Sprintf("*File:* `%s`", path)
-		}
-	case "WebFetch":
-		if url, ok := perm.ToolInput["url"].(string); ok {
-			detailText = fmt.Sprintf("*URL:* %s", url)
-		}
-	case "WebSearch":
-		if query, ok := perm.ToolInput["query"].(string); ok {
-			detailText = fmt.Sprintf("*Query:* `%s`", query)
-		}
-	default:
-		var parts []string
-		for k, v := range perm.ToolInput {
-			parts = append(parts, fmt.Sprintf("*%s:* `%v`", k, v))
-		}
-		detailText = strings.Join(parts, "\n")
+	if detailText := permissionDetailText(perm.ToolName, perm.ToolInput); detailText != "" {
+		blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: detailText}})
 	}
 
-	if detailText != "" {
-		detailBlock := slack.NewTextBlockObject("mrkdwn", detailText, false, false)
-		blocks = append(blocks, slack.NewSectionBlock(detailBlock, nil, nil))
+	// Diff/hazard context (same logic as buildPermissionBlocks, minus the
+	// "View Full Diff" button: by this point resolvePermission has already
+	// deleted the pending permission the button's progressKey would resolve
+	// to).
+	if text, _, ok := diffPreviewText(perm.ToolName, perm.ToolInput, perm.TaskPath); ok {
+		blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: text}})
+	}
+	if hazardBlock, ok := bashHazardBlock(perm.ToolName, perm.ToolInput); ok {
+		blocks = append(blocks, hazardBlock)
 	}
 
 	if err := h.bot.UpdateMessageBlocks(perm.ChannelID, perm.MessageTS, blocks); err != nil {
@@ -972,103 +1940,580 @@ func parsePermissionResponse(text string) *PermissionResponse {
 	}
 }
 
-// isPermissionAllowed checks if a permission request matches saved allowed rules.
-// This enables "remember" to take effect immediately within the same session.
+// isPermissionAllowed checks if a permission request matches saved allowed
+// rules, either in the legacy claude.json allowedTools list or the
+// structured PermissionStore (see "@bot permissions" meta-commands and the
+// "Allow Always"/"Allow Similar" actions). This enables "remember" to take
+// effect immediately within the same session.
 func (h *Handler) isPermissionAllowed(taskPath, toolName string, toolInput map[string]any) bool {
-	configPath := filepath.Join(taskPath, ".clod", "claude", "claude.json")
+	legacyRules, err := h.ruleStore(taskPath).ListRules()
+	if err != nil {
+		h.logger.Error().Err(err).Str("task_path", taskPath).Msg("failed to read permission rule store")
+		legacyRules = nil
+	}
 
-	data, err := os.ReadFile(configPath)
+	structuredRules, err := h.permissionStore(taskPath).ListRules()
 	if err != nil {
-		return false
+		h.logger.Error().Err(err).Str("task_path", taskPath).Msg("failed to read permission store")
+		structuredRules = nil
+	}
+
+	// Deny rules are checked before any allow rule, wherever they're
+	// stored, so a narrower deny (e.g. "!Bash(rm *)") can carve an
+	// exception out of a broader allow (e.g. "Bash(re:.*)").
+	for _, rule := range legacyRules {
+		if isDenyPattern(rule) && matchesPermissionRule(rule, toolName, toolInput, taskPath) {
+			return false
+		}
+	}
+	for _, r := range structuredRules {
+		if r.Deny && r.matches(toolName, toolInput, taskPath) {
+			return false
+		}
+	}
+
+	for _, rule := range legacyRules {
+		if !isDenyPattern(rule) && matchesPermissionRule(rule, toolName, toolInput, taskPath) {
+			return true
+		}
+	}
+	for _, r := range structuredRules {
+		if r.Deny || r.exhausted() || !r.matches(toolName, toolInput, taskPath) {
+			continue
+		}
+		if r.MaxUses > 0 {
+			if err := h.permissionStore(taskPath).RecordUse(r.ID); err != nil {
+				h.logger.Error().Err(err).Str("rule_id", r.ID).Msg("failed to record permission rule use")
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// isDenyPattern reports whether a legacy (claude.json allowedTools-style)
+// rule string is a "!"-prefixed deny rule rather than an allow rule.
+func isDenyPattern(rule string) bool {
+	return strings.HasPrefix(rule, "!")
+}
+
+// primaryField names the ToolInput field generateSimilarPattern and
+// generateExactPattern key off of for each built-in tool; "" for tools with
+// no single obvious field to suggest a pattern from.
+func primaryField(toolName string) string {
+	switch toolName {
+	case "Bash":
+		return "command"
+	case "Write", "Edit", "Read":
+		return "file_path"
+	case "WebFetch":
+		return "url"
+	case "WebSearch":
+		return "query"
+	default:
+		return ""
+	}
+}
+
+// matchesPermissionRule checks if a tool request matches a permission rule.
+// Rules are "ToolName" (matches any use of that tool) or "ToolName(pattern)",
+// where pattern is one of:
+//   - the legacy "prefix:*" shorthand (argv[0] prefix for Bash, directory
+//     prefix for file tools) or "dir/**" shorthand, kept so rules already
+//     saved to disk by earlier versions keep working
+//   - "re:<regexp>", matched with regexp.MatchString against toolName's
+//     primary field (see scopedFieldValues), e.g. "command" for Bash
+//     (e.g. "Bash(re:^kubectl (get|describe))")
+//   - "under:<dir>", matching a Read/Write/Edit file_path that resolves,
+//     relative to taskPath, under dir (e.g. "Read(under:./src)")
+//   - a shell-style glob (see globMatch), tested against toolName's
+//     primary field (e.g. "Bash(git *)", "Write(**/*.go)")
+//
+// A rule may be prefixed with "!" (e.g. "!Bash(rm *)") to mark it a deny
+// rule; see isPermissionAllowed, which checks deny rules before allow rules.
+func matchesPermissionRule(rule, toolName string, toolInput map[string]any, taskPath string) bool {
+	rule = strings.TrimPrefix(rule, "!")
+
+	// Exact tool match (e.g., "WebSearch" matches any WebSearch)
+	if rule == toolName {
+		return true
 	}
 
-	var config map[string]any
-	if err := json.Unmarshal(data, &config); err != nil {
+	if !strings.HasPrefix(rule, toolName+"(") || !strings.HasSuffix(rule, ")") {
 		return false
 	}
+	pattern := rule[len(toolName)+1 : len(rule)-1] // Extract "python:*" from "Bash(python:*)"
+
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		return matchesFieldRegex(toolName, toolInput, strings.TrimPrefix(pattern, "re:"))
+	case strings.HasPrefix(pattern, "under:"):
+		return matchesUnderScope(toolInput, taskPath, strings.TrimPrefix(pattern, "under:"))
+	case strings.HasSuffix(pattern, ":*"):
+		return matchesLegacyPrefix(toolName, toolInput, strings.TrimSuffix(pattern, ":*"))
+	case strings.HasSuffix(pattern, "**"):
+		return matchesLegacyGlobDir(toolName, toolInput, strings.TrimSuffix(pattern, "**"))
+	default:
+		return matchesFieldGlob(toolName, toolInput, pattern)
+	}
+}
+
+// matchesLegacyPrefix implements the original "prefix:*" shorthand: an
+// argv[0] prefix for Bash, a directory-name prefix for file tools.
+func matchesLegacyPrefix(toolName string, toolInput map[string]any, prefix string) bool {
+	if toolName == "Bash" {
+		if cmd, ok := toolInput["command"].(string); ok {
+			parts := strings.Fields(cmd)
+			if len(parts) > 0 && parts[0] == prefix {
+				return true
+			}
+		}
+	}
+
+	if toolName == "Write" || toolName == "Edit" || toolName == "Read" {
+		if path, ok := toolInput["file_path"].(string); ok {
+			if strings.Contains(path, "/"+prefix+"/") || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
 
-	projects, ok := config["projects"].(map[string]any)
+// matchesLegacyGlobDir implements the original "dir/**" shorthand: any path
+// under dirPrefix, anywhere in a file tool's file_path.
+func matchesLegacyGlobDir(toolName string, toolInput map[string]any, dirPrefix string) bool {
+	if toolName != "Write" && toolName != "Edit" && toolName != "Read" {
+		return false
+	}
+	path, ok := toolInput["file_path"].(string)
 	if !ok {
 		return false
 	}
+	return strings.Contains(path, "/"+dirPrefix) || strings.HasPrefix(path, dirPrefix)
+}
+
+// fieldValues returns every string-valued entry of toolInput. It's the
+// fallback scopedFieldValues uses for tools with no primaryField, since
+// they have no single obvious field to scope a pattern to.
+func fieldValues(toolInput map[string]any) []string {
+	values := make([]string, 0, len(toolInput))
+	for _, v := range toolInput {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
 
-	project, ok := projects[taskPath].(map[string]any)
+// scopedFieldValues returns the value "re:"/glob rule patterns are matched
+// against for toolName: just its primaryField (e.g. "command" for Bash),
+// not every string field of toolInput. A Bash tool call also carries an
+// LLM-generated "description" field, and matching against every field would
+// let a prompt-injected description satisfy a rule meant to scope the
+// actual command (e.g. "Bash(re:^git (status|log))" must not also match
+// because description happens to say "git status" while command runs
+// something unrelated). Tools with no primaryField have no single field to
+// scope to, so they fall back to scanning every string-valued field.
+func scopedFieldValues(toolName string, toolInput map[string]any) []string {
+	field := primaryField(toolName)
+	if field == "" {
+		return fieldValues(toolInput)
+	}
+	s, ok := toolInput[field].(string)
 	if !ok {
+		return nil
+	}
+	return []string{s}
+}
+
+// matchesFieldRegex reports whether pattern, compiled as a regexp, matches
+// toolName's primary field (see scopedFieldValues). An invalid regexp never
+// matches.
+func matchesFieldRegex(toolName string, toolInput map[string]any, pattern string) bool {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
 		return false
 	}
+	for _, v := range scopedFieldValues(toolName, toolInput) {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFieldGlob reports whether pattern, as a shell-style glob (see
+// globMatch), matches toolName's primary field (see scopedFieldValues).
+func matchesFieldGlob(toolName string, toolInput map[string]any, pattern string) bool {
+	for _, v := range scopedFieldValues(toolName, toolInput) {
+		if globMatch(pattern, v) {
+			return true
+		}
+	}
+	return false
+}
 
-	allowedTools, ok := project["allowedTools"].([]any)
+// matchesUnderScope reports whether toolInput's file_path falls under dir,
+// with both resolved relative to taskPath so "Read(under:./src)" means
+// "src" within the task's own directory rather than wherever the bot
+// process happens to be running from.
+func matchesUnderScope(toolInput map[string]any, taskPath, dir string) bool {
+	path, ok := toolInput["file_path"].(string)
 	if !ok {
 		return false
 	}
 
-	for _, rule := range allowedTools {
-		ruleStr, ok := rule.(string)
-		if !ok {
-			continue
+	path = resolveUnderTaskPath(taskPath, path)
+	dir = resolveUnderTaskPath(taskPath, dir)
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// resolveUnderTaskPath resolves path relative to taskPath, unless it's
+// already absolute.
+func resolveUnderTaskPath(taskPath, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Clean(filepath.Join(taskPath, path))
+}
+
+// globMatch reports whether s matches a shell-style wildcard pattern: "*"
+// matches any run of characters, including "/" (so "**" behaves the same
+// as a single "*" here — unlike filepath.Match, there's no path-separator
+// boundary), and "?" matches any single character. Used by
+// matchesPermissionRule for rules that aren't one of the fixed legacy
+// shorthands or a "re:"/"under:" rule.
+func globMatch(pattern, s string) bool {
+	var pIdx, sIdx, starIdx, matchIdx int
+	starIdx = -1
+
+	for sIdx < len(s) {
+		switch {
+		case pIdx < len(pattern) && (pattern[pIdx] == '?' || pattern[pIdx] == s[sIdx]):
+			pIdx++
+			sIdx++
+		case pIdx < len(pattern) && pattern[pIdx] == '*':
+			starIdx = pIdx
+			matchIdx = sIdx
+			pIdx++
+		case starIdx != -1:
+			pIdx = starIdx + 1
+			matchIdx++
+			sIdx = matchIdx
+		default:
+			return false
 		}
+	}
 
-		if matchesPermissionRule(ruleStr, toolName, toolInput) {
-			return true
+	for pIdx < len(pattern) && pattern[pIdx] == '*' {
+		pIdx++
+	}
+
+	return pIdx == len(pattern)
+}
+
+// rememberPatternToPolicyRule converts a "remember" pattern in the legacy
+// claude.json allowedTools syntax (e.g. "Bash", "Bash(python:*)",
+// "Write(src/**)", as produced by generateSimilarPattern) into an "allow"
+// PolicyRule, so the "remember" buttons can keep using the same compact
+// pattern text while being backed by the policy engine.
+func rememberPatternToPolicyRule(pattern, scope string) PolicyRule {
+	rule := PolicyRule{Tool: pattern, Effect: "allow", Scope: scope}
+
+	open := strings.Index(pattern, "(")
+	if open == -1 || !strings.HasSuffix(pattern, ")") {
+		return rule
+	}
+
+	tool := pattern[:open]
+	inner := pattern[open+1 : len(pattern)-1]
+	rule.Tool = tool
+
+	switch {
+	case strings.HasPrefix(inner, "re:"):
+		re := strings.TrimPrefix(inner, "re:")
+		if tool == "Bash" {
+			rule.Match.CommandRegex = re
+		} else {
+			rule.Match.FileRegex = re
+		}
+	case strings.HasPrefix(inner, "under:"):
+		dir := regexp.QuoteMeta(strings.TrimPrefix(inner, "under:"))
+		rule.Match.FileRegex = "(^|/)" + dir + "(/|$)"
+	case strings.HasSuffix(inner, ":*"):
+		prefix := regexp.QuoteMeta(strings.TrimSuffix(inner, ":*"))
+		if tool == "Bash" {
+			rule.Match.CommandRegex = "^" + prefix + "(\\s|$)"
+		} else {
+			rule.Match.FileRegex = "(^|/)" + prefix + "/"
+		}
+	case strings.HasSuffix(inner, "**"):
+		dirPrefix := regexp.QuoteMeta(strings.TrimSuffix(inner, "**"))
+		rule.Match.FileRegex = "(^|/)" + dirPrefix
+	default:
+		// A plain glob or an exact-match rule doesn't map onto
+		// PolicyEngine's regex-only Match fields; translate it to an
+		// anchored regex so "remember" still takes effect immediately via
+		// the policy engine (isPermissionAllowed and the
+		// PermissionStore/PermissionRuleStore cover it more precisely once
+		// persisted to disk).
+		re := globToRegex(inner)
+		if tool == "Bash" {
+			rule.Match.CommandRegex = re
+		} else {
+			rule.Match.FileRegex = re
 		}
 	}
 
-	return false
+	return rule
 }
 
-// matchesPermissionRule checks if a tool request matches a permission rule.
-// Rules can be:
-//   - "ToolName" - matches all uses of that tool
-//   - "ToolName(pattern:*)" - matches tool with pattern prefix (e.g., "Bash(python:*)")
-func matchesPermissionRule(rule, toolName string, toolInput map[string]any) bool {
-	// Exact tool match (e.g., "WebSearch" matches any WebSearch)
-	if rule == toolName {
-		return true
+// globToRegex translates a globMatch-style pattern ("*" and "?" wildcards)
+// into an equivalent anchored regexp, for rememberPatternToPolicyRule's
+// fallback case.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// resolveTaskPathForThread finds the task directory a thread is associated
+// with, whether it currently has a running task or only a saved session, so
+// "@bot permissions ..." meta-commands can be used both mid-run and once a
+// task has finished.
+func (h *Handler) resolveTaskPathForThread(channelID, threadTS string) (string, bool) {
+	progressKey := key(channelID, threadTS)
+	if taskVal, ok := h.runningTasks.Load(progressKey); ok {
+		return taskVal.(*RunningTask).taskPath, true
 	}
+	if session := h.bot.sessions.Get(channelID, threadTS); session != nil {
+		return session.TaskPath, true
+	}
+	return "", false
+}
 
-	// Pattern match (e.g., "Bash(python:*)" matches "python3 -m venv venv")
-	if strings.HasPrefix(rule, toolName+"(") && strings.HasSuffix(rule, ")") {
-		pattern := rule[len(toolName)+1 : len(rule)-1] // Extract "python:*" from "Bash(python:*)"
+// handleRulesSlashCommand implements the "/clod rules <task> list|revoke
+// [arg]" slash command: an operator-facing way to audit and cull a task's
+// remembered permission rules without needing a thread already associated
+// with that task (unlike "@bot permissions ...", see
+// handlePermissionsCommand).
+func (h *Handler) handleRulesSlashCommand(ev chat.ChatEvent, text string, logger zerolog.Logger) {
+	cmd := ParseRulesCommand(text)
+	if cmd == nil {
+		h.bot.PostEphemeral(ev.Channel, ev.User, "Usage: `/clod rules <task> list`, `/clod rules <task> revoke <id>`, or `/clod rules <task> revoke all`")
+		return
+	}
 
-		// Parse the pattern.
-		if strings.HasSuffix(pattern, ":*") {
-			prefix := strings.TrimSuffix(pattern, ":*")
+	taskPath, err := h.bot.Tasks().Get(cmd.TaskName)
+	if err != nil {
+		h.bot.PostEphemeral(ev.Channel, ev.User, fmt.Sprintf("Unknown task: `%s`\n\n%s", cmd.TaskName, h.bot.Tasks().ListFormatted()))
+		return
+	}
 
-			// For Bash, check command prefix.
-			if toolName == "Bash" {
-				if cmd, ok := toolInput["command"].(string); ok {
-					parts := strings.Fields(cmd)
-					if len(parts) > 0 && parts[0] == prefix {
-						return true
-					}
-				}
-			}
+	logger = logger.With().
+		Str("rules_task", cmd.TaskName).
+		Str("rules_action", cmd.Action).
+		Str("task_path", taskPath).
+		Logger()
 
-			// For file operations, check path prefix.
-			if toolName == "Write" || toolName == "Edit" || toolName == "Read" {
-				if path, ok := toolInput["file_path"].(string); ok {
-					// Check if path is under the specified directory.
-					if strings.Contains(path, "/"+prefix+"/") || strings.HasPrefix(path, prefix+"/") {
-						return true
-					}
-				}
-			}
+	store := h.permissionStore(taskPath)
+
+	switch cmd.Action {
+	case "list":
+		rules, err := store.ListRules()
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to list permission rules")
+			h.bot.PostEphemeral(ev.Channel, ev.User, fmt.Sprintf(":x: Failed to list permission rules: %v", err))
+			return
 		}
+		h.postPermissionRulesList(ev.Channel, "", rules)
 
-		// Glob pattern (e.g., "Write(src/**)")
-		if strings.HasSuffix(pattern, "**") {
-			dirPrefix := strings.TrimSuffix(pattern, "**")
-			if toolName == "Write" || toolName == "Edit" || toolName == "Read" {
-				if path, ok := toolInput["file_path"].(string); ok {
-					if strings.Contains(path, "/"+dirPrefix) || strings.HasPrefix(path, dirPrefix) {
-						return true
-					}
-				}
+	case "revoke":
+		if cmd.Arg == "" {
+			h.bot.PostEphemeral(ev.Channel, ev.User, fmt.Sprintf("Usage: `/clod rules %s revoke <id>` or `/clod rules %s revoke all`", cmd.TaskName, cmd.TaskName))
+			return
+		}
+
+		if strings.EqualFold(cmd.Arg, "all") {
+			if err := store.Clear(); err != nil {
+				logger.Error().Err(err).Msg("failed to clear permission rules")
+				h.bot.PostMessage(ev.Channel, fmt.Sprintf(":x: Failed to clear permission rules: %v", err), "")
+				return
 			}
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":white_check_mark: Cleared all remembered permission rules for `%s`.", cmd.TaskName), "")
+			return
+		}
+
+		removed, err := store.RevokeRule(cmd.Arg)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to revoke permission rule")
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":x: Failed to revoke rule: %v", err), "")
+			return
+		}
+		if !removed {
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":warning: No permission rule with ID `%s` for `%s`.", cmd.Arg, cmd.TaskName), "")
+			return
+		}
+		h.bot.PostMessage(ev.Channel, fmt.Sprintf(":white_check_mark: Revoked rule `%s` for `%s`.", cmd.Arg, cmd.TaskName), "")
+	}
+}
+
+// handlePermissionsCommand implements the "@bot permissions list/revoke/add/clear"
+// meta-commands against the thread's task PermissionStore, replying with
+// Slack blocks rather than a prompt/button round-trip.
+func (h *Handler) handlePermissionsCommand(ev chat.ChatEvent, threadTS string, cmd *PermissionsCommand, logger zerolog.Logger) {
+	taskPath, ok := h.resolveTaskPathForThread(ev.Channel, threadTS)
+	if !ok {
+		h.bot.PostMessage(ev.Channel, ":warning: No task associated with this thread to manage permissions for.", threadTS)
+		return
+	}
+	store := h.permissionStore(taskPath)
+
+	logger = logger.With().Str("permissions_action", cmd.Action).Str("task_path", taskPath).Logger()
+
+	switch cmd.Action {
+	case "list":
+		rules, err := store.ListRules()
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to list permission rules")
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":x: Failed to list permission rules: %v", err), threadTS)
+			return
+		}
+		h.postPermissionRulesList(ev.Channel, threadTS, rules)
+
+	case "revoke":
+		if cmd.Arg == "" {
+			h.bot.PostMessage(ev.Channel, "Usage: `@bot permissions revoke <id>`", threadTS)
+			return
+		}
+		removed, err := store.RevokeRule(cmd.Arg)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to revoke permission rule")
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":x: Failed to revoke rule: %v", err), threadTS)
+			return
+		}
+		if !removed {
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":warning: No permission rule with ID `%s`.", cmd.Arg), threadTS)
+			return
 		}
+		h.bot.PostMessage(ev.Channel, fmt.Sprintf(":white_check_mark: Revoked rule `%s`.", cmd.Arg), threadTS)
+
+	case "add":
+		if cmd.Arg == "" {
+			h.bot.PostMessage(ev.Channel, "Usage: `@bot permissions add Bash(git:*) [max:N]` (prefix with `!` to deny, e.g. `!Bash(rm *)`)", threadTS)
+			return
+		}
+		patternArg, maxUses := splitMaxUsesSuffix(cmd.Arg)
+		tool, pattern, deny := splitRememberPattern(patternArg)
+		rule, err := store.AddRule(tool, pattern, "task", ev.User, 0, maxUses, deny)
+		if err != nil {
+			logger.Error().Err(err).Msg("failed to add permission rule")
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":x: Failed to add rule: %v", err), threadTS)
+			return
+		}
+		h.bot.PostMessage(ev.Channel, fmt.Sprintf(":white_check_mark: Added rule `%s`: `%s`", rule.ID, patternArg), threadTS)
+
+	case "clear":
+		if err := store.Clear(); err != nil {
+			logger.Error().Err(err).Msg("failed to clear permission rules")
+			h.bot.PostMessage(ev.Channel, fmt.Sprintf(":x: Failed to clear permission rules: %v", err), threadTS)
+			return
+		}
+		h.bot.PostMessage(ev.Channel, ":white_check_mark: Cleared all remembered permission rules for this task.", threadTS)
 	}
+}
 
-	return false
+// postPermissionRulesList posts the current PermissionStore rules as Slack
+// blocks, one line per rule, for "@bot permissions list".
+func (h *Handler) postPermissionRulesList(channelID, threadTS string, rules []PermissionRule) {
+	if len(rules) == 0 {
+		h.bot.PostMessage(channelID, "No remembered permission rules for this task.", threadTS)
+		return
+	}
+
+	blocks := []chat.Block{
+		chat.Section{Text: chat.Text{Markdown: ":lock: *Remembered Permission Rules*"}},
+	}
+	for _, r := range rules {
+		pattern := r.Tool
+		if r.Pattern != "" {
+			pattern = fmt.Sprintf("%s(%s)", r.Tool, r.Pattern)
+		}
+		if r.Deny {
+			pattern = "!" + pattern
+		}
+		line := fmt.Sprintf("`%s` `%s` — scope: %s, by <@%s>", r.ID, pattern, r.Scope, r.CreatedBy)
+		if !r.ExpiresAt.IsZero() {
+			line += fmt.Sprintf(", expires %s", r.ExpiresAt.Format(time.RFC3339))
+		}
+		if r.MaxUses > 0 {
+			line += fmt.Sprintf(", used %d/%d", r.Uses, r.MaxUses)
+		}
+		blocks = append(blocks, chat.Section{Text: chat.Text{Markdown: line}})
+	}
+
+	if _, err := h.bot.PostMessageBlocks(channelID, blocks, threadTS); err != nil {
+		h.logger.Error().Err(err).Msg("failed to post permission rules list")
+	}
+}
+
+// splitRememberPattern splits a "remember" pattern in the legacy
+// claude.json allowedTools syntax (as produced by generateSimilarPattern or
+// generateExactPattern, or typed in by hand to "@bot permissions add") into
+// its tool name, inner pattern, and whether it's a "!"-prefixed deny rule,
+// e.g. "Bash(python:*)" -> ("Bash", "python:*", false); "!Bash(rm *)" ->
+// ("Bash", "rm *", true); "Bash" -> ("Bash", "", false).
+func splitRememberPattern(pattern string) (tool, glob string, deny bool) {
+	deny = isDenyPattern(pattern)
+	pattern = strings.TrimPrefix(pattern, "!")
+
+	open := strings.Index(pattern, "(")
+	if open == -1 || !strings.HasSuffix(pattern, ")") {
+		return pattern, "", deny
+	}
+	return pattern[:open], pattern[open+1 : len(pattern)-1], deny
+}
+
+// splitMaxUsesSuffix splits a trailing " max:N" token off of a "@bot
+// permissions add" argument, e.g. "Bash(git:*) max:5" -> ("Bash(git:*)", 5);
+// "Bash(git:*)" -> ("Bash(git:*)", 0). A malformed or missing "max:" suffix
+// is left in place and reported as maxUses 0 (unlimited).
+func splitMaxUsesSuffix(arg string) (pattern string, maxUses int) {
+	fields := strings.Fields(arg)
+	if len(fields) < 2 {
+		return arg, 0
+	}
+
+	last := fields[len(fields)-1]
+	n, ok := strings.CutPrefix(last, "max:")
+	if !ok {
+		return arg, 0
+	}
+	count, err := strconv.Atoi(n)
+	if err != nil || count <= 0 {
+		return arg, 0
+	}
+
+	return strings.TrimSpace(strings.Join(fields[:len(fields)-1], " ")), count
 }
 
 // TaskStats represents the statistics from a completed task.
@@ -1079,7 +2524,7 @@ type TaskStats struct {
 	CostUSD    float64 `json:"cost_usd"`
 }
 
-// postStatsMessage posts a formatted stats message using Slack blocks.
+// postStatsMessage posts a formatted stats message using chat blocks.
 func (h *Handler) postStatsMessage(channelID, threadTS, statsJSON string) {
 	var stats TaskStats
 	if err := json.Unmarshal([]byte(statsJSON), &stats); err != nil {
@@ -1099,9 +2544,6 @@ func (h *Handler) postStatsMessage(channelID, threadTS, statsJSON string) {
 	// Format cost.
 	costStr := fmt.Sprintf("$%.4f", stats.CostUSD)
 
-	// Build blocks with fields for table-like layout.
-	blocks := []slack.Block{}
-
 	// Status emoji based on error state.
 	var statusEmoji string
 	if stats.IsError {
@@ -1110,17 +2552,16 @@ func (h *Handler) postStatsMessage(channelID, threadTS, statsJSON string) {
 		statusEmoji = ":chart_with_upwards_trend:"
 	}
 
-	// Use context block for compact inline display.
-	contextElements := []slack.MixedElement{
-		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("%s *Task Stats*", statusEmoji), false, false),
-		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("â±ï¸ %s", durationStr), false, false),
-		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("ðŸ”„ %d turns", stats.NumTurns), false, false),
-		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("ðŸ’° %s", costStr), false, false),
+	// Use a context block for compact inline display.
+	blocks := []chat.Block{
+		chat.Context{Elements: []chat.Text{
+			{Markdown: fmt.Sprintf("%s *Task Stats*", statusEmoji)},
+			{Markdown: fmt.Sprintf("⏱️ %s", durationStr)},
+			{Markdown: fmt.Sprintf("🔄 %d turns", stats.NumTurns)},
+			{Markdown: fmt.Sprintf("💰 %s", costStr)},
+		}},
 	}
 
-	contextBlock := slack.NewContextBlock("", contextElements...)
-	blocks = append(blocks, contextBlock)
-
 	// Post the stats message.
 	if _, err := h.bot.PostMessageBlocks(channelID, blocks, threadTS); err != nil {
 		h.logger.Error().Err(err).Msg("failed to post stats message")
@@ -1143,8 +2584,18 @@ func formatBytes(bytes int) string {
 	}
 }
 
-// postToolSnippet posts a tool result as a summary line with attached collapsible snippet.
-func (h *Handler) postToolSnippet(channelID, threadTS, toolName, inputJSON, content string, logger zerolog.Logger) {
+// postToolSnippet posts a tool result as a summary line with attached
+// collapsible snippet, syntax-highlighted per snippetLanguage. hash is the
+// content's key in the task's on-disk snippet cache (empty if caching
+// failed); content above snippetPageBytes is uploaded as a first page with
+// "Show more" / "Show head/tail only" / "Download raw" controls instead of
+// in full, so later pages can be streamed from disk on demand (see
+// handleSnippetAction) rather than kept here in memory.
+func (h *Handler) postToolSnippet(channelID, threadTS, toolName, inputJSON, hash, content, taskPath string, logger zerolog.Logger) {
+	if h.bot.files == nil {
+		return
+	}
+
 	contentLen := len(content)
 	lineCount := strings.Count(content, "\n") + 1
 
@@ -1238,9 +2689,42 @@ func (h *Handler) postToolSnippet(channelID, threadTS, toolName, inputJSON, cont
 		snippetTitle = fmt.Sprintf("%s output", toolName)
 	}
 
+	language := snippetLanguage(toolName, input)
+
+	// Content above snippetPageBytes is paginated: upload just the first
+	// page here, plus controls to fetch the rest (or the whole file) from
+	// the on-disk snippet cache on demand, instead of uploading the full
+	// (possibly huge) content in one shot.
+	uploadContent := content
+	paginated := hash != "" && int64(contentLen) > snippetPageBytes
+	if paginated {
+		uploadContent = content[:snippetPageBytes]
+		snippetTitle = fmt.Sprintf("%s (page 1)", snippetTitle)
+	}
+
 	// Upload content as collapsible snippet with summary as the comment.
 	// This keeps the summary and expandable content together in one message.
-	if _, err := h.bot.files.UploadSnippet(content, snippetTitle, summary, channelID, threadTS); err != nil {
+	if _, err := h.bot.files.UploadSnippet(uploadContent, snippetTitle, summary, language, channelID, threadTS); err != nil {
 		logger.Error().Err(err).Str("tool", toolName).Msg("failed to upload tool snippet")
+		return
+	}
+	metrics.SnippetBytesUploaded.WithLabelValues(toolName).Add(float64(len(uploadContent)))
+
+	if paginated {
+		if block, ok := snippetPaginationBlock(toolName, inputJSON, taskPath, hash, threadTS, int64(contentLen), snippetPageBytes); ok {
+			if _, err := h.bot.PostMessageBlocks(channelID, []chat.Block{block}, threadTS); err != nil {
+				logger.Error().Err(err).Msg("failed to post snippet pagination controls")
+			}
+		}
+	}
+}
+
+// adminKill terminates a task's clod process by PID, but only on behalf of
+// a user holding the "admin" role. Intended for privileged task-management
+// actions (e.g. an admin cancelling another user's stuck task).
+func (h *Handler) adminKill(userID string, pid int) error {
+	if !h.bot.auth.IsAdmin(userID) {
+		return oops.New("user %s is not authorized to kill tasks", userID)
 	}
+	return h.bot.runner.Kill(pid)
 }
@@ -0,0 +1,96 @@
+// Package metrics holds the bot's Prometheus collectors. Centralizing them
+// here (rather than promauto.With(prometheus.DefaultRegisterer) calls
+// scattered across the codebase) gives tests one place to assert on
+// collector state and gives operators one source of truth for what's
+// exposed on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// TaskInvocations counts completed task runs by task name and outcome
+// (completed, failed, timed_out, cancelled).
+var TaskInvocations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "clod_task_invocations_total",
+	Help: "Total number of task invocations, by task name and outcome.",
+}, []string{"task", "outcome"})
+
+// TaskDuration observes how long a task's clod execution took, from Start to
+// a final Result, by task name.
+var TaskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "clod_task_duration_seconds",
+	Help:    "Duration of clod task executions in seconds, by task name.",
+	Buckets: prometheus.ExponentialBuckets(1, 2, 12), // 1s .. ~34m
+}, []string{"task"})
+
+// PermissionDenials counts tool permission requests denied, either by the
+// policy engine or by a user's Deny button click, by tool name.
+var PermissionDenials = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "clod_permission_denials_total",
+	Help: "Total number of tool permission requests denied, by tool name.",
+}, []string{"tool"})
+
+// SessionCount tracks the current number of stored thread-to-session
+// mappings (see bot.SessionStore).
+var SessionCount = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "clod_session_count",
+	Help: "Current number of stored thread-to-session mappings.",
+})
+
+// SlackAPIErrors counts errors returned by Slack Web API calls, by method
+// name (e.g. "PostMessage", "PostEphemeral").
+var SlackAPIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "clod_slack_api_errors_total",
+	Help: "Total number of Slack API call errors, by method.",
+}, []string{"method"})
+
+// ShutdownDuration observes how long graceful shutdown took.
+var ShutdownDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "clod_shutdown_duration_seconds",
+	Help:    "Duration of graceful shutdown in seconds.",
+	Buckets: prometheus.DefBuckets,
+})
+
+// TaskCostUSD observes the cost clod itself reported for a completed task
+// (from the "result" message's total_cost_usd), by task name.
+var TaskCostUSD = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "clod_task_cost_usd",
+	Help:    "Cost in USD reported by clod for a task invocation, by task name.",
+	Buckets: prometheus.ExponentialBuckets(0.001, 4, 10), // $0.001 .. ~$262
+}, []string{"task"})
+
+// TaskNumTurns observes the number of turns clod reported for a completed
+// task, by task name.
+var TaskNumTurns = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "clod_task_num_turns",
+	Help:    "Number of turns reported by clod for a task invocation, by task name.",
+	Buckets: prometheus.ExponentialBuckets(1, 2, 8), // 1 .. 128
+}, []string{"task"})
+
+// PermissionGrants counts tool permission requests granted, by tool name.
+var PermissionGrants = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "clod_permission_grants_total",
+	Help: "Total number of tool permission requests granted, by tool name.",
+}, []string{"tool"})
+
+// ToolInvocations counts tool calls clod made, by tool name.
+var ToolInvocations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "clod_tool_invocations_total",
+	Help: "Total number of tool invocations, by tool name.",
+}, []string{"tool"})
+
+// SnippetBytesUploaded counts the bytes of tool output uploaded as
+// collapsible Slack snippets (see Handler.postToolSnippet), by tool name.
+var SnippetBytesUploaded = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "clod_snippet_bytes_uploaded_total",
+	Help: "Total bytes of tool output uploaded as snippets, by tool name.",
+}, []string{"tool"})
+
+// PendingPermissionQueueDepth tracks the current number of permission
+// requests awaiting a Slack user's decision (see Handler.pendingPermissions).
+var PendingPermissionQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "clod_pending_permission_queue_depth",
+	Help: "Current number of permission requests awaiting a decision.",
+})
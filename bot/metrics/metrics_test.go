@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTaskInvocationsCountsByTaskAndOutcome(t *testing.T) {
+	TaskInvocations.Reset()
+
+	TaskInvocations.WithLabelValues("deprecation", "completed").Inc()
+	TaskInvocations.WithLabelValues("deprecation", "failed").Inc()
+	TaskInvocations.WithLabelValues("deprecation", "completed").Inc()
+
+	if got := testutil.ToFloat64(TaskInvocations.WithLabelValues("deprecation", "completed")); got != 2 {
+		t.Fatalf("got %v completed invocations, want 2", got)
+	}
+	if got := testutil.ToFloat64(TaskInvocations.WithLabelValues("deprecation", "failed")); got != 1 {
+		t.Fatalf("got %v failed invocations, want 1", got)
+	}
+}
+
+func TestSessionCountReflectsLastSet(t *testing.T) {
+	SessionCount.Set(3)
+	if got := testutil.ToFloat64(SessionCount); got != 3 {
+		t.Fatalf("got %v, want 3", got)
+	}
+}
+
+func TestPermissionGrantsAndDenialsCountByTool(t *testing.T) {
+	PermissionGrants.Reset()
+	PermissionDenials.Reset()
+
+	PermissionGrants.WithLabelValues("Bash").Inc()
+	PermissionGrants.WithLabelValues("Bash").Inc()
+	PermissionDenials.WithLabelValues("Bash").Inc()
+
+	if got := testutil.ToFloat64(PermissionGrants.WithLabelValues("Bash")); got != 2 {
+		t.Fatalf("got %v grants, want 2", got)
+	}
+	if got := testutil.ToFloat64(PermissionDenials.WithLabelValues("Bash")); got != 1 {
+		t.Fatalf("got %v denials, want 1", got)
+	}
+}
+
+func TestToolInvocationsAndSnippetBytesCountByTool(t *testing.T) {
+	ToolInvocations.Reset()
+	SnippetBytesUploaded.Reset()
+
+	ToolInvocations.WithLabelValues("Write").Inc()
+	SnippetBytesUploaded.WithLabelValues("Write").Add(42)
+
+	if got := testutil.ToFloat64(ToolInvocations.WithLabelValues("Write")); got != 1 {
+		t.Fatalf("got %v tool invocations, want 1", got)
+	}
+	if got := testutil.ToFloat64(SnippetBytesUploaded.WithLabelValues("Write")); got != 42 {
+		t.Fatalf("got %v snippet bytes, want 42", got)
+	}
+}
+
+func TestPendingPermissionQueueDepthReflectsLastSet(t *testing.T) {
+	PendingPermissionQueueDepth.Set(2)
+	if got := testutil.ToFloat64(PendingPermissionQueueDepth); got != 2 {
+		t.Fatalf("got %v, want 2", got)
+	}
+}
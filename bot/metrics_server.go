@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+// newMetricsServer builds an *http.Server exposing /metrics (Prometheus
+// text format), /healthz (process is up), and /readyz (process is up and
+// has finished its own startup, which by the time this is called is always
+// true — kept as a separate endpoint so a load balancer can distinguish
+// liveness from readiness if that changes later).
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// runMetricsServer starts server and blocks until ctx is cancelled, then
+// shuts it down. Intended to run in its own goroutine from CLI.Run.
+func runMetricsServer(ctx context.Context, server *http.Server, logger zerolog.Logger) {
+	logger = logger.With().Str("component", "metrics_server").Logger()
+
+	errors := make(chan error, 1)
+	go func() {
+		logger.Info().Str("addr", server.Addr).Msg("serving /metrics, /healthz, /readyz")
+		errors <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errors:
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error().Err(err).Msg("metrics server stopped unexpectedly")
+		}
+	case <-ctx.Done():
+		if err := server.Shutdown(context.Background()); err != nil {
+			logger.Error().Err(err).Msg("failed to shut down metrics server")
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package mrkdwn
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// ConvertMarkdownToMrkdwn converts GitHub-flavored markdown to Slack's mrkdwn format.
+// Uses an AST parser for robust handling of nested structures.
+func ConvertMarkdownToMrkdwn(md string) string {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.Strikethrough
+	p := parser.NewWithExtensions(extensions)
+
+	data := markdown.NormalizeNewlines([]byte(md))
+	node := p.Parse(data)
+
+	renderer := &mrkdwnRenderer{}
+	result := markdown.Render(node, renderer)
+
+	return strings.TrimSpace(string(result))
+}
+
+// mrkdwnRenderer renders markdown AST to Slack's mrkdwn format.
+type mrkdwnRenderer struct{}
+
+func (r *mrkdwnRenderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	switch n := node.(type) {
+	case *ast.Document:
+		return ast.GoToNext
+
+	case *ast.Paragraph:
+		if !entering {
+			// Don't add newline if parent is a ListItem (it handles its own newlines).
+			if _, isListItem := n.Parent.(*ast.ListItem); !isListItem {
+				_, _ = fmt.Fprint(w, "\n")
+			}
+		}
+		return ast.GoToNext
+
+	case *ast.Text:
+		if entering {
+			_, _ = fmt.Fprint(w, string(n.Literal))
+		}
+		return ast.GoToNext
+
+	case *ast.Strong:
+		if entering {
+			_, _ = fmt.Fprint(w, "*")
+		} else {
+			_, _ = fmt.Fprint(w, "*")
+		}
+		return ast.GoToNext
+
+	case *ast.Emph:
+		if entering {
+			_, _ = fmt.Fprint(w, "_")
+		} else {
+			_, _ = fmt.Fprint(w, "_")
+		}
+		return ast.GoToNext
+
+	case *ast.Del:
+		if entering {
+			_, _ = fmt.Fprint(w, "~")
+		} else {
+			_, _ = fmt.Fprint(w, "~")
+		}
+		return ast.GoToNext
+
+	case *ast.Heading:
+		if entering {
+			_, _ = fmt.Fprint(w, "\n*")
+		} else {
+			_, _ = fmt.Fprint(w, "*\n")
+		}
+		return ast.GoToNext
+
+	case *ast.Link:
+		if entering {
+			// Render children to get the link text.
+			var textBuilder strings.Builder
+			for _, child := range n.Children {
+				childData := markdown.Render(child, r)
+				textBuilder.Write(childData)
+			}
+			linkText := strings.TrimSpace(textBuilder.String())
+			_, _ = fmt.Fprintf(w, "<%s|%s>", string(n.Destination), linkText)
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+
+	case *ast.Code:
+		if entering {
+			_, _ = fmt.Fprintf(w, "`%s`", string(n.Literal))
+		}
+		return ast.GoToNext
+
+	case *ast.CodeBlock:
+		if entering {
+			code := strings.TrimSuffix(string(n.Literal), "\n")
+			_, _ = fmt.Fprintf(w, "```\n%s\n```\n", code)
+		}
+		return ast.GoToNext
+
+	case *ast.List:
+		if !entering {
+			_, _ = fmt.Fprint(w, "\n")
+		}
+		return ast.GoToNext
+
+	case *ast.ListItem:
+		if entering {
+			// Determine the bullet style.
+			parent := n.Parent
+			if list, ok := parent.(*ast.List); ok {
+				if list.ListFlags&ast.ListTypeOrdered != 0 {
+					// Find item index for ordered lists.
+					idx := 1
+					for i, sibling := range list.Children {
+						if sibling == node {
+							idx = i + 1
+							break
+						}
+					}
+					start := list.Start
+					if start == 0 {
+						start = 1
+					}
+					_, _ = fmt.Fprintf(w, "%d. ", idx+start-1)
+				} else {
+					_, _ = fmt.Fprint(w, "• ")
+				}
+			}
+		} else {
+			_, _ = fmt.Fprint(w, "\n")
+		}
+		return ast.GoToNext
+
+	case *ast.BlockQuote:
+		if entering {
+			// Render children and prefix each line with >.
+			var contentBuilder strings.Builder
+			for _, child := range n.Children {
+				childData := markdown.Render(child, r)
+				contentBuilder.Write(childData)
+			}
+			content := strings.TrimSpace(contentBuilder.String())
+			lines := strings.Split(content, "\n")
+			for _, line := range lines {
+				_, _ = fmt.Fprintf(w, "> %s\n", line)
+			}
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+
+	case *ast.Table:
+		if entering {
+			renderTable(w, n)
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+
+	case *ast.HorizontalRule:
+		if entering {
+			_, _ = fmt.Fprint(w, "\n---\n")
+		}
+		return ast.GoToNext
+
+	case *ast.Softbreak:
+		if entering {
+			_, _ = fmt.Fprint(w, "\n")
+		}
+		return ast.GoToNext
+
+	case *ast.Hardbreak:
+		if entering {
+			_, _ = fmt.Fprint(w, "\n")
+		}
+		return ast.GoToNext
+
+	case *ast.HTMLSpan:
+		// Pass through HTML spans as-is.
+		if entering {
+			_, _ = fmt.Fprint(w, string(n.Literal))
+		}
+		return ast.GoToNext
+
+	case *ast.HTMLBlock:
+		// Pass through HTML blocks as-is.
+		if entering {
+			_, _ = fmt.Fprint(w, string(n.Literal))
+		}
+		return ast.GoToNext
+
+	default:
+		// For unknown nodes, try to render children.
+		return ast.GoToNext
+	}
+}
+
+func (r *mrkdwnRenderer) RenderHeader(w io.Writer, node ast.Node) {}
+
+func (r *mrkdwnRenderer) RenderFooter(w io.Writer, node ast.Node) {}
+
+// maxTableColWidth is the widest a rendered column is allowed to be before
+// its cells get wrapped onto multiple lines.
+const maxTableColWidth = 30
+
+// tableCell is the plain-text content and alignment of one rendered cell.
+type tableCell struct {
+	text  string
+	align ast.CellAlignFlags
+}
+
+// renderTable renders a *ast.Table as a fenced code block containing a
+// monospaced ASCII layout, since Slack mrkdwn has no native table syntax.
+func renderTable(w io.Writer, table *ast.Table) {
+	var rows [][]tableCell
+	headerRows := 0
+
+	ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+		row, ok := node.(*ast.TableRow)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		var cells []tableCell
+		isHeader := false
+		for _, child := range row.Children {
+			cell, ok := child.(*ast.TableCell)
+			if !ok {
+				continue
+			}
+			isHeader = isHeader || cell.IsHeader
+			cells = append(cells, tableCell{
+				text:  tableCellText(cell),
+				align: cell.Align,
+			})
+		}
+		if isHeader {
+			headerRows++
+		}
+		rows = append(rows, cells)
+		return ast.SkipChildren
+	})
+
+	if len(rows) == 0 {
+		return
+	}
+
+	numCols := 0
+	for _, row := range rows {
+		if len(row) > numCols {
+			numCols = len(row)
+		}
+	}
+
+	// Column widths, capped at maxTableColWidth; auto right-align columns
+	// that look entirely numeric and have no explicit alignment.
+	widths := make([]int, numCols)
+	numeric := make([]bool, numCols)
+	for c := 0; c < numCols; c++ {
+		numeric[c] = true
+	}
+	for _, row := range rows {
+		for c, cell := range row {
+			if l := utf8.RuneCountInString(cell.text); l > widths[c] {
+				widths[c] = l
+			}
+			if cell.text != "" && !isNumericLike(cell.text) {
+				numeric[c] = false
+			}
+		}
+	}
+	for c := range widths {
+		if widths[c] > maxTableColWidth {
+			widths[c] = maxTableColWidth
+		}
+	}
+
+	align := func(c int, explicit ast.CellAlignFlags) ast.CellAlignFlags {
+		if explicit != 0 {
+			return explicit
+		}
+		if numeric[c] {
+			return ast.TableAlignmentRight
+		}
+		return ast.TableAlignmentLeft
+	}
+
+	_, _ = fmt.Fprint(w, "\n```\n")
+	for i, row := range rows {
+		for _, line := range wrapRow(row, widths, align) {
+			_, _ = fmt.Fprintln(w, line)
+		}
+		if i == headerRows-1 && i != len(rows)-1 {
+			var sep []string
+			for c := 0; c < numCols; c++ {
+				sep = append(sep, strings.Repeat("-", widths[c]))
+			}
+			_, _ = fmt.Fprintln(w, strings.Join(sep, "-+-"))
+		}
+	}
+	_, _ = fmt.Fprint(w, "```\n")
+}
+
+// wrapRow pads/truncates each cell to its column width, wrapping cells
+// wider than the column onto additional lines.
+func wrapRow(row []tableCell, widths []int, align func(c int, explicit ast.CellAlignFlags) ast.CellAlignFlags) []string {
+	wrapped := make([][]string, len(widths))
+	lineCount := 1
+	for c := range widths {
+		var text string
+		if c < len(row) {
+			text = row[c].text
+		}
+		wrapped[c] = wrapCell(text, widths[c])
+		if len(wrapped[c]) > lineCount {
+			lineCount = len(wrapped[c])
+		}
+	}
+
+	lines := make([]string, lineCount)
+	for l := 0; l < lineCount; l++ {
+		var cols []string
+		for c := range widths {
+			var explicit ast.CellAlignFlags
+			if c < len(row) {
+				explicit = row[c].align
+			}
+			var text string
+			if l < len(wrapped[c]) {
+				text = wrapped[c][l]
+			}
+			cols = append(cols, padCell(text, widths[c], align(c, explicit)))
+		}
+		lines[l] = strings.Join(cols, " | ")
+	}
+	return lines
+}
+
+// wrapCell splits text into chunks of at most width runes, so multi-byte
+// characters (CJK, emoji, accents) are never split mid-codepoint.
+func wrapCell(text string, width int) []string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return []string{text}
+	}
+
+	var lines []string
+	for len(runes) > width {
+		lines = append(lines, string(runes[:width]))
+		runes = runes[width:]
+	}
+	if len(runes) > 0 {
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// padCell pads text to width (in runes) according to the given alignment.
+func padCell(text string, width int, align ast.CellAlignFlags) string {
+	pad := width - utf8.RuneCountInString(text)
+	if pad <= 0 {
+		return text
+	}
+
+	switch align {
+	case ast.TableAlignmentRight:
+		return strings.Repeat(" ", pad) + text
+	case ast.TableAlignmentCenter:
+		left := pad / 2
+		right := pad - left
+		return strings.Repeat(" ", left) + text + strings.Repeat(" ", right)
+	default:
+		return text + strings.Repeat(" ", pad)
+	}
+}
+
+// isNumericLike reports whether text looks like a number (optionally
+// signed, with a decimal point, thousands separators, or a trailing unit
+// like "%" or "$" prefix), used to auto right-align unmarked columns.
+func isNumericLike(text string) bool {
+	trimmed := strings.TrimSpace(text)
+	trimmed = strings.TrimPrefix(trimmed, "$")
+	trimmed = strings.TrimSuffix(trimmed, "%")
+	if trimmed == "" {
+		return false
+	}
+	for _, r := range trimmed {
+		switch {
+		case r >= '0' && r <= '9':
+		case r == '.' || r == ',' || r == '-' || r == '+':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// tableCellText extracts the plain text content of a table cell, stripping
+// inline markup since the cell will be rendered inside a monospace block.
+func tableCellText(cell *ast.TableCell) string {
+	var buf strings.Builder
+	ast.WalkFunc(cell, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Text:
+			buf.Write(n.Literal)
+		case *ast.Code:
+			buf.Write(n.Literal)
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(buf.String())
+}
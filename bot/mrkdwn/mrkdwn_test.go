@@ -1,4 +1,4 @@
-package main
+package mrkdwn
 
 import (
 	"strings"
@@ -76,6 +76,16 @@ func TestConvertMarkdownToMrkdwn(t *testing.T) {
 			input:    "> This is a quote",
 			expected: "> This is a quote",
 		},
+		{
+			name:     "table",
+			input:    "| Name | Count |\n| --- | ---: |\n| apples | 3 |\n| bananas | 12 |",
+			expected: "```\nName    | Count\n--------+------\napples  |     3\nbananas |    12\n```",
+		},
+		{
+			name:     "table with non-ASCII content",
+			input:    "| Name | 数量 |\n| --- | ---: |\n| apples | 3 |\n| 香蕉 | 12 |",
+			expected: "```\nName   | 数量\n-------+---\napples |  3\n香蕉     | 12\n```",
+		},
 	}
 
 	for _, tt := range tests {
@@ -129,3 +139,18 @@ For more info, see [the docs](https://example.com).
 		t.Error("Link not converted")
 	}
 }
+
+func TestWrapCellRuneAware(t *testing.T) {
+	// 4 multi-byte runes wrapped at width 2 must split on rune boundaries,
+	// not byte offsets, or the result contains invalid UTF-8.
+	got := wrapCell("漢字漢字", 2)
+	want := []string{"漢字", "漢字"}
+	if len(got) != len(want) {
+		t.Fatalf("wrapCell lines = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wrapCell line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/rs/zerolog"
+)
+
+// notifyTimeout bounds how long a single sink's HTTP POST may take, so a
+// slow or unreachable webhook can't back up task lifecycle processing.
+const notifyTimeout = 10 * time.Second
+
+// NotifyEventType identifies which point in a task's lifecycle a
+// NotifyEvent describes.
+type NotifyEventType string
+
+const (
+	NotifyStarted   NotifyEventType = "started"
+	NotifyTool      NotifyEventType = "tool"
+	NotifyCompleted NotifyEventType = "completed"
+	NotifyFailed    NotifyEventType = "failed"
+	NotifyTimedOut  NotifyEventType = "timed_out"
+)
+
+// NotifyEvent is the payload fanned out to every configured notify sink.
+type NotifyEvent struct {
+	Type      NotifyEventType `json:"type"`
+	TaskName  string          `json:"task_name"`
+	TaskPath  string          `json:"task_path,omitempty"`
+	ChannelID string          `json:"channel_id,omitempty"`
+	ThreadTS  string          `json:"thread_ts,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	ToolName  string          `json:"tool_name,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// NotifySink delivers a single NotifyEvent to one outbound destination.
+type NotifySink interface {
+	Notify(ctx context.Context, evt NotifyEvent) error
+}
+
+// Notifier fans task lifecycle events out to every configured NotifySink,
+// mirroring the single "notify-url" abstraction tools like kured use in
+// place of bespoke per-backend hook flags: operators point clod at
+// PagerDuty, Matrix, a generic webhook, or a Slack Incoming Webhook without
+// touching core code.
+type Notifier struct {
+	sinks  []NotifySink
+	logger zerolog.Logger
+}
+
+// NewNotifier builds a Notifier from raw notify-url strings, selecting each
+// URL's sink type by host (see newSink). Blank entries are ignored, so a nil
+// or all-empty urls slice yields a Notifier that's safe to call but does
+// nothing.
+func NewNotifier(urls []string, logger zerolog.Logger) *Notifier {
+	n := &Notifier{logger: logger.With().Str("component", "notifier").Logger()}
+
+	client := &http.Client{Timeout: notifyTimeout}
+	for _, raw := range urls {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		n.sinks = append(n.sinks, newSink(raw, client))
+	}
+
+	return n
+}
+
+// newSink selects a NotifySink implementation for rawURL by host: Slack
+// Incoming Webhook URLs get Slack's simple {"text": ...} payload, everything
+// else gets a generic JSON POST of the NotifyEvent.
+func newSink(rawURL string, client *http.Client) NotifySink {
+	if parsed, err := url.Parse(rawURL); err == nil && strings.HasSuffix(parsed.Host, "hooks.slack.com") {
+		return &slackWebhookSink{url: rawURL, client: client}
+	}
+	return &httpSink{url: rawURL, client: client}
+}
+
+// Notify fans evt out to every configured sink concurrently. A sink that
+// errors or times out is logged and otherwise ignored, so one broken
+// webhook can't hold up the others or the caller (see runClod, which fires
+// these from the task's own goroutine).
+func (n *Notifier) Notify(evt NotifyEvent) {
+	if n == nil {
+		return
+	}
+
+	for _, sink := range n.sinks {
+		go func(sink NotifySink) {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+
+			if err := sink.Notify(ctx, evt); err != nil {
+				n.logger.Warn().Err(err).Str("event_type", string(evt.Type)).Str("task", evt.TaskName).
+					Msg("failed to deliver lifecycle notification")
+			}
+		}(sink)
+	}
+}
+
+// httpSink POSTs the NotifyEvent as JSON, for generic webhook receivers
+// (PagerDuty, Matrix, custom automation, etc).
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Notify(ctx context.Context, evt NotifyEvent) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+// slackWebhookSink POSTs to a Slack Incoming Webhook URL, which expects
+// {"text": "..."} rather than the generic NotifyEvent shape.
+type slackWebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackWebhookSink) Notify(ctx context.Context, evt NotifyEvent) error {
+	body, err := json.Marshal(map[string]string{"text": notifyEventText(evt)})
+	if err != nil {
+		return oops.Trace(err)
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+// postJSON POSTs body to url and treats any non-2xx response as an error.
+func postJSON(ctx context.Context, client *http.Client, dest string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest, bytes.NewReader(body))
+	if err != nil {
+		return oops.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return oops.New("notify sink %s returned status %d", dest, resp.StatusCode)
+	}
+	return nil
+}
+
+// notifyEventText renders a NotifyEvent as a one-line human summary, used by
+// sinks (Slack Incoming Webhooks) that want text rather than structured JSON.
+func notifyEventText(evt NotifyEvent) string {
+	switch evt.Type {
+	case NotifyStarted:
+		return fmt.Sprintf(":rocket: Task `%s` started", evt.TaskName)
+	case NotifyTool:
+		return fmt.Sprintf(":gear: Task `%s` invoked tool `%s`", evt.TaskName, evt.ToolName)
+	case NotifyCompleted:
+		return fmt.Sprintf(":white_check_mark: Task `%s` completed", evt.TaskName)
+	case NotifyFailed:
+		return fmt.Sprintf(":x: Task `%s` failed: %s", evt.TaskName, evt.Message)
+	case NotifyTimedOut:
+		return fmt.Sprintf(":hourglass: Task `%s` timed out", evt.TaskName)
+	default:
+		return fmt.Sprintf("Task `%s`: %s", evt.TaskName, evt.Type)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNotifierDeliversGenericJSON(t *testing.T) {
+	received := make(chan NotifyEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var evt NotifyEvent
+		if err := json.NewDecoder(r.Body).Decode(&evt); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		received <- evt
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier([]string{server.URL}, zerolog.Nop())
+	notifier.Notify(NotifyEvent{Type: NotifyStarted, TaskName: "deprecation"})
+
+	select {
+	case evt := <-received:
+		if evt.Type != NotifyStarted || evt.TaskName != "deprecation" {
+			t.Fatalf("got %+v", evt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("notify sink never received the event")
+	}
+}
+
+func TestNotifierSelectsSlackWebhookSinkByHost(t *testing.T) {
+	sink := newSink("https://hooks.slack.com/services/T000/B000/XXXX", http.DefaultClient)
+	if _, ok := sink.(*slackWebhookSink); !ok {
+		t.Fatalf("got %T, want *slackWebhookSink", sink)
+	}
+
+	sink = newSink("https://example.com/webhook", http.DefaultClient)
+	if _, ok := sink.(*httpSink); !ok {
+		t.Fatalf("got %T, want *httpSink", sink)
+	}
+}
+
+func TestNotifierIgnoresBlankURLs(t *testing.T) {
+	notifier := NewNotifier([]string{"", "  "}, zerolog.Nop())
+	if len(notifier.sinks) != 0 {
+		t.Fatalf("got %d sinks, want 0", len(notifier.sinks))
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/fsnotify/fsnotify"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// outputQuietPeriod is how long a file must go without a write event
+	// before outputWatcher considers it finished and uploads it.
+	outputQuietPeriod = 1 * time.Second
+
+	// outputReuploadCooldown mirrors the old polling implementation's
+	// cooldown: a file that keeps changing after being uploaded once
+	// isn't re-uploaded more than once per window.
+	outputReuploadCooldown = 10 * time.Second
+
+	// outputStreamThreshold is the file size above which uploads are
+	// streamed with a progress-reporting reader instead of being handed
+	// to UploadFromTaskOutputs directly.
+	outputStreamThreshold = 1 << 20 // 1 MiB
+
+	// outputProgressInterval is the minimum gap between progress message
+	// edits for a streaming upload.
+	outputProgressInterval = 2 * time.Second
+)
+
+// uploadedFile tracks upload state for one output file.
+type uploadedFile struct {
+	modTime        time.Time // Last modification time when uploaded
+	lastUploadTime time.Time // When the file was last uploaded (for the cooldown)
+}
+
+// outputWatcher replaces the old poll-every-2s implementation of
+// FileHandler.WatchOutputs with an fsnotify-driven one: it watches taskPath
+// recursively (adding subdirectories as they're created), and coalesces a
+// burst of WRITE/CREATE events for a file into a single upload once the
+// file has gone outputQuietPeriod without another event.
+type outputWatcher struct {
+	f         *FileHandler
+	taskPath  string
+	channelID string
+	threadTS  string
+	watcher   *fsnotify.Watcher
+
+	mu       sync.Mutex
+	uploaded map[string]*uploadedFile // localPath -> state of the last upload
+	pending  map[string]*time.Timer   // localPath -> debounce timer awaiting the quiet period
+}
+
+// newOutputWatcher starts watching taskPath and seeds uploaded with
+// whatever's already there, so pre-existing files aren't treated as new
+// outputs.
+func newOutputWatcher(f *FileHandler, taskPath, channelID, threadTS string) (*outputWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	ow := &outputWatcher{
+		f:         f,
+		taskPath:  taskPath,
+		channelID: channelID,
+		threadTS:  threadTS,
+		watcher:   watcher,
+		uploaded:  make(map[string]*uploadedFile),
+		pending:   make(map[string]*time.Timer),
+	}
+
+	now := time.Now()
+	walkErr := filepath.WalkDir(taskPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // best effort; a directory that vanished mid-walk just isn't watched
+		}
+		if d.IsDir() {
+			return oops.Trace(watcher.Add(path))
+		}
+		if info, err := d.Info(); err == nil {
+			ow.uploaded[path] = &uploadedFile{modTime: info.ModTime(), lastUploadTime: now}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		watcher.Close()
+		return nil, oops.Trace(walkErr)
+	}
+
+	f.logger.Debug().
+		Str("task_path", taskPath).
+		Int("existing_files", len(ow.uploaded)).
+		Msg("starting output file watcher")
+
+	return ow, nil
+}
+
+// run dispatches fsnotify events until done fires, then does one final
+// flush (settling anything still debouncing, and checking for files
+// deleted since the last event) before returning.
+func (ow *outputWatcher) run(done <-chan struct{}) {
+	defer ow.watcher.Close()
+
+	for {
+		select {
+		case <-done:
+			ow.f.logger.Debug().Msg("output file watcher stopping")
+			ow.flush()
+			return
+		case event, ok := <-ow.watcher.Events:
+			if !ok {
+				return
+			}
+			ow.handleEvent(event)
+		case err, ok := <-ow.watcher.Errors:
+			if !ok {
+				return
+			}
+			ow.f.logger.Warn().Err(err).Msg("output file watcher error")
+		}
+	}
+}
+
+// handleEvent routes one fsnotify event: a new directory is added to the
+// watch, a write/create on a file (re)starts its debounce timer, and a
+// remove/rename propagates the deletion to Slack immediately.
+func (ow *outputWatcher) handleEvent(event fsnotify.Event) {
+	switch {
+	case event.Op&(fsnotify.Write|fsnotify.Create) != 0:
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return // vanished again before we got to it
+		}
+		if info.IsDir() {
+			if event.Op&fsnotify.Create != 0 {
+				if err := ow.addDir(event.Name); err != nil {
+					ow.f.logger.Warn().Err(err).Str("dir", event.Name).Msg("failed to watch new output subdirectory")
+				}
+			}
+			return
+		}
+		ow.schedule(event.Name)
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		ow.handleRemoved(event.Name)
+	}
+}
+
+// addDir adds dir and every subdirectory under it to the watch, for a
+// subdirectory created after the initial watch was set up.
+func (ow *outputWatcher) addDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			return oops.Trace(ow.watcher.Add(path))
+		}
+		return nil
+	})
+}
+
+// schedule (re)starts path's debounce timer so it fires outputQuietPeriod
+// after the most recent write/create event, coalescing a burst of events
+// from one write into a single settle call.
+func (ow *outputWatcher) schedule(path string) {
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+
+	if t, ok := ow.pending[path]; ok {
+		t.Reset(outputQuietPeriod)
+		return
+	}
+
+	ow.pending[path] = time.AfterFunc(outputQuietPeriod, func() {
+		ow.mu.Lock()
+		delete(ow.pending, path)
+		ow.mu.Unlock()
+		ow.settle(path)
+	})
+}
+
+// settle decides whether path has really finished changing and, if so,
+// uploads it. Called once a debounce timer fires, and again for any file
+// still pending or already tracked during the final flush.
+func (ow *outputWatcher) settle(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return // removed before it ever settled
+	}
+
+	ow.mu.Lock()
+	tracked, wasUploaded := ow.uploaded[path]
+	ow.mu.Unlock()
+
+	if wasUploaded {
+		if !info.ModTime().After(tracked.modTime) {
+			return // unchanged since the last upload
+		}
+		if time.Since(tracked.lastUploadTime) < outputReuploadCooldown {
+			ow.f.logger.Debug().Str("file", path).Msg("output modified within cooldown, deferring re-upload")
+			return
+		}
+	}
+
+	name := ow.relName(path)
+	if err := ow.upload(path, name, info.Size()); err != nil {
+		ow.f.logger.Error().Err(err).Str("file", path).Msg("failed to upload output file")
+		return
+	}
+
+	ow.mu.Lock()
+	ow.uploaded[path] = &uploadedFile{modTime: info.ModTime(), lastUploadTime: time.Now()}
+	ow.mu.Unlock()
+}
+
+// upload uploads path to Slack, streaming it with progress reporting if
+// it's at or above outputStreamThreshold.
+func (ow *outputWatcher) upload(path, name string, size int64) error {
+	if size < outputStreamThreshold {
+		comment := fmt.Sprintf(":outbox_tray: Output: `%s`", name)
+		_, err := ow.f.UploadFromTaskOutputs(path, ow.taskPath, ow.channelID, ow.threadTS, comment)
+		return err
+	}
+
+	return ow.uploadWithProgress(path, name, size)
+}
+
+// uploadWithProgress streams path through UploadFileV2 via a
+// progressReader, editing a single Slack message in place to show percent
+// complete and throughput, then finalizing it with the usual output
+// comment once the upload completes.
+func (ow *outputWatcher) uploadWithProgress(path, name string, size int64) error {
+	f := ow.f
+
+	_, ts, err := f.client.PostMessage(
+		ow.channelID,
+		slack.MsgOptionText(fmt.Sprintf(":outbox_tray: Uploading `%s`... 0%%", name), false),
+		slack.MsgOptionTS(ow.threadTS),
+	)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer in.Close()
+
+	reader := newProgressReader(in, size, func(read, total int64, elapsed time.Duration) {
+		percent := 0
+		if total > 0 {
+			percent = int(100 * read / total)
+		}
+		var rate float64
+		if elapsed > 0 {
+			rate = float64(read) / elapsed.Seconds()
+		}
+		text := fmt.Sprintf(":outbox_tray: Uploading `%s`... %d%% (%s/s)", name, percent, formatBytes(int(rate)))
+		if _, _, _, err := f.client.UpdateMessage(ow.channelID, ts, slack.MsgOptionText(text, false)); err != nil {
+			f.logger.Debug().Err(err).Str("file", name).Msg("failed to update upload progress message")
+		}
+	})
+
+	summary, err := f.client.UploadFileV2(slack.UploadFileV2Parameters{
+		Reader:          reader,
+		FileSize:        int(size),
+		Filename:        filepath.Base(name),
+		Title:           name,
+		Channel:         ow.channelID,
+		ThreadTimestamp: ow.threadTS,
+	})
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	if _, _, _, err := f.client.UpdateMessage(ow.channelID, ts, slack.MsgOptionText(fmt.Sprintf(":outbox_tray: Output: `%s`", name), false)); err != nil {
+		f.logger.Warn().Err(err).Str("file", name).Msg("failed to finalize upload progress message")
+	}
+
+	f.trackFile(summary.ID, path, ow.taskPath, ow.channelID, ow.threadTS)
+
+	return nil
+}
+
+// handleRemoved cancels any pending debounce for path and, if it had been
+// uploaded, propagates the deletion to Slack immediately rather than
+// waiting for the next flush.
+func (ow *outputWatcher) handleRemoved(path string) {
+	ow.mu.Lock()
+	if t, ok := ow.pending[path]; ok {
+		t.Stop()
+		delete(ow.pending, path)
+	}
+	_, wasUploaded := ow.uploaded[path]
+	delete(ow.uploaded, path)
+	ow.mu.Unlock()
+
+	if !wasUploaded {
+		return
+	}
+
+	ow.untrackAndDelete(path)
+}
+
+// untrackAndDelete looks path up in the file mapping store and, if found,
+// deletes the corresponding Slack file so the thread stays in sync when an
+// output file is removed locally -- the opposite direction of
+// Handler.HandleFileDeleted. No-op if the mapping store is disabled.
+func (ow *outputWatcher) untrackAndDelete(path string) {
+	if ow.f.mapping == nil {
+		return
+	}
+
+	ref, ok, err := ow.f.mapping.UntrackByLocalPath(path)
+	if err != nil {
+		ow.f.logger.Warn().Err(err).Str("local_path", path).Msg("failed to untrack removed output file")
+	}
+	if !ok {
+		return
+	}
+
+	if err := ow.f.client.DeleteFile(ref.SlackFileID); err != nil {
+		ow.f.logger.Warn().Err(err).Str("file_id", ref.SlackFileID).Msg("failed to delete Slack file for removed output")
+		return
+	}
+	ow.f.logger.Info().
+		Str("file_id", ref.SlackFileID).
+		Str("local_path", path).
+		Msg("deleted Slack file for removed output")
+}
+
+// flush settles every pending debounce immediately, then does one last
+// walk of taskPath to catch files that settled without ever seeing their
+// quiet-period timer fire (or an event at all, e.g. written and closed
+// between watcher.Add and the first read from watcher.Events), and finally
+// checks previously uploaded files for ones that have since disappeared.
+// Called once, when WatchOutputs' done channel fires.
+func (ow *outputWatcher) flush() {
+	ow.mu.Lock()
+	pending := make([]string, 0, len(ow.pending))
+	for path, t := range ow.pending {
+		t.Stop()
+		pending = append(pending, path)
+	}
+	ow.pending = make(map[string]*time.Timer)
+	ow.mu.Unlock()
+
+	for _, path := range pending {
+		ow.settle(path)
+	}
+
+	_ = filepath.WalkDir(ow.taskPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		ow.settle(path)
+		return nil
+	})
+
+	ow.mu.Lock()
+	remaining := make([]string, 0, len(ow.uploaded))
+	for path := range ow.uploaded {
+		remaining = append(remaining, path)
+	}
+	ow.mu.Unlock()
+
+	for _, path := range remaining {
+		if _, err := os.Stat(path); err == nil {
+			continue // still there
+		}
+		ow.mu.Lock()
+		delete(ow.uploaded, path)
+		ow.mu.Unlock()
+		ow.untrackAndDelete(path)
+	}
+}
+
+// relName returns path relative to taskPath (e.g. "logs/run.txt"), falling
+// back to its base name if it isn't actually under taskPath.
+func (ow *outputWatcher) relName(path string) string {
+	if rel, err := filepath.Rel(ow.taskPath, path); err == nil {
+		return rel
+	}
+	return filepath.Base(path)
+}
+
+// progressReader wraps an io.Reader, invoking report no more than once per
+// outputProgressInterval with bytes read so far, total size, and elapsed
+// time -- in the spirit of cheggaaa/pb's progress bars, scoped down to just
+// what a Slack message edit needs (percent complete and throughput).
+type progressReader struct {
+	r      io.Reader
+	total  int64
+	read   int64
+	start  time.Time
+	last   time.Time
+	report func(read, total int64, elapsed time.Duration)
+}
+
+func newProgressReader(r io.Reader, total int64, report func(read, total int64, elapsed time.Duration)) *progressReader {
+	now := time.Now()
+	return &progressReader{r: r, total: total, start: now, last: now, report: report}
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+
+	if now := time.Now(); now.Sub(p.last) >= outputProgressInterval || err == io.EOF {
+		p.last = now
+		p.report(p.read, p.total, now.Sub(p.start))
+	}
+
+	return n, err
+}
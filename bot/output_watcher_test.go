@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressReaderReportsTotalAtEOF(t *testing.T) {
+	content := strings.Repeat("x", 4096)
+	var calls int
+	var lastRead, lastTotal int64
+
+	pr := newProgressReader(strings.NewReader(content), int64(len(content)), func(read, total int64, elapsed time.Duration) {
+		calls++
+		lastRead, lastTotal = read, total
+	})
+
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		t.Fatalf("io.Copy: %v", err)
+	}
+
+	if calls == 0 {
+		t.Fatal("report was never called")
+	}
+	if lastRead != int64(len(content)) {
+		t.Fatalf("last reported read = %d, want %d", lastRead, len(content))
+	}
+	if lastTotal != int64(len(content)) {
+		t.Fatalf("last reported total = %d, want %d", lastTotal, len(content))
+	}
+}
+
+func TestOutputWatcherRelName(t *testing.T) {
+	ow := &outputWatcher{taskPath: "/tasks/t1"}
+
+	if got, want := ow.relName("/tasks/t1/logs/run.txt"), filepath.Join("logs", "run.txt"); got != want {
+		t.Fatalf("relName = %q, want %q", got, want)
+	}
+	if got, want := ow.relName("/tasks/t1/out.txt"), "out.txt"; got != want {
+		t.Fatalf("relName for a top-level output = %q, want %q", got, want)
+	}
+}
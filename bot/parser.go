@@ -42,3 +42,105 @@ func ParseContinuation(text string) string {
 	}
 	return strings.TrimSpace(matches[1])
 }
+
+// PermissionsCommand represents a parsed "@bot permissions ..." meta-command
+// for managing a task's PermissionStore from Slack.
+type PermissionsCommand struct {
+	Action string // "list", "revoke", "add", or "clear"
+	Arg    string // rule ID for "revoke", pattern (e.g. "Bash(git:*)") for "add"
+}
+
+// permissionsCommandPattern matches a continuation-stripped mention that
+// invokes the "permissions" meta-command, e.g. "permissions list" or
+// "permissions revoke perm-3".
+var permissionsCommandPattern = regexp.MustCompile(`(?i)^permissions\s+(list|revoke|add|clear)\b\s*(.*)$`)
+
+// ParsePermissionsCommand parses a continuation-stripped mention (see
+// ParseContinuation) as a "permissions" meta-command. Returns nil if text
+// isn't one.
+func ParsePermissionsCommand(text string) *PermissionsCommand {
+	matches := permissionsCommandPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if len(matches) < 3 {
+		return nil
+	}
+
+	return &PermissionsCommand{
+		Action: strings.ToLower(matches[1]),
+		Arg:    strings.TrimSpace(matches[2]),
+	}
+}
+
+// RulesCommand represents a parsed "/clod rules <task> list|revoke [arg]"
+// slash command for auditing/managing a task's PermissionStore from
+// wherever, without needing an active thread (see
+// Handler.handleRulesSlashCommand). Unlike "@bot permissions ...", which
+// operates on whatever task a thread is already associated with, this names
+// the task explicitly.
+type RulesCommand struct {
+	TaskName string
+	Action   string // "list" or "revoke"
+	Arg      string // rule ID, or "all", for "revoke"; empty for "list"
+}
+
+// rulesCommandPattern matches the text following the "rules" subcommand
+// keyword, e.g. "mytask list" or "mytask revoke perm-3" or "mytask revoke
+// all".
+var rulesCommandPattern = regexp.MustCompile(`(?i)^(\S+)\s+(list|revoke)\s*(\S*)$`)
+
+// ParseRulesCommand parses the text following "/clod rules" into a
+// RulesCommand. Returns nil if text doesn't match "<task> list" or "<task>
+// revoke [arg]".
+func ParseRulesCommand(text string) *RulesCommand {
+	matches := rulesCommandPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if len(matches) < 4 {
+		return nil
+	}
+
+	return &RulesCommand{
+		TaskName: strings.ToLower(matches[1]),
+		Action:   strings.ToLower(matches[2]),
+		Arg:      matches[3],
+	}
+}
+
+// ParsedCommand represents a parsed slash command invocation, e.g.
+// "/clod deprecation upstream-deprecation.md --branch=main --verbose".
+type ParsedCommand struct {
+	SubCommand   string // e.g. "deprecation"
+	Instructions string // positional words, joined back with spaces
+	Flags        map[string]string
+}
+
+// ParseSlashCommand parses the text portion of a slash command (everything
+// after "/clod"), separating flag-style arguments ("--branch=main",
+// "--verbose") from the positional words that make up the instructions.
+// The first positional word is taken as the subcommand name. Returns nil if
+// text has no subcommand at all.
+func ParseSlashCommand(text string) *ParsedCommand {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	parsed := &ParsedCommand{
+		SubCommand: strings.ToLower(fields[0]),
+		Flags:      make(map[string]string),
+	}
+
+	var instructions []string
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "--") {
+			instructions = append(instructions, field)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(field, "--"), "=")
+		if !hasValue {
+			value = "true"
+		}
+		parsed.Flags[name] = value
+	}
+	parsed.Instructions = strings.Join(instructions, " ")
+
+	return parsed
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	_ "embed"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/calebcase/oops"
 	"github.com/rs/zerolog"
+	"golang.org/x/sys/unix"
 )
 
 //go:embed permission_mcp.py
@@ -27,6 +31,14 @@ const (
 	MCPScriptName = "permission_mcp.py"
 	// MCPConfigName is the name of the MCP config file
 	MCPConfigName = "mcp_config.json"
+
+	// maxPermissionRequestLine bounds how much unterminated data
+	// dispatchRequestLines will buffer while waiting for a newline,
+	// mirroring the implicit cap bufio.Scanner used to provide. Without
+	// it a malformed or stuck writer that never sends '\n' could grow
+	// buf without bound instead of tripping a clear, reconnect-and-retry
+	// failure.
+	maxPermissionRequestLine = 1 << 20 // 1 MiB
 )
 
 // PermissionRequest represents a permission request from the Claude hook.
@@ -55,6 +67,22 @@ type PermissionFIFO struct {
 	responses     chan PermissionResponse
 	logger        zerolog.Logger
 	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+
+	// wakeMu guards wakeFD so wakeupReader's write and readRequests'
+	// teardown close can never interleave: either the write completes
+	// while the fd is still valid, or the teardown has already set
+	// wakeFD to -1 and the write is skipped. Without this, a write
+	// landing between another goroutine's close(2) and the OS recycling
+	// that fd number could wake up (or worse, write into) an unrelated
+	// file descriptor.
+	wakeMu sync.Mutex
+	// wakeFD is the eventfd readRequests' epoll loop is currently also
+	// watching, or -1 if readRequests isn't running. Close writes to it to
+	// unblock a pending epoll_wait immediately instead of leaving the
+	// goroutine parked until a writer happens to open the request FIFO
+	// (see readRequests and wakeupReader).
+	wakeFD int
 }
 
 // NewPermissionFIFO creates and initializes the permission FIFO.
@@ -129,7 +157,7 @@ func NewPermissionFIFO(taskPath string, runtimeSuffix string, agentsPromptPath s
 		return nil, oops.Trace(err)
 	}
 
-	return &PermissionFIFO{
+	pf := &PermissionFIFO{
 		taskPath:      taskPath,
 		runtimeSuffix: runtimeSuffix,
 		requestPath:   requestPath,
@@ -137,72 +165,231 @@ func NewPermissionFIFO(taskPath string, runtimeSuffix string, agentsPromptPath s
 		requests:      make(chan PermissionRequest, 10),
 		responses:     make(chan PermissionResponse, 10),
 		logger:        logger.With().Str("component", "permission_fifo").Logger(),
-	}, nil
+		wakeFD:        -1,
+	}
+
+	return pf, nil
 }
 
 // Start begins listening for permission requests and sending responses.
 func (p *PermissionFIFO) Start(ctx context.Context) {
 	ctx, p.cancel = context.WithCancel(ctx)
 
+	p.wg.Add(2)
+
 	// Read requests from FIFO
-	go p.readRequests(ctx)
+	go func() {
+		defer p.wg.Done()
+		p.readRequests(ctx)
+	}()
 
 	// Write responses to FIFO
-	go p.writeResponses(ctx)
+	go func() {
+		defer p.wg.Done()
+		p.writeResponses(ctx)
+	}()
 }
 
-// readRequests reads permission requests from the FIFO.
+// readRequests reads permission requests from the FIFO. It opens the FIFO
+// non-blocking and multiplexes it with a wakeup eventfd via epoll, so
+// Close (which writes to the eventfd, see wakeupReader) interrupts a
+// pending read immediately instead of leaving this goroutine parked until
+// some writer happens to open the FIFO. A blocking os.OpenFile +
+// bufio.Scanner can't be interrupted that way: there's no way to cancel a
+// pending open() or Read() on a FIFO from another goroutine.
+//
+// The FIFO is opened O_RDWR rather than O_RDONLY, even though we never
+// write to it: holding our own phantom write end open is what keeps a
+// FIFO reader from seeing an immediate EOF whenever no other writer is
+// currently connected (the common, idle state between requests). An
+// O_RDONLY reader would have epoll_wait return readable right away for
+// that EOF condition, making readRequests spin in a tight reopen loop
+// instead of actually waiting for a hook process to write a request.
 func (p *PermissionFIFO) readRequests(ctx context.Context) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to create epoll instance")
+		return
+	}
+	defer unix.Close(epfd)
+
+	wakeFD, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to create wakeup eventfd")
+		return
+	}
+	defer unix.Close(wakeFD)
+
+	if err := unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, wakeFD, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(wakeFD)}); err != nil {
+		p.logger.Error().Err(err).Msg("failed to register wakeup eventfd with epoll")
+		return
+	}
+
+	p.wakeMu.Lock()
+	p.wakeFD = wakeFD
+	p.wakeMu.Unlock()
+	defer func() {
+		p.wakeMu.Lock()
+		p.wakeFD = -1
+		p.wakeMu.Unlock()
+	}()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	fifoFD, err := unix.Open(p.requestPath, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to open request FIFO")
+		return
+	}
+	defer unix.Close(fifoFD)
+
+	if err := unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, fifoFD, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fifoFD)}); err != nil {
+		p.logger.Error().Err(err).Msg("failed to register request FIFO with epoll")
+		return
+	}
+
+	var buf []byte
+	p.pumpRequestFIFO(ctx, epfd, wakeFD, fifoFD, &buf)
+}
+
+// pumpRequestFIFO runs the epoll_wait loop for the request FIFO (fifoFD),
+// parsing newline-delimited PermissionRequest JSON as bytes arrive into buf
+// and dispatching them to p.requests. It returns once wakeFD becomes
+// readable (Close was called) or on an unrecoverable epoll/read error.
+func (p *PermissionFIFO) pumpRequestFIFO(ctx context.Context, epfd, wakeFD, fifoFD int, buf *[]byte) {
+	events := make([]unix.EpollEvent, 4)
+	readBuf := make([]byte, 4096)
+
 	for {
-		select {
-		case <-ctx.Done():
+		n, err := unix.EpollWait(epfd, events, -1)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			p.logger.Error().Err(err).Msg("epoll_wait failed on request FIFO")
 			return
-		default:
 		}
 
-		// Open FIFO for reading (blocks until writer connects)
-		// We need to open in non-blocking mode first to allow select to work
-		file, err := os.OpenFile(p.requestPath, os.O_RDONLY, 0)
-		if err != nil {
-			if ctx.Err() != nil {
+		for i := 0; i < n; i++ {
+			fd := int(events[i].Fd)
+			switch fd {
+			case wakeFD:
+				// Don't drain the eventfd: Close() may be racing to tear
+				// down everything right after this, and leaving the
+				// counter set is harmless since nothing reads it again.
 				return
+			case fifoFD:
+				for {
+					m, err := unix.Read(fifoFD, readBuf)
+					if err != nil {
+						if err == unix.EAGAIN {
+							break
+						}
+						p.logger.Error().Err(err).Msg("failed to read request FIFO")
+						return
+					}
+					if m == 0 {
+						// We hold our own write end open (see
+						// readRequests), so a genuine EOF here would mean
+						// even that end got closed; nothing more to read.
+						return
+					}
+					*buf = append(*buf, readBuf[:m]...)
+					if len(*buf) > maxPermissionRequestLine {
+						p.logger.Error().Int("bytes", len(*buf)).Msg("request FIFO line exceeded max size, dropping buffered data")
+						*buf = (*buf)[:0]
+						continue
+					}
+					if p.dispatchRequestLines(ctx, buf) {
+						return
+					}
+				}
 			}
-			p.logger.Error().Err(err).Msg("failed to open request FIFO")
-			continue
 		}
+	}
+}
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
-			}
+// dispatchRequestLines extracts and dispatches complete newline-terminated
+// PermissionRequest lines from the front of buf, leaving any trailing
+// partial line in place. It returns true if ctx was cancelled while trying
+// to send a request (the caller should stop reading).
+func (p *PermissionFIFO) dispatchRequestLines(ctx context.Context, buf *[]byte) bool {
+	for {
+		idx := bytes.IndexByte(*buf, '\n')
+		if idx < 0 {
+			return false
+		}
 
-			var req PermissionRequest
-			if err := json.Unmarshal([]byte(line), &req); err != nil {
-				p.logger.Error().Err(err).Str("line", line).Msg("failed to parse permission request")
-				continue
-			}
+		line := (*buf)[:idx]
+		*buf = (*buf)[idx+1:]
+		if len(line) == 0 {
+			continue
+		}
 
-			p.logger.Info().
-				Str("tool_name", req.ToolName).
-				Str("tool_use_id", req.ToolUseID).
-				Msg("received permission request")
+		var req PermissionRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			p.logger.Error().Err(err).Str("line", string(line)).Msg("failed to parse permission request")
+			continue
+		}
 
-			select {
-			case p.requests <- req:
-			case <-ctx.Done():
-				_ = file.Close()
-				return
-			}
+		p.logger.Info().
+			Str("tool_name", req.ToolName).
+			Str("tool_use_id", req.ToolUseID).
+			Msg("received permission request")
+
+		select {
+		case p.requests <- req:
+		case <-ctx.Done():
+			return true
 		}
+	}
+}
+
+// wakeupReader interrupts a pending epoll_wait in readRequests, if it's
+// currently running, by writing to its wakeup eventfd.
+func (p *PermissionFIFO) wakeupReader() {
+	p.wakeMu.Lock()
+	defer p.wakeMu.Unlock()
+
+	if p.wakeFD < 0 {
+		return
+	}
+
+	var val [8]byte
+	binary.LittleEndian.PutUint64(val[:], 1)
+	if _, err := unix.Write(p.wakeFD, val[:]); err != nil {
+		p.logger.Error().Err(err).Msg("failed to wake up request FIFO reader")
+	}
+}
 
-		if err := file.Close(); err != nil {
-			p.logger.Error().Err(err).Msg("failed to close request FIFO")
+// responseOpenRetryInterval is how often openResponseWriter retries
+// opening the response FIFO while no reader is connected.
+const responseOpenRetryInterval = 50 * time.Millisecond
+
+// openResponseWriter opens the response FIFO for writing, returning
+// promptly once ctx is cancelled instead of blocking in open(2)
+// indefinitely. A plain os.OpenFile(path, O_WRONLY) would block until a
+// reader connects with no way to interrupt it from another goroutine (the
+// same limitation that motivated readRequests' epoll-based rewrite), so
+// this polls a non-blocking open instead: O_WRONLY|O_NONBLOCK fails with
+// ENXIO exactly when no reader is connected yet, which we treat as "try
+// again shortly" rather than an error.
+func (p *PermissionFIFO) openResponseWriter(ctx context.Context) (*os.File, error) {
+	for {
+		fd, err := unix.Open(p.responsePath, unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		if err == nil {
+			return os.NewFile(uintptr(fd), p.responsePath), nil
+		}
+		if err != unix.ENXIO {
+			return nil, err
 		}
 
-		if ctx.Err() != nil {
-			return
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(responseOpenRetryInterval):
 		}
 	}
 }
@@ -214,8 +401,7 @@ func (p *PermissionFIFO) writeResponses(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case resp := <-p.responses:
-			// Open FIFO for writing (blocks until reader connects)
-			file, err := os.OpenFile(p.responsePath, os.O_WRONLY, 0)
+			file, err := p.openResponseWriter(ctx)
 			if err != nil {
 				if ctx.Err() != nil {
 					return
@@ -268,10 +454,21 @@ func (p *PermissionFIFO) Close() {
 		p.cancel()
 	}
 
+	// Interrupt a pending epoll_wait in readRequests immediately; without
+	// this it would stay parked until a writer happened to open the
+	// request FIFO (see readRequests).
+	p.wakeupReader()
+
 	// Remove the FIFOs
 	_ = os.Remove(p.requestPath)  // Ignore error if already removed
 	_ = os.Remove(p.responsePath) // Ignore error if already removed
 
+	// Wait for readRequests and writeResponses to actually exit before
+	// closing the channels they send on; otherwise a goroutine still
+	// mid-select on p.requests/p.responses could panic with "send on
+	// closed channel".
+	p.wg.Wait()
+
 	close(p.requests)
 	close(p.responses)
 
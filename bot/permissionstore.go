@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/rs/zerolog"
+)
+
+// PermissionRule is one remembered permission decision, as managed through
+// Slack (the "Allow Always"/"Allow Similar" buttons/reactions, or the
+// "@bot permissions" meta-commands), independent of the legacy opaque
+// claude.json allowedTools list (see PermissionRuleStore).
+type PermissionRule struct {
+	ID        string    `json:"id"`
+	Tool      string    `json:"tool"`              // e.g. "Bash", "Write"
+	Pattern   string    `json:"pattern,omitempty"` // inner pattern, e.g. "python:*", "src/**", "re:^git (status|log)", "under:./src"; empty matches all uses of Tool
+	Deny      bool      `json:"deny,omitempty"`    // if true, this is an explicit deny rule, checked before allow rules (see isPermissionAllowed)
+	Scope     string    `json:"scope"`             // "session", "task", or "global"
+	CreatedBy string    `json:"created_by"`        // Slack user ID
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // zero means never
+	MaxUses   int       `json:"max_uses,omitempty"`   // zero means unlimited
+	Uses      int       `json:"uses,omitempty"`       // incremented by RecordUse each time the rule is the one that allows a call
+}
+
+// expired reports whether the rule's TTL has passed as of now.
+func (r PermissionRule) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && r.ExpiresAt.Before(now)
+}
+
+// exhausted reports whether the rule has already been used MaxUses times.
+func (r PermissionRule) exhausted() bool {
+	return r.MaxUses > 0 && r.Uses >= r.MaxUses
+}
+
+// matches reports whether a tool call is covered by this rule's pattern
+// (regardless of whether it's an allow or a deny rule; see Deny), reusing
+// the same glob/regex/scope semantics as the legacy allowedTools patterns
+// (see matchesPermissionRule) so "python:*"/"src/**"/"re:..."/"under:..."
+// -style patterns behave identically whichever store granted them.
+func (r PermissionRule) matches(toolName string, toolInput map[string]any, taskPath string) bool {
+	legacy := r.Tool
+	if r.Pattern != "" {
+		legacy = fmt.Sprintf("%s(%s)", r.Tool, r.Pattern)
+	}
+	return matchesPermissionRule(legacy, toolName, toolInput, taskPath)
+}
+
+// permissionsFile is the on-disk shape of a PermissionStore's
+// .clod-runtime/permissions.json.
+type permissionsFile struct {
+	Rules []PermissionRule `json:"rules"`
+}
+
+// PermissionStore persists remembered permission rules for a single task
+// directory at .clod-runtime/permissions.json, giving Slack users a way to
+// list, add, and revoke them instead of having them live only as opaque
+// strings in claude.json or in-memory PolicyEngine rules that vanish on
+// restart. Unlike PermissionRuleStore, this file belongs entirely to the
+// bot (the claude CLI never writes to it), so a plain mutex plus an atomic
+// rename is enough; no flock is needed.
+type PermissionStore struct {
+	path     string
+	taskPath string
+	logger   zerolog.Logger
+
+	mu     sync.Mutex
+	rules  []PermissionRule // nil until first load
+	nextID atomic.Uint64
+}
+
+// NewPermissionStore creates a PermissionStore backed by path (typically
+// <taskPath>/.clod-runtime/permissions.json).
+func NewPermissionStore(path, taskPath string, logger zerolog.Logger) *PermissionStore {
+	return &PermissionStore{
+		path:     path,
+		taskPath: taskPath,
+		logger:   logger.With().Str("component", "permission_store").Logger(),
+	}
+}
+
+// AddRule records a new rule granted by createdBy, expiring after ttl (zero
+// means never) or after maxUses uses (zero means unlimited), and flushes it
+// to disk. deny marks it an explicit deny rule (see PermissionRule.Deny)
+// rather than an allow rule. It returns the stored rule, including its
+// generated ID.
+func (s *PermissionStore) AddRule(tool, pattern, scope, createdBy string, ttl time.Duration, maxUses int, deny bool) (PermissionRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return PermissionRule{}, err
+	}
+
+	rule := PermissionRule{
+		ID:        fmt.Sprintf("perm-%d", s.nextID.Add(1)),
+		Tool:      tool,
+		Pattern:   pattern,
+		Deny:      deny,
+		Scope:     scope,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+		MaxUses:   maxUses,
+	}
+	if ttl > 0 {
+		rule.ExpiresAt = rule.CreatedAt.Add(ttl)
+	}
+
+	s.rules = append(s.rules, rule)
+	if err := s.saveLocked(); err != nil {
+		s.rules = s.rules[:len(s.rules)-1]
+		return PermissionRule{}, err
+	}
+
+	return rule, nil
+}
+
+// RevokeRule removes the rule with the given ID, if present, and flushes the
+// change to disk. Returns false if no rule with that ID existed.
+func (s *PermissionStore) RevokeRule(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return false, err
+	}
+
+	kept := make([]PermissionRule, 0, len(s.rules))
+	removed := false
+	for _, r := range s.rules {
+		if r.ID == id {
+			removed = true
+			continue
+		}
+		kept = append(kept, r)
+	}
+	if !removed {
+		return false, nil
+	}
+
+	prev := s.rules
+	s.rules = kept
+	if err := s.saveLocked(); err != nil {
+		s.rules = prev
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Clear removes every rule and flushes the change to disk.
+func (s *PermissionStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return err
+	}
+
+	prev := s.rules
+	s.rules = nil
+	if err := s.saveLocked(); err != nil {
+		s.rules = prev
+		return err
+	}
+
+	return nil
+}
+
+// ListRules returns a copy of the current rules, pruning (and persisting the
+// removal of) any that have expired or run out of uses.
+func (s *PermissionStore) ListRules() ([]PermissionRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return nil, err
+	}
+
+	if err := s.pruneExpiredLocked(); err != nil {
+		return nil, err
+	}
+
+	return append([]PermissionRule(nil), s.rules...), nil
+}
+
+// RecordUse increments the use count of the rule with the given ID and
+// flushes the change to disk, so a count-bound rule (see
+// PermissionRule.MaxUses) is pruned once exhausted. A no-op if no rule with
+// that ID exists (e.g. it was revoked concurrently).
+func (s *PermissionStore) RecordUse(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return err
+	}
+
+	for i, r := range s.rules {
+		if r.ID != id {
+			continue
+		}
+		prev := r.Uses
+		s.rules[i].Uses++
+		if err := s.saveLocked(); err != nil {
+			s.rules[i].Uses = prev
+			return err
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// Matches reports whether any non-expired, non-exhausted allow rule covers
+// toolName's call with toolInput, ignoring deny rules (see
+// isPermissionAllowed, which applies deny precedence across both this store
+// and the legacy PermissionRuleStore). This enables "remember" rules to take
+// effect immediately within the same session. It does not record a use;
+// callers that actually grant the call should also call RecordUse.
+func (s *PermissionStore) Matches(toolName string, toolInput map[string]any) bool {
+	rules, err := s.ListRules()
+	if err != nil {
+		s.logger.Error().Err(err).Str("task_path", s.taskPath).Msg("failed to read permission store")
+		return false
+	}
+
+	for _, r := range rules {
+		if !r.Deny && !r.exhausted() && r.matches(toolName, toolInput, s.taskPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pruneExpiredLocked drops expired or exhausted rules and, if any were
+// dropped, persists the change. s.mu must be held.
+func (s *PermissionStore) pruneExpiredLocked() error {
+	now := time.Now()
+	kept := make([]PermissionRule, 0, len(s.rules))
+	pruned := false
+	for _, r := range s.rules {
+		if r.expired(now) || r.exhausted() {
+			pruned = true
+			continue
+		}
+		kept = append(kept, r)
+	}
+	if !pruned {
+		return nil
+	}
+	s.rules = kept
+	return s.saveLocked()
+}
+
+// ensureLoadedLocked lazily loads the rule list from disk. s.mu must be
+// held.
+func (s *PermissionStore) ensureLoadedLocked() error {
+	if s.rules != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.rules = []PermissionRule{}
+			return nil
+		}
+		return oops.Trace(err)
+	}
+
+	var file permissionsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return oops.Trace(err)
+	}
+	if file.Rules == nil {
+		file.Rules = []PermissionRule{}
+	}
+	s.rules = file.Rules
+
+	// Resume ID generation past the highest ID already on disk, so a
+	// restarted bot doesn't reuse an ID that's still referenced elsewhere
+	// (e.g. in a Slack message the user might "revoke" against later).
+	for _, r := range s.rules {
+		var n uint64
+		if _, err := fmt.Sscanf(r.ID, "perm-%d", &n); err == nil && n > s.nextID.Load() {
+			s.nextID.Store(n)
+		}
+	}
+
+	return nil
+}
+
+// saveLocked writes the current rule list to disk atomically: write to a
+// ".tmp" sibling, fsync, then rename(2) into place. s.mu must be held.
+func (s *PermissionStore) saveLocked() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return oops.Trace(err)
+	}
+
+	data, err := json.MarshalIndent(permissionsFile{Rules: s.rules}, "", "  ")
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return oops.Trace(err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return oops.Trace(err)
+	}
+	if err := f.Close(); err != nil {
+		return oops.Trace(err)
+	}
+
+	return oops.Trace(os.Rename(tmpPath, s.path))
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func newTestPermissionStore(t *testing.T) (*PermissionStore, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".clod-runtime", "permissions.json")
+
+	return NewPermissionStore(path, dir, zerolog.Nop()), path
+}
+
+func TestPermissionStoreAddListRevoke(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	rule, err := store.AddRule("Bash", "git:*", "task", "U123", 0, 0, false)
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if rule.ID == "" {
+		t.Fatal("expected a generated rule ID")
+	}
+
+	rules, err := store.ListRules()
+	if err != nil {
+		t.Fatalf("ListRules: %v", err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("got %d rules, want 1", len(rules))
+	}
+
+	removed, err := store.RevokeRule(rule.ID)
+	if err != nil {
+		t.Fatalf("RevokeRule: %v", err)
+	}
+	if !removed {
+		t.Fatal("expected RevokeRule to report the rule as removed")
+	}
+
+	rules, err = store.ListRules()
+	if err != nil {
+		t.Fatalf("ListRules: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Fatalf("got %d rules after revoke, want 0", len(rules))
+	}
+
+	removed, err = store.RevokeRule("no-such-id")
+	if err != nil {
+		t.Fatalf("RevokeRule unknown id: %v", err)
+	}
+	if removed {
+		t.Fatal("expected revoking an unknown ID to report false")
+	}
+}
+
+func TestPermissionStoreMatches(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	if _, err := store.AddRule("Bash", "git:*", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	if !store.Matches("Bash", map[string]any{"command": "git status"}) {
+		t.Error("expected git command to match Bash(git:*)")
+	}
+	if store.Matches("Bash", map[string]any{"command": "rm -rf /"}) {
+		t.Error("rm command should not match Bash(git:*)")
+	}
+	if store.Matches("Write", map[string]any{"file_path": "/tmp/x"}) {
+		t.Error("Write should not match a Bash-scoped rule")
+	}
+}
+
+func TestPermissionStoreMatchesGlobAndRegexRules(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	if _, err := store.AddRule("Bash", "git *", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if _, err := store.AddRule("Write", "**/*.go", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if _, err := store.AddRule("Bash", "re:^kubectl (get|describe)", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	if !store.Matches("Bash", map[string]any{"command": "git status"}) {
+		t.Error("expected \"git status\" to match the \"git *\" glob rule")
+	}
+	if store.Matches("Bash", map[string]any{"command": "gitx status"}) {
+		t.Error("\"gitx status\" should not match the \"git *\" glob rule")
+	}
+	if !store.Matches("Write", map[string]any{"file_path": "/home/user/project/src/main.go"}) {
+		t.Error("expected a .go path to match the \"**/*.go\" glob rule")
+	}
+	if store.Matches("Write", map[string]any{"file_path": "/home/user/project/src/main.py"}) {
+		t.Error("a .py path should not match the \"**/*.go\" glob rule")
+	}
+	if !store.Matches("Bash", map[string]any{"command": "kubectl get pods"}) {
+		t.Error("expected \"kubectl get pods\" to match the \"re:^kubectl (get|describe)\" rule")
+	}
+	if store.Matches("Bash", map[string]any{"command": "kubectl delete pod foo"}) {
+		t.Error("\"kubectl delete\" should not match the \"re:^kubectl (get|describe)\" rule")
+	}
+}
+
+// TestPermissionStoreMatchesScopedToPrimaryField verifies a "re:"/glob rule
+// is matched only against the tool's primary field (command, for Bash),
+// not every string-valued field of ToolInput: a real Bash call also carries
+// an LLM-generated "description", and a scoped rule must not fire just
+// because description happens to match while the actual command doesn't.
+func TestPermissionStoreMatchesScopedToPrimaryField(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	if _, err := store.AddRule("Bash", "re:^git (status|log)", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if _, err := store.AddRule("Bash", "git *", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	toolInput := map[string]any{
+		"command":     "rm -rf /",
+		"description": "git status",
+	}
+	if store.Matches("Bash", toolInput) {
+		t.Error("rule scoped to command must not match because an unrelated description field matches")
+	}
+}
+
+func TestPermissionStoreMatchesUnderScope(t *testing.T) {
+	store, path := newTestPermissionStore(t)
+	taskPath := filepath.Dir(filepath.Dir(path)) // matches newTestPermissionStore's dir
+
+	if _, err := store.AddRule("Read", "under:./src", "task", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	if !store.Matches("Read", map[string]any{"file_path": filepath.Join(taskPath, "src", "main.go")}) {
+		t.Error("expected a path under <taskPath>/src to match Read(under:./src)")
+	}
+	if store.Matches("Read", map[string]any{"file_path": filepath.Join(taskPath, "docs", "readme.md")}) {
+		t.Error("a path outside <taskPath>/src should not match Read(under:./src)")
+	}
+}
+
+func TestPermissionStoreDenyRule(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	rule, err := store.AddRule("Bash", "rm *", "task", "U123", 0, 0, true)
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if !rule.Deny {
+		t.Fatal("expected the stored rule to have Deny set")
+	}
+
+	// Matches only ever reports allow coverage; deny precedence is
+	// isPermissionAllowed's job (it checks Deny rules across both stores
+	// before any allow rule), not this store's in isolation.
+	if store.Matches("Bash", map[string]any{"command": "rm -rf /tmp/x"}) {
+		t.Error("Matches should not report a deny rule as an allow match")
+	}
+}
+
+func TestPermissionStoreRecordUseExhaustsRule(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	rule, err := store.AddRule("Bash", "git:*", "task", "U123", 0, 2, false)
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	for i := 0; i < rule.MaxUses; i++ {
+		if !store.Matches("Bash", map[string]any{"command": "git status"}) {
+			t.Fatalf("expected rule to still match on use %d", i+1)
+		}
+		if err := store.RecordUse(rule.ID); err != nil {
+			t.Fatalf("RecordUse: %v", err)
+		}
+	}
+
+	if store.Matches("Bash", map[string]any{"command": "git status"}) {
+		t.Error("expected rule to stop matching once MaxUses is reached")
+	}
+
+	rules, err := store.ListRules()
+	if err != nil {
+		t.Fatalf("ListRules: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("expected ListRules to prune the exhausted rule, got %d rules", len(rules))
+	}
+}
+
+func TestPermissionStorePersistsAcrossInstances(t *testing.T) {
+	store, path := newTestPermissionStore(t)
+
+	if _, err := store.AddRule("WebSearch", "", "global", "U123", 0, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	reloaded := NewPermissionStore(path, store.taskPath, zerolog.Nop())
+	rules, err := reloaded.ListRules()
+	if err != nil {
+		t.Fatalf("ListRules on reloaded store: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Tool != "WebSearch" {
+		t.Fatalf("rule didn't survive reload: %v", rules)
+	}
+}
+
+func TestPermissionStorePrunesExpiredRules(t *testing.T) {
+	store, _ := newTestPermissionStore(t)
+
+	if _, err := store.AddRule("Bash", "git:*", "task", "U123", time.Nanosecond, 0, false); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	rules, err := store.ListRules()
+	if err != nil {
+		t.Fatalf("ListRules: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Fatalf("expected expired rule to be pruned, got %v", rules)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyMatch narrows a PolicyRule to a subset of a tool's calls. An empty
+// PolicyMatch matches every call to the rule's tool.
+type PolicyMatch struct {
+	// CommandRegex matches against the Bash "command" input.
+	CommandRegex string `json:"command_regex,omitempty" yaml:"command_regex,omitempty"`
+	// FileRegex matches against the "file_path" input of Read/Write/Edit.
+	FileRegex string `json:"file_regex,omitempty" yaml:"file_regex,omitempty"`
+}
+
+// PolicyRule is one entry in a policy ruleset, e.g.:
+//
+//	{tool: "Bash", match: {command_regex: "^git (status|log|diff)"}, effect: "allow"}
+type PolicyRule struct {
+	// ID identifies the rule in the audit log. Defaults to "rule-<index>"
+	// (its position in the ruleset) if left blank.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Tool is the tool name this rule applies to, or "*" for any tool.
+	Tool string `json:"tool" yaml:"tool"`
+	// Match further narrows which calls to Tool this rule covers.
+	Match PolicyMatch `json:"match,omitempty" yaml:"match,omitempty"`
+	// Effect is "allow" or "deny".
+	Effect string `json:"effect" yaml:"effect"`
+	// TTL is how long the rule stays in effect, parsed with
+	// time.ParseDuration (e.g. "1h"). Empty means it never expires.
+	// Rules loaded from a PolicyConfig file are expected to leave this
+	// blank; it's primarily used by rules added at runtime (see AddRule).
+	TTL string `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	// Scope is "session", "task", or "global" (the default). It
+	// constrains the rule to the originating Slack session or task
+	// directory; see compiledRule.appliesToScope.
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+// PolicyConfig is the on-disk shape of a policy ruleset, loaded as JSON or
+// YAML (selected by file extension).
+type PolicyConfig struct {
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// compiledRule is a PolicyRule with its regexes compiled and, for rules
+// added at runtime via AddRule, its expiry and originating scope resolved.
+type compiledRule struct {
+	PolicyRule
+
+	commandRegex *regexp.Regexp
+	fileRegex    *regexp.Regexp
+
+	expiresAt time.Time // zero means never
+	taskPath  string    // set when Scope == "task"
+	sessionID string    // set when Scope == "session"
+}
+
+func compileRule(rule PolicyRule, id string) (*compiledRule, error) {
+	cr := &compiledRule{PolicyRule: rule}
+	cr.ID = id
+
+	if rule.Match.CommandRegex != "" {
+		re, err := regexp.Compile(rule.Match.CommandRegex)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+		cr.commandRegex = re
+	}
+	if rule.Match.FileRegex != "" {
+		re, err := regexp.Compile(rule.Match.FileRegex)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+		cr.fileRegex = re
+	}
+	if rule.TTL != "" {
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+		cr.expiresAt = time.Now().Add(ttl)
+	}
+
+	return cr, nil
+}
+
+// matchesRequest reports whether req (a Bash/Read/Write/Edit/... tool call)
+// is covered by this rule's tool + match fields.
+func (c *compiledRule) matchesRequest(req PermissionRequest) bool {
+	if c.Tool != "*" && c.Tool != req.ToolName {
+		return false
+	}
+
+	if c.commandRegex != nil {
+		cmd, ok := req.ToolInput["command"].(string)
+		if !ok || !c.commandRegex.MatchString(cmd) {
+			return false
+		}
+	}
+	if c.fileRegex != nil {
+		path, ok := req.ToolInput["file_path"].(string)
+		if !ok || !c.fileRegex.MatchString(path) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// appliesToScope reports whether this rule's scope covers the request's
+// originating session/task. Rules loaded from a PolicyConfig file are
+// global (Scope is blank or "global") and always apply.
+func (c *compiledRule) appliesToScope(req PermissionRequest, taskPath string) bool {
+	switch c.Scope {
+	case "", "global":
+		return true
+	case "task":
+		return c.taskPath == taskPath
+	case "session":
+		return c.sessionID == req.SessionID
+	default:
+		return false
+	}
+}
+
+// AuditEntry is one line of the policy engine's append-only audit log.
+type AuditEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	RequestHash string    `json:"request_hash"`
+	ToolName    string    `json:"tool_name"`
+	RuleID      string    `json:"rule_id"`
+	Decision    string    `json:"decision"`
+}
+
+// PolicyEngine evaluates incoming PermissionRequests against an ordered
+// ruleset loaded from a YAML/JSON file, plus rules added at runtime (e.g.
+// from a Slack "remember" button) that are scoped to a session or task and
+// may expire. Rules are evaluated first-match-wins, except that an explicit
+// "deny" always takes precedence over an earlier "allow" match. Every
+// decision is recorded to an append-only audit log.
+type PolicyEngine struct {
+	auditLogPath string
+	logger       zerolog.Logger
+
+	mu           sync.RWMutex
+	staticRules  []*compiledRule // loaded from PolicyConfig, evaluated last
+	dynamicRules []*compiledRule // added via AddRule, evaluated first (more specific)
+
+	nextRuleID atomic.Uint64
+}
+
+// NewPolicyEngine creates a PolicyEngine that appends decisions to
+// auditLogPath. The ruleset starts empty; load one with LoadConfig.
+func NewPolicyEngine(auditLogPath string, logger zerolog.Logger) *PolicyEngine {
+	return &PolicyEngine{
+		auditLogPath: auditLogPath,
+		logger:       logger.With().Str("component", "policy_engine").Logger(),
+	}
+}
+
+// LoadConfig (re)loads the ruleset from a JSON or YAML file (selected by
+// extension), replacing the current static rules. Rules without an
+// explicit ID are assigned "rule-<index>" based on their position in the
+// file.
+func (e *PolicyEngine) LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	var config PolicyConfig
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return oops.Trace(err)
+		}
+	default:
+		if err := json.Unmarshal(data, &config); err != nil {
+			return oops.Trace(err)
+		}
+	}
+
+	rules := make([]*compiledRule, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		id := rule.ID
+		if id == "" {
+			id = fmt.Sprintf("rule-%d", i)
+		}
+		cr, err := compileRule(rule, id)
+		if err != nil {
+			return oops.Trace(fmt.Errorf("rule %s: %w", id, err))
+		}
+		rules = append(rules, cr)
+	}
+
+	e.mu.Lock()
+	e.staticRules = rules
+	e.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the ruleset at path whenever it changes, until ctx is
+// cancelled. It's meant to be run in its own goroutine, mirroring
+// Authorizer.Watch.
+func (e *PolicyEngine) Watch(ctx context.Context, path string, logger zerolog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer watcher.Close()
+
+	// Watch the containing directory rather than the file itself so the
+	// watch survives editors that replace the file (write to a temp file,
+	// then rename over it) instead of writing in place.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return oops.Trace(err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := e.LoadConfig(path); err != nil {
+				logger.Error().Err(err).Str("path", path).Msg("failed to reload policy config")
+				continue
+			}
+			logger.Info().Str("path", path).Msg("reloaded policy config")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error().Err(err).Msg("policy config watcher error")
+		}
+	}
+}
+
+// Evaluate checks req against the ruleset, returning the decided effect
+// ("allow" or "deny"), the ID of the rule that decided it, and whether any
+// rule matched at all. Every evaluated decision is appended to the audit
+// log; an unmatched request (matched == false) is not logged, since the
+// caller still has to fall back to prompting the user.
+func (e *PolicyEngine) Evaluate(req PermissionRequest, taskPath string) (effect, ruleID string, matched bool) {
+	e.mu.Lock()
+	e.pruneExpiredLocked()
+	// Dynamic (session/task-scoped) rules are more specific than the
+	// static global ruleset, so they're consulted first.
+	rules := make([]*compiledRule, 0, len(e.dynamicRules)+len(e.staticRules))
+	rules = append(rules, e.dynamicRules...)
+	rules = append(rules, e.staticRules...)
+	e.mu.Unlock()
+
+	var first, firstDeny *compiledRule
+	for _, r := range rules {
+		if !r.appliesToScope(req, taskPath) || !r.matchesRequest(req) {
+			continue
+		}
+		if first == nil {
+			first = r
+		}
+		if r.Effect == "deny" && firstDeny == nil {
+			firstDeny = r
+		}
+	}
+
+	chosen := first
+	if firstDeny != nil {
+		chosen = firstDeny
+	}
+	if chosen == nil {
+		return "", "", false
+	}
+
+	e.audit(req, chosen.ID, chosen.Effect)
+
+	return chosen.Effect, chosen.ID, true
+}
+
+// AddRule adds a rule scoped to a session or task (granted from the Slack
+// "remember" interaction), optionally expiring after ttl. An empty ttl
+// means the rule lives for as long as the bot process does. It returns the
+// rule's ID for logging/audit purposes.
+func (e *PolicyEngine) AddRule(rule PolicyRule, taskPath, sessionID string, ttl time.Duration) (string, error) {
+	id := fmt.Sprintf("dynamic-%d", e.nextRuleID.Add(1))
+
+	cr, err := compileRule(rule, id)
+	if err != nil {
+		return "", oops.Trace(err)
+	}
+	if ttl > 0 {
+		cr.expiresAt = time.Now().Add(ttl)
+	}
+	switch cr.Scope {
+	case "task":
+		cr.taskPath = taskPath
+	case "session":
+		cr.sessionID = sessionID
+	}
+
+	e.mu.Lock()
+	e.dynamicRules = append(e.dynamicRules, cr)
+	e.mu.Unlock()
+
+	e.logger.Info().
+		Str("rule_id", id).
+		Str("tool", rule.Tool).
+		Str("effect", rule.Effect).
+		Str("scope", rule.Scope).
+		Dur("ttl", ttl).
+		Msg("added dynamic policy rule")
+
+	return id, nil
+}
+
+// pruneExpiredLocked drops dynamic rules whose TTL has passed. e.mu must be
+// held for writing.
+func (e *PolicyEngine) pruneExpiredLocked() {
+	if len(e.dynamicRules) == 0 {
+		return
+	}
+	kept := e.dynamicRules[:0]
+	now := time.Now()
+	for _, r := range e.dynamicRules {
+		if r.expiresAt.IsZero() || r.expiresAt.After(now) {
+			kept = append(kept, r)
+		}
+	}
+	e.dynamicRules = kept
+}
+
+// audit appends one decision to the audit log. Failures are logged but
+// otherwise ignored: a broken audit log shouldn't block the bot from
+// answering permission requests it already has a policy decision for.
+func (e *PolicyEngine) audit(req PermissionRequest, ruleID, decision string) {
+	if e.auditLogPath == "" {
+		return
+	}
+
+	entry := AuditEntry{
+		Timestamp:   time.Now(),
+		RequestHash: hashRequest(req),
+		ToolName:    req.ToolName,
+		RuleID:      ruleID,
+		Decision:    decision,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		e.logger.Error().Err(err).Msg("failed to marshal audit entry")
+		return
+	}
+
+	f, err := os.OpenFile(e.auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		e.logger.Error().Err(err).Str("path", e.auditLogPath).Msg("failed to open audit log")
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		e.logger.Error().Err(err).Msg("failed to write audit entry")
+	}
+}
+
+// hashRequest returns a stable content hash for a permission request, used
+// to correlate audit log entries without storing the (possibly sensitive)
+// raw tool input.
+func hashRequest(req PermissionRequest) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		// json.Marshal only fails on unsupported types; ToolInput comes
+		// from decoding JSON, so this can't happen in practice.
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// QuorumRule requires Required approvals from members of a Slack role
+// before a matching tool call is allowed, instead of resolving on the first
+// Allow/Deny click (see PolicyRule for the simpler single-decider case).
+// Unlike PolicyRule, it has no "deny" effect: a rule only ever gates a
+// request behind approvals, it never denies outright (use a PolicyRule for
+// that).
+type QuorumRule struct {
+	// ID identifies the rule in logs. Defaults to "quorum-<index>" (its
+	// position in the ruleset) if left blank.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Tool is the tool name this rule applies to, or "*" for any tool.
+	Tool string `json:"tool" yaml:"tool"`
+	// Match further narrows which calls to Tool this rule covers.
+	Match PolicyMatch `json:"match,omitempty" yaml:"match,omitempty"`
+	// Role is the Authorizer role (see AuthConfig.Roles) whose members may
+	// cast a vote; typically backed by a Slack subteam (e.g. "@sre").
+	Role string `json:"role" yaml:"role"`
+	// Required is the number of distinct Allow votes needed to approve the
+	// request. A single Deny vote from any eligible approver always
+	// short-circuits to deny, regardless of Required.
+	Required int `json:"required" yaml:"required"`
+	// Timeout is how long to wait for quorum before auto-denying, parsed
+	// with time.ParseDuration (e.g. "15m"). Empty means wait indefinitely.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// QuorumConfig is the on-disk shape of a quorum ruleset, loaded as JSON or
+// YAML (selected by file extension), the same as PolicyConfig.
+type QuorumConfig struct {
+	Rules []QuorumRule `json:"rules" yaml:"rules"`
+}
+
+// compiledQuorumRule is a QuorumRule with its regexes and timeout resolved.
+type compiledQuorumRule struct {
+	QuorumRule
+
+	commandRegex *regexp.Regexp
+	fileRegex    *regexp.Regexp
+	timeout      time.Duration
+}
+
+func compileQuorumRule(rule QuorumRule, id string) (*compiledQuorumRule, error) {
+	cr := &compiledQuorumRule{QuorumRule: rule}
+	cr.ID = id
+
+	if rule.Match.CommandRegex != "" {
+		re, err := regexp.Compile(rule.Match.CommandRegex)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+		cr.commandRegex = re
+	}
+	if rule.Match.FileRegex != "" {
+		re, err := regexp.Compile(rule.Match.FileRegex)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+		cr.fileRegex = re
+	}
+	if rule.Timeout != "" {
+		timeout, err := time.ParseDuration(rule.Timeout)
+		if err != nil {
+			return nil, oops.Trace(err)
+		}
+		cr.timeout = timeout
+	}
+	if rule.Required < 1 {
+		return nil, oops.New("required must be at least 1")
+	}
+
+	return cr, nil
+}
+
+// matchesRequest reports whether req is covered by this rule's tool + match
+// fields (see compiledRule.matchesRequest, which this mirrors).
+func (c *compiledQuorumRule) matchesRequest(req PermissionRequest) bool {
+	if c.Tool != "*" && c.Tool != req.ToolName {
+		return false
+	}
+
+	if c.commandRegex != nil {
+		cmd, ok := req.ToolInput["command"].(string)
+		if !ok || !c.commandRegex.MatchString(cmd) {
+			return false
+		}
+	}
+	if c.fileRegex != nil {
+		path, ok := req.ToolInput["file_path"].(string)
+		if !ok || !c.fileRegex.MatchString(path) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// QuorumPolicy holds a ruleset of QuorumRules loaded from a YAML/JSON file,
+// consulted after PolicyEngine and the legacy allowedTools list both decline
+// to auto-decide a permission request (see the permRequests case in
+// runClod). It has no dynamic (runtime-added) rules: quorum requirements are
+// an operator-configured control, not something a "remember" button grants.
+type QuorumPolicy struct {
+	mu    sync.RWMutex
+	rules []*compiledQuorumRule
+}
+
+// NewQuorumPolicy creates an empty QuorumPolicy. Load a ruleset with
+// LoadConfig.
+func NewQuorumPolicy() *QuorumPolicy {
+	return &QuorumPolicy{}
+}
+
+// LoadConfig (re)loads the ruleset from a JSON or YAML file (selected by
+// extension), replacing the current rules. Rules without an explicit ID are
+// assigned "quorum-<index>" based on their position in the file.
+func (q *QuorumPolicy) LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	var config QuorumConfig
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return oops.Trace(err)
+		}
+	default:
+		if err := json.Unmarshal(data, &config); err != nil {
+			return oops.Trace(err)
+		}
+	}
+
+	rules := make([]*compiledQuorumRule, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		id := rule.ID
+		if id == "" {
+			id = fmt.Sprintf("quorum-%d", i)
+		}
+		cr, err := compileQuorumRule(rule, id)
+		if err != nil {
+			return oops.Trace(fmt.Errorf("rule %s: %w", id, err))
+		}
+		rules = append(rules, cr)
+	}
+
+	q.mu.Lock()
+	q.rules = rules
+	q.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the ruleset at path whenever it changes, until ctx is
+// cancelled. It's meant to be run in its own goroutine, mirroring
+// PolicyEngine.Watch.
+func (q *QuorumPolicy) Watch(ctx context.Context, path string, logger zerolog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer watcher.Close()
+
+	// Watch the containing directory rather than the file itself so the
+	// watch survives editors that replace the file (write to a temp file,
+	// then rename over it) instead of writing in place.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return oops.Trace(err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := q.LoadConfig(path); err != nil {
+				logger.Error().Err(err).Str("path", path).Msg("failed to reload quorum config")
+				continue
+			}
+			logger.Info().Str("path", path).Msg("reloaded quorum config")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error().Err(err).Msg("quorum config watcher error")
+		}
+	}
+}
+
+// Evaluate returns the first rule covering req, if any.
+func (q *QuorumPolicy) Evaluate(req PermissionRequest) (*compiledQuorumRule, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for _, r := range q.rules {
+		if r.matchesRequest(req) {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// QuorumVote tracks the in-flight votes for a PendingPermission gated by a
+// QuorumRule, until Rule.Required Allow votes (or a single Deny) resolve it.
+type QuorumVote struct {
+	mu       sync.Mutex
+	Rule     *compiledQuorumRule
+	Votes    map[string]string // Slack user ID -> "allow" or "deny"
+	resolved bool              // set once a vote has crossed Rule.Required or cast a deny; see Tally
+
+	timeoutTimer *time.Timer // nil if Rule.Timeout is unset
+}
+
+// Tally records userID's vote and reports the outcome: allowed is the
+// current count of Allow votes, denied is true if this vote (or an earlier
+// one) was a deny, reached is true once Rule.Required Allow votes have been
+// cast, and shouldResolve is true only for the single call that first
+// observes denied or reached — every subsequent call for an
+// already-resolved vote (e.g. a third Allow arriving after a Required:2
+// rule was already satisfied) gets shouldResolve=false, so callers don't
+// resolve the same request twice.
+func (v *QuorumVote) Tally(userID, behavior string) (allowed int, denied, reached, shouldResolve bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.Votes[userID] = behavior
+	denied = behavior == "deny"
+	for _, b := range v.Votes {
+		if b == "allow" {
+			allowed++
+		}
+	}
+	reached = allowed >= v.Rule.Required
+
+	shouldResolve = (denied || reached) && !v.resolved
+	if shouldResolve {
+		v.resolved = true
+	}
+	return allowed, denied, reached, shouldResolve
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestQuorumPolicyEvaluateMatchesByToolAndCommand(t *testing.T) {
+	q := NewQuorumPolicy()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "quorum.json")
+	config := `{"rules": [{"tool": "Bash", "match": {"command_regex": "^kubectl .* -n prod"}, "role": "sre", "required": 2, "timeout": "15m"}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := q.LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	prodReq := PermissionRequest{ToolName: "Bash", ToolInput: map[string]any{"command": "kubectl delete pod foo -n prod"}}
+	rule, matched := q.Evaluate(prodReq)
+	if !matched {
+		t.Fatal("expected prod kubectl command to match quorum rule")
+	}
+	if rule.Role != "sre" || rule.Required != 2 {
+		t.Fatalf("got role=%q required=%d, want sre/2", rule.Role, rule.Required)
+	}
+	if rule.timeout.String() != "15m0s" {
+		t.Fatalf("got timeout %v, want 15m", rule.timeout)
+	}
+
+	devReq := PermissionRequest{ToolName: "Bash", ToolInput: map[string]any{"command": "kubectl delete pod foo -n dev"}}
+	if _, matched := q.Evaluate(devReq); matched {
+		t.Fatal("expected dev kubectl command not to match quorum rule")
+	}
+
+	otherTool := PermissionRequest{ToolName: "Write", ToolInput: map[string]any{"file_path": "/tmp/x"}}
+	if _, matched := q.Evaluate(otherTool); matched {
+		t.Fatal("expected non-Bash tool not to match quorum rule")
+	}
+}
+
+func TestCompileQuorumRuleRejectsZeroRequired(t *testing.T) {
+	if _, err := compileQuorumRule(QuorumRule{Tool: "Bash", Role: "sre"}, "quorum-0"); err == nil {
+		t.Fatal("expected an error for a rule with no Required count")
+	}
+}
+
+func TestQuorumVoteTallyReachesThreshold(t *testing.T) {
+	rule, err := compileQuorumRule(QuorumRule{Tool: "Bash", Role: "sre", Required: 2}, "quorum-0")
+	if err != nil {
+		t.Fatalf("compileQuorumRule: %v", err)
+	}
+	vote := &QuorumVote{Rule: rule, Votes: make(map[string]string)}
+
+	vote.Votes["alice"] = "allow"
+	if len(vote.Votes) != 1 {
+		t.Fatalf("got %d votes, want 1", len(vote.Votes))
+	}
+
+	vote.Votes["bob"] = "allow"
+	allowed := 0
+	for _, v := range vote.Votes {
+		if v == "allow" {
+			allowed++
+		}
+	}
+	if allowed != rule.Required {
+		t.Fatalf("got %d allow votes, want %d", allowed, rule.Required)
+	}
+}
+
+// TestQuorumVoteTallyResolvesOnce verifies that when more approvers than
+// Rule.Required cast an Allow vote in quick succession, only one of them
+// gets shouldResolve=true: without that, every vote past the threshold
+// would re-run the caller's resolution logic (a second response sent down
+// the same PermissionFIFO, a second Slack update, etc).
+func TestQuorumVoteTallyResolvesOnce(t *testing.T) {
+	rule, err := compileQuorumRule(QuorumRule{Tool: "Bash", Role: "sre", Required: 2}, "quorum-0")
+	if err != nil {
+		t.Fatalf("compileQuorumRule: %v", err)
+	}
+	vote := &QuorumVote{Rule: rule, Votes: make(map[string]string)}
+
+	const voters = 5
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	resolvedCount := 0
+
+	for i := 0; i < voters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, _, shouldResolve := vote.Tally(fmt.Sprintf("user%d", i), "allow")
+			if shouldResolve {
+				mu.Lock()
+				resolvedCount++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if resolvedCount != 1 {
+		t.Fatalf("got %d votes with shouldResolve=true, want exactly 1", resolvedCount)
+	}
+	if len(vote.Votes) != voters {
+		t.Fatalf("got %d recorded votes, want %d", len(vote.Votes), voters)
+	}
+}
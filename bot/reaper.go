@@ -0,0 +1,200 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+// ChildReaper reaps registered child processes on SIGCHLD so callers that
+// spawn a subprocess without owning an *exec.Cmd to Wait on themselves
+// (e.g. a detached helper process) aren't responsible for polling it, and
+// so such subprocesses don't accumulate as zombies during long bot uptimes
+// with many short-lived tasks.
+//
+// It deliberately only ever reaps pids that have been Register'd, via a
+// per-pid wait4(pid, WNOHANG) rather than the usual wait4(-1, WNOHANG):
+// this process also manages subprocesses the ordinary way via exec.Cmd.Wait
+// (see Runner), and a wait4(-1, ...) loop would race those calls for the
+// exit status of pids it was never told about, occasionally stealing it
+// out from under them ("wait: no child processes"). Scoping to registered
+// pids keeps ChildReaper a safe addition alongside that existing code.
+//
+// ChildReaper is a singleton (use GlobalChildReaper): SIGCHLD is
+// process-wide, so there's no useful way to have more than one.
+type ChildReaper struct {
+	mu       sync.Mutex
+	children map[int]func(syscall.WaitStatus)
+	logger   zerolog.Logger
+
+	// pidLocks serializes reapOne against Unregister for a given pid (see
+	// reapOne's doc comment): whichever of the two acquires a pid's lock
+	// first either performs the wait4 or removes the pid from children,
+	// so the other side never acts on stale state.
+	pidLocks map[int]*sync.Mutex
+
+	startOnce sync.Once
+}
+
+var globalReaper = &ChildReaper{
+	children: make(map[int]func(syscall.WaitStatus)),
+	pidLocks: make(map[int]*sync.Mutex),
+	logger:   zerolog.Nop(),
+}
+
+// GlobalChildReaper returns the process-wide ChildReaper.
+func GlobalChildReaper() *ChildReaper {
+	return globalReaper
+}
+
+// SetLogger configures the logger ChildReaper uses to report wait4 errors.
+func (r *ChildReaper) SetLogger(logger zerolog.Logger) {
+	r.mu.Lock()
+	r.logger = logger.With().Str("component", "child_reaper").Logger()
+	r.mu.Unlock()
+}
+
+// Register arranges for cb to be invoked with pid's exit status once it
+// terminates, and starts the SIGCHLD handler if this is the first
+// registration. It also checks once, immediately, whether pid has already
+// terminated: SIGCHLD is only delivered on the state transition, so a pid
+// that exited in the window between the caller spawning it and calling
+// Register would otherwise never get another chance to be reaped.
+func (r *ChildReaper) Register(pid int, cb func(status syscall.WaitStatus)) {
+	r.start()
+
+	r.mu.Lock()
+	r.children[pid] = cb
+	if _, ok := r.pidLocks[pid]; !ok {
+		r.pidLocks[pid] = &sync.Mutex{}
+	}
+	r.mu.Unlock()
+
+	r.reapOne(pid)
+}
+
+// Unregister stops tracking pid, e.g. because its owner is about to call
+// cmd.Wait itself and doesn't want a racing wait4 to steal the exit status
+// first. It blocks on pid's lock (see pidLocks), so it can't return while a
+// concurrent reapOne for the same pid is between checking that pid is still
+// registered and actually calling wait4 — without that, a SIGCHLD handled
+// just as Unregister runs could still have reapOne win the wait4 race
+// after Unregister has already returned, leaving the owner's own cmd.Wait
+// to fail with "no child processes" for a process that really did exit
+// cleanly.
+func (r *ChildReaper) Unregister(pid int) {
+	r.mu.Lock()
+	pidLock, ok := r.pidLocks[pid]
+	r.mu.Unlock()
+	if ok {
+		pidLock.Lock()
+		defer pidLock.Unlock()
+	}
+
+	r.mu.Lock()
+	delete(r.children, pid)
+	delete(r.pidLocks, pid)
+	r.mu.Unlock()
+}
+
+// start installs the SIGCHLD handler exactly once.
+func (r *ChildReaper) start() {
+	r.startOnce.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGCHLD)
+		go r.run(sigs)
+	})
+}
+
+func (r *ChildReaper) run(sigs chan os.Signal) {
+	for range sigs {
+		r.reapReady()
+	}
+}
+
+// reapReady checks every currently registered pid with a non-blocking,
+// per-pid wait4 and dispatches the callback for any that have exited. A
+// pid not yet terminated is simply left registered for the next SIGCHLD.
+func (r *ChildReaper) reapReady() {
+	r.mu.Lock()
+	pids := make([]int, 0, len(r.children))
+	for pid := range r.children {
+		pids = append(pids, pid)
+	}
+	r.mu.Unlock()
+
+	for _, pid := range pids {
+		r.reapOne(pid)
+	}
+}
+
+// reapOne performs a single non-blocking wait4 for pid and, if it has
+// exited, removes it from r.children and invokes its callback. ECHILD
+// (nothing left to wait for) also removes the entry: it means pid was
+// already reaped by something else, e.g. its owner's own cmd.Wait winning
+// the race against Unregister, and there'd be no exit status to ever
+// collect for it, so leaving it registered would just leak the entry and
+// waste a wait4 call on every future SIGCHLD.
+//
+// The wait4 call itself runs under pid's lock (see pidLocks), and
+// registration is re-checked after acquiring it: Unregister may have run
+// (and removed pid) while this call was waiting for the lock, in which case
+// there's nothing to do — the owner is about to call cmd.Wait itself.
+func (r *ChildReaper) reapOne(pid int) {
+	r.mu.Lock()
+	pidLock, ok := r.pidLocks[pid]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	pidLock.Lock()
+	defer pidLock.Unlock()
+
+	r.mu.Lock()
+	_, stillRegistered := r.children[pid]
+	r.mu.Unlock()
+	if !stillRegistered {
+		return
+	}
+
+	var ws syscall.WaitStatus
+	got, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil)
+	if err != nil {
+		if err != syscall.ECHILD {
+			logger := r.log()
+			logger.Error().Err(err).Int("pid", pid).Msg("wait4 failed while reaping child")
+			return
+		}
+		// Already reaped elsewhere; nothing more to learn about it.
+		r.mu.Lock()
+		delete(r.children, pid)
+		delete(r.pidLocks, pid)
+		r.mu.Unlock()
+		return
+	}
+	if got != pid {
+		// Not ready yet.
+		return
+	}
+
+	r.mu.Lock()
+	cb, ok := r.children[pid]
+	delete(r.children, pid)
+	delete(r.pidLocks, pid)
+	r.mu.Unlock()
+
+	if ok {
+		cb(ws)
+	}
+}
+
+// log returns a copy of the current logger, safe to use without holding
+// r.mu (zerolog.Logger is a small, copyable value).
+func (r *ChildReaper) log() zerolog.Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.logger
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestChildReaperReapsRegisteredChild spawns a short-lived process,
+// registers it, and asserts the SIGCHLD handler invokes the callback with
+// its exit status instead of the caller needing to call cmd.Wait itself.
+func TestChildReaperReapsRegisteredChild(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	done := make(chan syscall.WaitStatus, 1)
+	GlobalChildReaper().Register(cmd.Process.Pid, func(ws syscall.WaitStatus) {
+		done <- ws
+	})
+
+	select {
+	case ws := <-done:
+		if ws.ExitStatus() != 0 {
+			t.Errorf("exit status = %d, want 0", ws.ExitStatus())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was never invoked for reaped child")
+	}
+}
+
+// TestChildReaperRegisterAfterExit verifies that Register still reaps a
+// pid that already exited before Register was called, i.e. that it
+// doesn't depend solely on a future SIGCHLD (which the kernel only
+// delivers once, on the exit transition itself).
+func TestChildReaperRegisterAfterExit(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// Give the process time to exit and its SIGCHLD to be delivered and
+	// handled (with no registered callback yet, reapReady has nothing to
+	// do with it) before we register.
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan syscall.WaitStatus, 1)
+	GlobalChildReaper().Register(cmd.Process.Pid, func(ws syscall.WaitStatus) {
+		done <- ws
+	})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Register never reaped a pid that had already exited")
+	}
+}
+
+// TestChildReaperUnregisterAvoidsDoubleReap verifies that Unregister
+// removes the callback so a caller doing its own cmd.Wait afterward isn't
+// racing the reaper for the same exit status. The child sleeps briefly so
+// Register and Unregister both run well before it exits, instead of
+// racing Register's own immediate reap check (see Register's doc comment).
+func TestChildReaperUnregisterAvoidsDoubleReap(t *testing.T) {
+	cmd := exec.Command("sleep", "0.2")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	var mu sync.Mutex
+	called := false
+	GlobalChildReaper().Register(cmd.Process.Pid, func(syscall.WaitStatus) {
+		mu.Lock()
+		called = true
+		mu.Unlock()
+	})
+	GlobalChildReaper().Unregister(cmd.Process.Pid)
+
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	// Give any (unwanted) racing callback a moment to fire before checking.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if called {
+		t.Error("callback fired after Unregister; caller's own Wait should have been the sole reaper")
+	}
+}
+
+// TestChildReaperReapOneIgnoresStaleSnapshotAfterUnregister reproduces the
+// exact scenario reapReady's two-phase snapshot creates: a pid is included
+// in the snapshot taken under r.mu, then (before reapOne is actually called
+// for it) Unregister removes it. reapOne must still notice that once it
+// acquires the pid's lock, instead of blindly calling wait4 against a pid
+// the owner is now about to Wait on itself.
+func TestChildReaperReapOneIgnoresStaleSnapshotAfterUnregister(t *testing.T) {
+	cmd := exec.Command("sleep", "1")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	pid := cmd.Process.Pid
+
+	GlobalChildReaper().Register(pid, func(syscall.WaitStatus) {
+		t.Error("callback should not fire: pid was unregistered before reapOne ran")
+	})
+
+	// Simulate reapReady having already snapshotted pid into its pids list
+	// just before Unregister removes it.
+	GlobalChildReaper().Unregister(pid)
+	GlobalChildReaper().reapOne(pid)
+
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	// A non-nil error here is expected (the process exited via SIGKILL); what
+	// matters is that it's an *exec.ExitError describing that signal, not the
+	// "no child processes" error reapOne's wait4 would produce if it had
+	// wrongly reaped pid out from under this Wait.
+	if _, err := cmd.Process.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+// TestChildReaperUnregisterWaitsForInFlightReapOne verifies Unregister
+// blocks until a reapOne call already holding the pid's lock has finished,
+// instead of returning immediately and letting the caller's own cmd.Wait
+// race a wait4 that's already underway (see Unregister's doc comment).
+func TestChildReaperUnregisterWaitsForInFlightReapOne(t *testing.T) {
+	cmd := exec.Command("sleep", "1")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	pid := cmd.Process.Pid
+
+	GlobalChildReaper().Register(pid, func(syscall.WaitStatus) {})
+
+	r := GlobalChildReaper()
+	r.mu.Lock()
+	pidLock := r.pidLocks[pid]
+	r.mu.Unlock()
+
+	// Hold the pid's lock ourselves, standing in for a reapOne call that's
+	// already in flight (e.g. mid-wait4) when Unregister is invoked.
+	pidLock.Lock()
+
+	unregisterDone := make(chan struct{})
+	go func() {
+		r.Unregister(pid)
+		close(unregisterDone)
+	}()
+
+	select {
+	case <-unregisterDone:
+		pidLock.Unlock()
+		t.Fatal("Unregister returned while the pid's lock was still held by an in-flight reapOne")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pidLock.Unlock()
+	<-unregisterDone
+
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	if _, err := cmd.Process.Wait(); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
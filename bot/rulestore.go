@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/calebcase/oops"
+	"github.com/rs/zerolog"
+)
+
+// ruleFlushDelay is how long a PermissionRuleStore waits after a mutation
+// before flushing to disk. Several AddRule/RemoveRule calls arriving within
+// this window (e.g. concurrent permission approvals racing through the
+// buffered FIFO channels, see PermissionFIFO) are coalesced into a single
+// lock+read+write cycle instead of one per call.
+const ruleFlushDelay = 250 * time.Millisecond
+
+// PermissionRuleStore manages the legacy claude.json allowedTools list for
+// a single task directory. It replaces ad hoc read-modify-write access to
+// claude.json: every flush takes an flock(2) lock on a sibling ".lock"
+// file, re-reads the current contents fresh, and writes the result to a
+// ".tmp" file that is fsync'd and rename(2)'d into place, so a crash or a
+// racing writer (another clod process, the claude CLI itself) never leaves
+// claude.json half-written or loses that writer's changes. AddRule/RemoveRule
+// are recorded as pending adds/removes and applied on top of the freshly
+// read file at flush time, rather than overwriting it with a stale cached
+// list; reads (ListRules/HasRule) serve from an in-memory view kept in sync
+// with the last flush so they don't hit disk on every call. Mutations are
+// coalesced; see ruleFlushDelay.
+type PermissionRuleStore struct {
+	configPath string
+	lockPath   string
+	taskPath   string
+	logger     zerolog.Logger
+
+	mu      sync.Mutex
+	rules   []string        // last known allowedTools for taskPath; nil until first load
+	adds    map[string]bool // patterns added since the last flush
+	removes map[string]bool // patterns removed since the last flush
+	timer   *time.Timer
+}
+
+// NewPermissionRuleStore creates a PermissionRuleStore for the claude.json
+// at configPath, scoped to the project entry for taskPath.
+func NewPermissionRuleStore(configPath, taskPath string, logger zerolog.Logger) *PermissionRuleStore {
+	return &PermissionRuleStore{
+		configPath: configPath,
+		lockPath:   configPath + ".lock",
+		taskPath:   taskPath,
+		logger:     logger.With().Str("component", "permission_rule_store").Logger(),
+	}
+}
+
+// AddRule adds pattern to the allowedTools list if it isn't already
+// present, then schedules a coalesced flush to disk. The addition is
+// applied on top of whatever is on disk at flush time, so it can't clobber
+// a concurrent writer's changes to the same file.
+func (s *PermissionRuleStore) AddRule(pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return err
+	}
+
+	for _, r := range s.rules {
+		if r == pattern {
+			return nil
+		}
+	}
+	s.rules = append(s.rules, pattern)
+	delete(s.removes, pattern)
+	if s.adds == nil {
+		s.adds = map[string]bool{}
+	}
+	s.adds[pattern] = true
+	s.scheduleFlushLocked()
+
+	return nil
+}
+
+// RemoveRule removes pattern from the allowedTools list, if present, then
+// schedules a coalesced flush to disk. The removal is applied on top of
+// whatever is on disk at flush time, so it can't clobber a concurrent
+// writer's changes to the same file.
+func (s *PermissionRuleStore) RemoveRule(pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return err
+	}
+
+	kept := s.rules[:0]
+	removed := false
+	for _, r := range s.rules {
+		if r == pattern {
+			removed = true
+			continue
+		}
+		kept = append(kept, r)
+	}
+	if !removed {
+		return nil
+	}
+	s.rules = kept
+	delete(s.adds, pattern)
+	if s.removes == nil {
+		s.removes = map[string]bool{}
+	}
+	s.removes[pattern] = true
+	s.scheduleFlushLocked()
+
+	return nil
+}
+
+// ListRules returns a copy of the current allowedTools list.
+func (s *PermissionRuleStore) ListRules() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		return nil, err
+	}
+
+	return append([]string(nil), s.rules...), nil
+}
+
+// HasRule reports whether pattern is exactly present in the allowedTools
+// list. Callers wanting glob/prefix matching against a tool call (rather
+// than an exact remembered pattern) should use matchesPermissionRule over
+// ListRules instead.
+func (s *PermissionRuleStore) HasRule(pattern string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoadedLocked(); err != nil {
+		s.logger.Error().Err(err).Msg("failed to load rule store")
+		return false
+	}
+
+	for _, r := range s.rules {
+		if r == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Flush cancels any pending coalesced write and flushes the pending
+// add/remove set to disk synchronously.
+func (s *PermissionRuleStore) Flush() error {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	adds, removes := s.pendingLocked()
+	s.mu.Unlock()
+
+	return s.flushAndCache(adds, removes)
+}
+
+// pendingLocked returns copies of the current pending add/remove sets and
+// clears them. s.mu must be held.
+func (s *PermissionRuleStore) pendingLocked() (adds, removes map[string]bool) {
+	adds, s.adds = s.adds, nil
+	removes, s.removes = s.removes, nil
+	return adds, removes
+}
+
+// flushAndCache flushes adds/removes to disk and, on success, updates the
+// in-memory read cache (s.rules) to match what was actually written.
+func (s *PermissionRuleStore) flushAndCache(adds, removes map[string]bool) error {
+	merged, err := s.flush(adds, removes)
+	if err != nil {
+		// Put the pending changes back so the next flush retries them.
+		s.mu.Lock()
+		for p := range adds {
+			if s.adds == nil {
+				s.adds = map[string]bool{}
+			}
+			s.adds[p] = true
+		}
+		for p := range removes {
+			if s.removes == nil {
+				s.removes = map[string]bool{}
+			}
+			s.removes[p] = true
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Lock()
+	s.rules = merged
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ensureLoadedLocked lazily loads the cached rule list from disk. s.mu must
+// be held.
+func (s *PermissionRuleStore) ensureLoadedLocked() error {
+	if s.rules != nil {
+		return nil
+	}
+
+	var rules []string
+	err := s.withLock(func() error {
+		config, err := s.readConfigLocked()
+		if err != nil {
+			return err
+		}
+		rules = allowedToolsOf(projectOf(config, s.taskPath))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if rules == nil {
+		rules = []string{}
+	}
+	s.rules = rules
+
+	return nil
+}
+
+// scheduleFlushLocked arranges for the current rules to be written to disk
+// after ruleFlushDelay, coalescing with any mutations made in the meantime.
+// s.mu must be held.
+func (s *PermissionRuleStore) scheduleFlushLocked() {
+	if s.timer != nil {
+		return
+	}
+	s.timer = time.AfterFunc(ruleFlushDelay, func() {
+		s.mu.Lock()
+		s.timer = nil
+		adds, removes := s.pendingLocked()
+		s.mu.Unlock()
+
+		if err := s.flushAndCache(adds, removes); err != nil {
+			s.logger.Error().Err(err).Msg("failed to flush permission rule store")
+		}
+	})
+}
+
+// flush takes the file lock, re-reads claude.json fresh (so it doesn't
+// clobber fields written by another process, e.g. another clod process or
+// the claude CLI itself), applies adds/removes on top of whatever
+// allowedTools it finds there, writes the merged result back atomically,
+// and returns that merged list.
+func (s *PermissionRuleStore) flush(adds, removes map[string]bool) ([]string, error) {
+	var merged []string
+
+	err := s.withLock(func() error {
+		config, err := s.readConfigLocked()
+		if err != nil {
+			return err
+		}
+
+		project := projectOf(config, s.taskPath)
+		current := allowedToolsOf(project)
+
+		merged = make([]string, 0, len(current)+len(adds))
+		seen := make(map[string]bool, len(current))
+		for _, r := range current {
+			if removes[r] {
+				continue
+			}
+			if !seen[r] {
+				merged = append(merged, r)
+				seen[r] = true
+			}
+		}
+		for r := range adds {
+			if !seen[r] {
+				merged = append(merged, r)
+				seen[r] = true
+			}
+		}
+
+		tools := make([]any, len(merged))
+		for i, r := range merged {
+			tools[i] = r
+		}
+		project["allowedTools"] = tools
+
+		return s.writeConfigLocked(config)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// withLock runs fn while holding an flock(2) exclusive lock on s.lockPath.
+func (s *PermissionRuleStore) withLock(fn func() error) error {
+	lockFile, err := os.OpenFile(s.lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	defer lockFile.Close()
+
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX); err != nil {
+		return oops.Trace(err)
+	}
+	defer syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+// readConfigLocked reads and parses claude.json. The caller must hold the
+// file lock. A missing file reads as an empty config rather than an error,
+// since claude.json doesn't exist until the claude CLI or this store first
+// writes to it.
+func (s *PermissionRuleStore) readConfigLocked() (map[string]any, error) {
+	data, err := os.ReadFile(s.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]any{}, nil
+		}
+		return nil, oops.Trace(err)
+	}
+
+	config := map[string]any{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	return config, nil
+}
+
+// writeConfigLocked marshals config and writes it to configPath atomically:
+// write to a ".tmp" sibling, fsync, then rename(2) over the real path. The
+// caller must hold the file lock.
+func (s *PermissionRuleStore) writeConfigLocked(config map[string]any) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	tmpPath := s.configPath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return oops.Trace(err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return oops.Trace(err)
+	}
+	if err := f.Close(); err != nil {
+		return oops.Trace(err)
+	}
+
+	if err := os.Rename(tmpPath, s.configPath); err != nil {
+		return oops.Trace(err)
+	}
+
+	return nil
+}
+
+// projectOf returns the project entry for taskPath within config, creating
+// it (and the enclosing "projects" map) if absent.
+func projectOf(config map[string]any, taskPath string) map[string]any {
+	projects, _ := config["projects"].(map[string]any)
+	if projects == nil {
+		projects = map[string]any{}
+		config["projects"] = projects
+	}
+
+	project, _ := projects[taskPath].(map[string]any)
+	if project == nil {
+		project = map[string]any{}
+		projects[taskPath] = project
+	}
+
+	return project
+}
+
+// allowedToolsOf extracts the allowedTools string list from a project
+// entry, skipping any non-string entries.
+func allowedToolsOf(project map[string]any) []string {
+	raw, _ := project["allowedTools"].([]any)
+	tools := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if s, ok := r.(string); ok {
+			tools = append(tools, s)
+		}
+	}
+
+	return tools
+}
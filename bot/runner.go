@@ -1,21 +1,46 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/calebcase/clod/bot/metrics"
 	"github.com/calebcase/oops"
 	"github.com/creack/pty"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// defaultRetryLimit is how many additional attempts Start makes after a
+	// transient failure before giving up.
+	defaultRetryLimit = 3
+	// defaultBackoff is the initial delay before the first retry.
+	defaultBackoff = 15 * time.Second
+	// defaultMaxBackoff caps the exponential backoff between retries.
+	defaultMaxBackoff = 4 * time.Minute
+	// defaultMaxProcs caps how many clod processes may run concurrently.
+	defaultMaxProcs = 4
+
+	// transportStreamJSON is the default Transport: clod is driven over a
+	// PTY using stream-json for both input and output.
+	transportStreamJSON = "stream-json"
+	// transportJSONRPC2 is the opt-in ("canary") Transport: clod is driven
+	// headlessly over stdio using JSON-RPC 2.0, including permission
+	// prompts. Selected via Runner.TransportKind, the CLOD_TRANSPORT
+	// environment variable, or the bot's --rpc flag.
+	transportJSONRPC2 = "jsonrpc2"
 )
 
 // Runner executes clod processes.
@@ -24,6 +49,34 @@ type Runner struct {
 	permissionMode   string
 	agentsPromptPath string
 	logger           zerolog.Logger
+
+	// RetryLimit is how many additional attempts Start makes after a
+	// transient failure (spawn error, PTY failure, or an exit with no
+	// session_id ever captured) before returning the failure to the caller.
+	RetryLimit int
+	// Backoff is the initial delay before the first retry; subsequent
+	// retries double it, capped at MaxBackoff.
+	Backoff time.Duration
+	// MaxBackoff caps the exponential backoff between retries.
+	MaxBackoff time.Duration
+	// MaxProcs caps the number of clod processes running concurrently.
+	// Start blocks until a slot is free.
+	MaxProcs int
+	// TransportKind selects how clod is driven: transportStreamJSON (the
+	// default PTY/stream-json pipe) or transportJSONRPC2 (the headless
+	// JSON-RPC 2.0 canary). Defaults to the CLOD_TRANSPORT environment
+	// variable if set, otherwise transportStreamJSON.
+	TransportKind string
+	// SnippetCacheBytes caps the total size of each task's on-disk,
+	// content-addressed tool_result snippet cache (see snippetCache).
+	// Defaults to defaultSnippetCacheBytes.
+	SnippetCacheBytes int64
+
+	// sem gates concurrent clod processes; sized to MaxProcs at construction.
+	sem chan struct{}
+
+	// snippetCaches holds one *snippetCache per task path, created lazily.
+	snippetCaches sync.Map
 }
 
 // NewRunner creates a new Runner.
@@ -33,12 +86,75 @@ func NewRunner(
 	agentsPromptPath string,
 	logger zerolog.Logger,
 ) *Runner {
-	return &Runner{
-		timeout:          timeout,
-		permissionMode:   permissionMode,
-		agentsPromptPath: agentsPromptPath,
-		logger:           logger.With().Str("component", "runner").Logger(),
+	transportKind := transportStreamJSON
+	if os.Getenv("CLOD_TRANSPORT") == transportJSONRPC2 {
+		transportKind = transportJSONRPC2
 	}
+
+	r := &Runner{
+		timeout:           timeout,
+		permissionMode:    permissionMode,
+		agentsPromptPath:  agentsPromptPath,
+		logger:            logger.With().Str("component", "runner").Logger(),
+		RetryLimit:        defaultRetryLimit,
+		Backoff:           defaultBackoff,
+		MaxBackoff:        defaultMaxBackoff,
+		MaxProcs:          defaultMaxProcs,
+		TransportKind:     transportKind,
+		SnippetCacheBytes: defaultSnippetCacheBytes,
+	}
+	r.sem = make(chan struct{}, r.MaxProcs)
+	return r
+}
+
+// snippetCacheFor returns the snippet cache for a task path, creating it if
+// this is the first time the path has been seen.
+func (r *Runner) snippetCacheFor(taskPath string) *snippetCache {
+	if v, ok := r.snippetCaches.Load(taskPath); ok {
+		return v.(*snippetCache)
+	}
+	c := newSnippetCache(taskPath, r.SnippetCacheBytes)
+	actual, _ := r.snippetCaches.LoadOrStore(taskPath, c)
+	return actual.(*snippetCache)
+}
+
+// SnippetContent reads a previously cached tool_result snippet by hash.
+func (r *Runner) SnippetContent(taskPath, hash string) ([]byte, error) {
+	return r.snippetCacheFor(taskPath).Get(hash)
+}
+
+// SnippetSize reports a cached snippet's byte length, for deciding whether
+// (and how) to paginate it (see postToolSnippet).
+func (r *Runner) SnippetSize(taskPath, hash string) (int64, error) {
+	return r.snippetCacheFor(taskPath).Size(hash)
+}
+
+// SnippetRange streams a slice of a cached snippet from disk, for "Show
+// more" / "Show head/tail only" pagination (see handleSnippetAction).
+func (r *Runner) SnippetRange(taskPath, hash string, offset, length int64) ([]byte, error) {
+	return r.snippetCacheFor(taskPath).ReadRange(hash, offset, length)
+}
+
+// SnippetPath returns a cached snippet's on-disk path, for a "Download raw"
+// upload of the unmodified file.
+func (r *Runner) SnippetPath(taskPath, hash string) string {
+	return r.snippetCacheFor(taskPath).Path(hash)
+}
+
+// SnippetStats aggregates snippet cache usage across every task directory
+// this Runner has served.
+func (r *Runner) SnippetStats() SnippetStats {
+	var total SnippetStats
+	r.snippetCaches.Range(func(_, v any) bool {
+		s := v.(*snippetCache).Stats()
+		total.Entries += s.Entries
+		total.TotalBytes += s.TotalBytes
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Evictions += s.Evictions
+		return true
+	})
+	return total
 }
 
 // Result represents the result of a clod execution.
@@ -132,16 +248,27 @@ type TextDelta struct {
 }
 
 // RunningTask represents a clod task that is currently executing.
+//
+// A handful of fields (cmd, transport, cancel, permissionFIFO) are swapped
+// out in place when Runner.Start retries a failed attempt, so access to
+// them is guarded by mu.
 type RunningTask struct {
+	mu             sync.Mutex
 	cmd            *exec.Cmd
-	pty            *os.File
-	output         chan string
-	done           chan *Result
+	transport      Transport
 	cancel         context.CancelFunc
-	sessionID      string
-	taskPath       string // The path to the task directory.
-	logger         zerolog.Logger
 	permissionFIFO *PermissionFIFO
+
+	output    chan string
+	done      chan *Result
+	sessionID string
+	taskPath  string // The path to the task directory.
+	logger    zerolog.Logger
+
+	// lastInputTS is the chat message timestamp behind the most recently
+	// forwarded input (see RecordInputTS), so Handler can correlate a
+	// later edit or delete of that same message back to it.
+	lastInputTS string
 }
 
 // InputMessage represents a user input message in stream-json format.
@@ -171,7 +298,7 @@ type ImageSource struct {
 	Data      string `json:"data"`       // Base64 encoded image data
 }
 
-// SendInput writes text to the running task's PTY in stream-json format.
+// SendInput sends text to the running task's transport.
 func (t *RunningTask) SendInput(text string) error {
 	return t.SendInputWithImages(text, nil)
 }
@@ -182,10 +309,15 @@ type ImageData struct {
 	Data      []byte // Raw image bytes
 }
 
-// SendInputWithImages writes text and optional images to the running task's PTY.
+// SendInputWithImages sends text and optional images to the running task's
+// transport.
 func (t *RunningTask) SendInputWithImages(text string, images []ImageData) error {
-	if t.pty == nil {
-		return oops.New("pty is closed")
+	t.mu.Lock()
+	transport := t.transport
+	t.mu.Unlock()
+
+	if transport == nil {
+		return oops.New("transport is closed")
 	}
 
 	// Build content blocks - images first, then text.
@@ -212,19 +344,11 @@ func (t *RunningTask) SendInputWithImages(text string, images []ImageData) error
 		},
 	}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return oops.Trace(err)
-	}
-
 	t.logger.Debug().
 		Int("num_images", len(images)).
-		Int("json_len", len(data)).
 		Msg("sending input to claude")
 
-	// Write JSON line to PTY
-	_, err = t.pty.Write(append(data, '\n'))
-	if err != nil {
+	if err := transport.Send(msg); err != nil {
 		return oops.Trace(err)
 	}
 	return nil
@@ -235,18 +359,38 @@ func (t *RunningTask) Output() <-chan string {
 	return t.output
 }
 
-// PermissionRequests returns the channel for receiving permission requests from the FIFO.
+// PermissionRequests returns the channel for receiving permission requests.
+// For the default PTY transport these arrive over PermissionFIFO's
+// out-of-band FIFO; transports that carry permission requests in-band
+// (see PermissionTransport) are asked directly instead.
 func (t *RunningTask) PermissionRequests() <-chan PermissionRequest {
-	if t.permissionFIFO == nil {
-		return nil
+	t.mu.Lock()
+	permFIFO := t.permissionFIFO
+	transport := t.transport
+	t.mu.Unlock()
+
+	if permFIFO != nil {
+		return permFIFO.Requests()
 	}
-	return t.permissionFIFO.Requests()
+	if pt, ok := transport.(PermissionTransport); ok {
+		return pt.PermissionRequests()
+	}
+	return nil
 }
 
 // SendPermissionResponse sends a response to a permission request.
 func (t *RunningTask) SendPermissionResponse(resp PermissionResponse) {
-	if t.permissionFIFO != nil {
-		t.permissionFIFO.SendResponse(resp)
+	t.mu.Lock()
+	permFIFO := t.permissionFIFO
+	transport := t.transport
+	t.mu.Unlock()
+
+	if permFIFO != nil {
+		permFIFO.SendResponse(resp)
+		return
+	}
+	if pt, ok := transport.(PermissionTransport); ok {
+		pt.SendPermissionResponse(resp)
 	}
 }
 
@@ -257,16 +401,48 @@ func (t *RunningTask) Done() <-chan *Result {
 
 // Cancel cancels the running task.
 func (t *RunningTask) Cancel() {
-	if t.cancel != nil {
-		t.cancel()
+	t.mu.Lock()
+	cancel := t.cancel
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
 	}
 }
 
 // SessionID returns the session ID once captured.
 func (t *RunningTask) GetSessionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return t.sessionID
 }
 
+// RecordInputTS remembers ts as the chat message timestamp behind the input
+// most recently forwarded via SendInput, so a later edit or delete of that
+// message can be correlated back to it (see Handler.HandleMessageChanged
+// and HandleMessageDeleted).
+func (t *RunningTask) RecordInputTS(ts string) {
+	t.mu.Lock()
+	t.lastInputTS = ts
+	t.mu.Unlock()
+}
+
+// CancelPendingInput reports whether ts is still the most recently recorded
+// input message and, if so, clears it so a duplicate delete event is a
+// no-op. clod has already read whatever was written to its stdin pipe by
+// the time this returns true, so it can't un-send those bytes; callers are
+// expected to follow up with their own correction message telling Claude to
+// disregard it (see Handler.HandleMessageDeleted).
+func (t *RunningTask) CancelPendingInput(ts string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ts == "" || t.lastInputTS != ts {
+		return false
+	}
+	t.lastInputTS = ""
+	return true
+}
+
 // readAllowedTools reads the allowed tools from the task's claude.json config.
 func readAllowedTools(taskPath string) []string {
 	configPath := filepath.Join(taskPath, ".clod", "claude", "claude.json")
@@ -306,13 +482,91 @@ func readAllowedTools(taskPath string) []string {
 	return tools
 }
 
-// Start begins executing clod in a task directory with the given prompt.
-// If sessionID is provided, it resumes an existing session.
-// Returns a RunningTask that can be used to send input and receive output.
-func (r *Runner) Start(
+// attempt holds the state of a single clod process launch. Runner.Start
+// may create several of these in sequence when retrying.
+type attempt struct {
+	runCtx    context.Context
+	cancel    context.CancelFunc
+	cmd       *exec.Cmd
+	transport Transport
+	permFIFO  *PermissionFIFO // nil when transport carries permissions in-band (see PermissionTransport).
+}
+
+// isRetryableStartErr reports whether a failure to launch clod (FIFO setup,
+// MCP config, or the PTY spawn itself) should be retried.
+func isRetryableStartErr(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// isRetryableExit reports whether a clod process that ran to completion
+// should be retried: only exits that never produced a session_id and
+// weren't the result of cancellation/timeout or an assistant-reported
+// error are considered transient.
+func isRetryableExit(runCtx context.Context, sessionID string, isError bool) bool {
+	if runCtx.Err() != nil {
+		return false
+	}
+	if isError {
+		return false
+	}
+	return sessionID == ""
+}
+
+// taskOutcome classifies a finished task's error for the clod_task_invocations_total
+// metric label, matching the "timed out"/"cancelled" phrasing used elsewhere
+// (see Handler.notifyTaskResult) since Runner has no typed sentinel errors.
+func taskOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case strings.Contains(err.Error(), "timed out"):
+		return "timed_out"
+	case strings.Contains(err.Error(), "cancelled"):
+		return "cancelled"
+	default:
+		return "failed"
+	}
+}
+
+// nextBackoff doubles delay, capped at max.
+func nextBackoff(delay, max time.Duration) time.Duration {
+	delay *= 2
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// registerChild registers pid with the global ChildReaper so the spawned
+// clod process doesn't accumulate as a zombie if runAttempt never reaches
+// its own cmd.Wait() for it. The callback only fires if the reaper wins
+// that race; there's nothing left to do with the exit status at that
+// point, so it just logs.
+func (r *Runner) registerChild(pid int) {
+	GlobalChildReaper().Register(pid, func(status syscall.WaitStatus) {
+		r.logger.Debug().Int("pid", pid).Int("exit_status", status.ExitStatus()).
+			Msg("clod process reaped by child reaper")
+	})
+}
+
+// launch starts a single clod attempt using the Transport selected by
+// r.TransportKind.
+func (r *Runner) launch(
 	ctx context.Context,
 	taskPath, prompt, sessionID string,
-) (*RunningTask, error) {
+) (*attempt, error) {
+	if r.TransportKind == transportJSONRPC2 {
+		return r.launchJSONRPC2(ctx, taskPath, prompt, sessionID)
+	}
+	return r.launchPTY(ctx, taskPath, prompt, sessionID)
+}
+
+// launchPTY starts a single clod attempt: it creates a fresh permission FIFO
+// (and therefore a fresh runtime suffix) and spawns clod under a PTY.
+func (r *Runner) launchPTY(
+	ctx context.Context,
+	taskPath, prompt, sessionID string,
+) (*attempt, error) {
 	// Create command with timeout context.
 	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
 
@@ -424,212 +678,478 @@ func (r *Runner) Start(
 		return nil, oops.Trace(err)
 	}
 
+	// Register with the global reaper so the clod process is still reaped
+	// if runAttempt never reaches cmd.Wait() for it (e.g. it's abandoned
+	// after a panic). runAttempt unregisters before calling cmd.Wait()
+	// itself, so the two never race for the same exit status.
+	r.registerChild(cmd.Process.Pid)
+
+	return &attempt{
+		runCtx:    runCtx,
+		cancel:    cancel,
+		cmd:       cmd,
+		transport: newPttyTransport(ptmx),
+		permFIFO:  permFIFO,
+	}, nil
+}
+
+// launchJSONRPC2 starts a single clod attempt headlessly: clod is spawned
+// with --rpc and driven over stdin/stdout using JSON-RPC 2.0, including
+// permission prompts, so no PermissionFIFO/MCP config is needed.
+func (r *Runner) launchJSONRPC2(
+	ctx context.Context,
+	taskPath, prompt, sessionID string,
+) (*attempt, error) {
+	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	args := []string{
+		"-p",
+		"--rpc",
+		"--verbose",
+	}
+
+	allowedTools := readAllowedTools(taskPath)
+	for _, tool := range allowedTools {
+		args = append(args, "--allowedTools", tool)
+	}
+	if len(allowedTools) > 0 {
+		r.logger.Debug().
+			Strs("allowed_tools", allowedTools).
+			Msg("passing saved allowed tools to claude")
+	}
+
+	if r.permissionMode != "" && r.permissionMode != "default" {
+		args = append(args, "--permission-mode", r.permissionMode)
+	}
+	if sessionID != "" {
+		args = append(args, "--resume", sessionID)
+	}
+	args = append(args, prompt)
+
+	r.logger.Debug().
+		Str("task_path", taskPath).
+		Str("session_id", sessionID).
+		Strs("args", args).
+		Msg("starting clod with jsonrpc2 transport")
+
+	//nolint:gosec
+	cmd := exec.CommandContext(runCtx, "clod", args...)
+	cmd.Dir = taskPath
+	cmd.Env = append(os.Environ(), "CLOD_NONINTERACTIVE=true")
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, oops.Trace(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, oops.Trace(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, oops.Trace(err)
+	}
+
+	// See the matching call in launchPTY for why this is safe alongside
+	// runAttempt's own cmd.Wait().
+	r.registerChild(cmd.Process.Pid)
+
+	return &attempt{
+		runCtx:    runCtx,
+		cancel:    cancel,
+		cmd:       cmd,
+		transport: newJSONRPC2Transport(stdin, stdout, r.logger),
+	}, nil
+}
+
+// swapIn installs a (possibly retried) attempt as the task's active process,
+// tearing down whatever attempt preceded it.
+func (t *RunningTask) swapIn(a *attempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cmd = a.cmd
+	t.transport = a.transport
+	t.cancel = a.cancel
+	t.permissionFIFO = a.permFIFO
+}
+
+// Start begins executing clod in a task directory with the given prompt.
+// If sessionID is provided, it resumes an existing session.
+//
+// Transient failures (spawn errors, PTY open failures, or a process exit
+// that never produced a session_id) are retried up to RetryLimit times
+// with exponential backoff; each retry tears down the failed attempt's
+// PermissionFIFO/MCP config and gets a fresh runtime suffix. Start blocks
+// until a slot under MaxProcs is free.
+//
+// Returns a RunningTask that can be used to send input and receive output.
+func (r *Runner) Start(
+	ctx context.Context,
+	taskPath, prompt, sessionID string,
+) (*RunningTask, error) {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, oops.Trace(ctx.Err())
+	}
+
+	a, err := r.launch(ctx, taskPath, prompt, sessionID)
+	if err != nil {
+		<-r.sem
+		return nil, oops.Trace(err)
+	}
+
 	task := &RunningTask{
-		cmd:            cmd,
-		pty:            ptmx,
+		cmd:            a.cmd,
+		transport:      a.transport,
+		cancel:         a.cancel,
+		permissionFIFO: a.permFIFO,
 		output:         make(chan string, 100),
 		done:           make(chan *Result, 1),
-		cancel:         cancel,
 		sessionID:      sessionID,
 		taskPath:       taskPath,
 		logger:         r.logger,
-		permissionFIFO: permFIFO,
 	}
 
-	// Start permission FIFO listener
-	permFIFO.Start(runCtx)
+	if a.permFIFO != nil {
+		a.permFIFO.Start(a.runCtx)
+	}
+
+	go r.serve(ctx, task, a, taskPath, prompt, sessionID)
 
-	// Read from PTY and parse stream-json in background
-	go func() {
-		defer close(task.output)
-		defer close(task.done)
-		defer func() { _ = ptmx.Close() }()
-		defer permFIFO.Close()
+	return task, nil
+}
 
-		var outputBuilder strings.Builder
-		// Track tool_use IDs to their names and inputs so we can show context in results.
-		type toolInfo struct {
-			Name  string
-			Input map[string]any
+// serve reads clod's stream-json output for the current attempt and, on a
+// retryable failure, relaunches clod and keeps going — transparent to the
+// caller, who only observes task.Output()/task.Done().
+func (r *Runner) serve(
+	ctx context.Context,
+	task *RunningTask,
+	a *attempt,
+	taskPath, prompt, sessionID string,
+) {
+	defer close(task.output)
+	defer close(task.done)
+	defer func() { <-r.sem }()
+
+	var outputBuilder strings.Builder
+	backoff := r.Backoff
+	attemptsUsed := 0
+	started := time.Now()
+	taskLabel := filepath.Base(taskPath)
+
+	ctx, span := tracer().Start(ctx, "clod.task", trace.WithAttributes(
+		attribute.String("task.name", taskLabel),
+	))
+	defer span.End()
+
+	finish := func(result *Result) {
+		result.SessionID = task.GetSessionID()
+		result.Output = outputBuilder.String()
+
+		metrics.TaskDuration.WithLabelValues(taskLabel).Observe(time.Since(started).Seconds())
+		metrics.TaskInvocations.WithLabelValues(taskLabel, taskOutcome(result.Error)).Inc()
+		if result.Error != nil {
+			span.RecordError(result.Error)
+		}
+
+		task.done <- result
+	}
+
+	for {
+		result := r.runAttempt(ctx, task, a, &outputBuilder)
+
+		if !isRetryableExit(a.runCtx, task.GetSessionID(), result.isError) || attemptsUsed >= r.RetryLimit {
+			finish(&Result{Error: result.err})
+			return
 		}
-		toolInfos := make(map[string]toolInfo)
-		scanner := bufio.NewScanner(ptmx)
-		// Increase buffer size for long lines
-		buf := make([]byte, 0, 64*1024)
-		scanner.Buffer(buf, 1024*1024)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
+
+		// Relaunch, retrying the relaunch itself if it fails to spawn, until
+		// RetryLimit attempts are exhausted.
+		var next *attempt
+		for {
+			attemptsUsed++
+			r.logger.Warn().
+				Int("attempt", attemptsUsed).
+				Dur("backoff", backoff).
+				Msg("clod exited without a session_id, retrying")
+
+			select {
+			case <-ctx.Done():
+				finish(&Result{Error: oops.New("clod execution was cancelled")})
+				return
+			case <-time.After(backoff):
 			}
+			backoff = nextBackoff(backoff, r.MaxBackoff)
 
-			var msg StreamMessage
-			if err := json.Unmarshal([]byte(line), &msg); err != nil {
-				r.logger.Debug().
-					Str("line", line).
-					Err(err).
-					Msg("failed to parse stream-json line")
-				continue
+			launched, err := r.launch(ctx, taskPath, prompt, sessionID)
+			if err == nil {
+				next = launched
+				break
 			}
+			if !isRetryableStartErr(err) || attemptsUsed >= r.RetryLimit {
+				finish(&Result{Error: oops.Trace(err)})
+				return
+			}
+			r.logger.Warn().Err(err).Int("attempt", attemptsUsed).Msg("retry launch failed, retrying again")
+		}
 
-			// Extract session ID if present
-			if msg.SessionID != "" && task.sessionID == "" {
+		task.swapIn(next)
+		if next.permFIFO != nil {
+			next.permFIFO.Start(next.runCtx)
+		}
+		a = next
+	}
+}
+
+// attemptResult summarizes the outcome of a single runAttempt call.
+type attemptResult struct {
+	err     error
+	isError bool // Claude reported an is_error:true result message.
+}
+
+// runAttempt reads a single clod attempt's transport until it exits,
+// streaming content to task.output and accumulating it into outputBuilder.
+// ctx carries the task's root span (see serve), so each tool_use/tool_result
+// pair can be recorded as a child span.
+func (r *Runner) runAttempt(ctx context.Context, task *RunningTask, a *attempt, outputBuilder *strings.Builder) attemptResult {
+	defer func() { _ = a.transport.Close() }()
+	if a.permFIFO != nil {
+		defer a.permFIFO.Close()
+	}
+
+	var res attemptResult
+	taskLabel := filepath.Base(task.taskPath)
+
+	// Track tool_use IDs to their names, inputs, and in-flight span (see
+	// "tool_use"/"tool_result" below) so we can show context in results.
+	type toolInfo struct {
+		Name  string
+		Input map[string]any
+		Span  trace.Span
+	}
+	toolInfos := make(map[string]toolInfo)
+	defer func() {
+		// Close out any tool call whose result never arrived (e.g. the
+		// attempt was cut short), so it isn't left open forever.
+		for _, info := range toolInfos {
+			if info.Span != nil {
+				info.Span.End()
+			}
+		}
+	}()
+
+	for {
+		msg, err := a.transport.Recv()
+		if err != nil {
+			break
+		}
+
+		// Extract session ID if present
+		if msg.SessionID != "" && task.GetSessionID() == "" {
+			task.mu.Lock()
+			task.sessionID = msg.SessionID
+			task.mu.Unlock()
+			r.logger.Debug().
+				Str("session_id", msg.SessionID).
+				Msg("captured session ID")
+		}
+
+		// Handle different message types.
+		switch msg.Type {
+		case "system":
+			// System messages include init with session_id.
+			if msg.Subtype == "init" && msg.SessionID != "" {
+				task.mu.Lock()
 				task.sessionID = msg.SessionID
+				task.mu.Unlock()
 				r.logger.Debug().
-					Str("session_id", task.sessionID).
-					Msg("captured session ID")
+					Str("session_id", msg.SessionID).
+					Msg("captured session ID from system init")
 			}
-
-			// Handle different message types.
-			switch msg.Type {
-			case "system":
-				// System messages include init with session_id.
-				if msg.Subtype == "init" && msg.SessionID != "" {
-					task.sessionID = msg.SessionID
-					r.logger.Debug().
-						Str("session_id", task.sessionID).
-						Msg("captured session ID from system init")
-				}
-			case "assistant":
-				// Assistant messages contain text output and tool_use requests.
-				if msg.Message != nil {
-					for _, block := range msg.Message.Content {
-						switch block.Type {
-						case "text":
-							if block.Text != "" {
-								outputBuilder.WriteString(block.Text)
-								select {
-								case task.output <- block.Text:
-								default:
-									r.logger.Warn().Msg("output channel full, dropping message")
-								}
-							}
-						case "tool_use":
-							// Track tool ID → name and input for showing context in results.
-							toolInfos[block.ID] = toolInfo{
-								Name:  block.Name,
-								Input: block.Input,
+		case "assistant":
+			// Assistant messages contain text output and tool_use requests.
+			if msg.Message != nil {
+				for _, block := range msg.Message.Content {
+					switch block.Type {
+					case "text":
+						if block.Text != "" {
+							outputBuilder.WriteString(block.Text)
+							select {
+							case task.output <- block.Text:
+							default:
+								r.logger.Warn().Msg("output channel full, dropping message")
 							}
-							// Log tool use but don't send to Slack - we'll show a summary
-							// with the result instead (avoids duplicate "Using tool" + "result" messages).
-							r.logger.Debug().
-								Str("tool_id", block.ID).
-								Str("tool_name", block.Name).
-								Msg("tool use requested")
 						}
+					case "tool_use":
+						_, span := tracer().Start(ctx, "tool."+block.Name, trace.WithAttributes(
+							attribute.String("tool.name", block.Name),
+						))
+
+						// Track tool ID → name, input, and span for showing
+						// context in results and closing the span once the
+						// matching tool_result arrives.
+						toolInfos[block.ID] = toolInfo{
+							Name:  block.Name,
+							Input: block.Input,
+							Span:  span,
+						}
+						metrics.ToolInvocations.WithLabelValues(block.Name).Inc()
+						// Log tool use but don't send to Slack - we'll show a summary
+						// with the result instead (avoids duplicate "Using tool" + "result" messages).
+						r.logger.Debug().
+							Str("tool_id", block.ID).
+							Str("tool_name", block.Name).
+							Msg("tool use requested")
 					}
 				}
-			case "user":
-				// User messages contain tool results.
-				if msg.Message != nil {
-					for _, block := range msg.Message.Content {
-						if block.Type == "tool_result" {
-							contentText := block.GetContentText()
-							if contentText == "" {
-								continue
-							}
-							info := toolInfos[block.ToolUseID]
-							contentLen := len(contentText)
-							r.logger.Debug().
-								Str("tool_use_id", block.ToolUseID).
-								Str("tool_name", info.Name).
-								Bool("is_error", block.IsError).
-								Int("content_len", contentLen).
-								Msg("received tool result")
-							outputBuilder.WriteString(contentText)
-
-							// Send tool results to Slack:
-							// - Short Bash output (<=500 bytes): inline code block
-							// - Everything else: summary line + collapsible snippet
-							const maxInlineLen = 500
-							trimmedContent := strings.TrimRight(contentText, " \t\n\r")
-
-							var outputMsg string
-							if info.Name == "Bash" && contentLen <= maxInlineLen {
-								// Short Bash output: inline code block.
-								outputMsg = fmt.Sprintf("\n```\n%s\n```", trimmedContent)
-							} else {
-								// Show summary + upload as expandable snippet.
-								// Use __SNIPPET__ prefix so handler can upload as collapsible file.
-								// Format: __SNIPPET__toolName\x00inputJSON\x00content
-								inputJSON, _ := json.Marshal(info.Input)
-								outputMsg = fmt.Sprintf("__SNIPPET__%s\x00%s\x00%s", info.Name, inputJSON, trimmedContent)
-							}
+			}
+		case "user":
+			// User messages contain tool results.
+			if msg.Message != nil {
+				for _, block := range msg.Message.Content {
+					if block.Type == "tool_result" {
+						contentText := block.GetContentText()
+						if contentText == "" {
+							continue
+						}
+						info := toolInfos[block.ToolUseID]
+						contentLen := len(contentText)
+						r.logger.Debug().
+							Str("tool_use_id", block.ToolUseID).
+							Str("tool_name", info.Name).
+							Bool("is_error", block.IsError).
+							Int("content_len", contentLen).
+							Msg("received tool result")
+						outputBuilder.WriteString(contentText)
+
+						if info.Span != nil {
+							info.Span.SetAttributes(attribute.Bool("tool.is_error", block.IsError))
+							info.Span.End()
+							delete(toolInfos, block.ToolUseID)
+						}
 
-							select {
-							case task.output <- outputMsg:
+						// Send tool results to Slack:
+						// - Short Bash output (<=500 bytes): inline code block
+						// - Everything else: summary line + collapsible snippet
+						const maxInlineLen = 500
+						trimmedContent := strings.TrimRight(contentText, " \t\n\r")
+
+						var outputMsg string
+						if info.Name == "Bash" && contentLen <= maxInlineLen {
+							// Short Bash output: inline code block.
+							outputMsg = fmt.Sprintf("\n```\n%s\n```", trimmedContent)
+						} else {
+							// Show summary + upload as expandable snippet.
+							// Use __SNIPPET__ prefix so handler can upload as collapsible file.
+							// Format: __SNIPPET__toolName\x00inputJSON\x00hash\x00content
+							// (hash is empty if caching failed, disabling pagination for
+							// this one snippet but not the inline summary+upload).
+							inputJSON, _ := json.Marshal(info.Input)
+							hash, dup, cacheErr := r.snippetCacheFor(task.taskPath).Store(info.Name, string(inputJSON), trimmedContent)
+							switch {
+							case cacheErr != nil:
+								r.logger.Warn().Err(cacheErr).Msg("failed to cache tool snippet")
+								outputMsg = fmt.Sprintf("__SNIPPET__%s\x00%s\x00%s\x00%s", info.Name, inputJSON, "", trimmedContent)
+							case dup:
+								// Identical content already cached; the handler
+								// fetches it by hash instead of re-sending it.
+								// Format: __SNIPPET_REF__toolName\x00inputJSON\x00hash
+								outputMsg = fmt.Sprintf("__SNIPPET_REF__%s\x00%s\x00%s", info.Name, inputJSON, hash)
 							default:
-								r.logger.Warn().Msg("output channel full, dropping tool result")
+								outputMsg = fmt.Sprintf("__SNIPPET__%s\x00%s\x00%s\x00%s", info.Name, inputJSON, hash, trimmedContent)
 							}
 						}
+
+						select {
+						case task.output <- outputMsg:
+						default:
+							r.logger.Warn().Msg("output channel full, dropping tool result")
+						}
 					}
 				}
-			case "content_block_delta":
-				// Partial streaming output. Send immediately for responsive feedback.
-				if msg.ContentBlockDelta != nil &&
-					msg.ContentBlockDelta.Delta != nil &&
-					msg.ContentBlockDelta.Delta.Text != "" {
-					text := msg.ContentBlockDelta.Delta.Text
-					outputBuilder.WriteString(text)
-					select {
-					case task.output <- text:
-					default:
-						r.logger.Warn().Msg("output channel full, dropping delta")
-					}
-				}
-			case "result":
-				// Final result with stats.
-				r.logger.Info().
-					Str("subtype", msg.Subtype).
-					Float64("cost_usd", msg.TotalCostUSD).
-					Int("duration_ms", msg.DurationMS).
-					Int("num_turns", msg.NumTurns).
-					Bool("is_error", msg.IsError).
-					Msg("task result")
-				if msg.Result != "" {
-					outputBuilder.WriteString(msg.Result)
-				}
-				// Send stats as JSON for special formatting by handler.
-				// Use __STATS__ prefix so handler can detect and format with blocks.
-				statsJSON := fmt.Sprintf(
-					"__STATS__{\"is_error\":%t,\"duration_ms\":%d,\"num_turns\":%d,\"cost_usd\":%.6f}",
-					msg.IsError,
-					msg.DurationMS,
-					msg.NumTurns,
-					msg.TotalCostUSD,
-				)
+			}
+		case "content_block_delta":
+			// Partial streaming output. Send immediately for responsive feedback.
+			if msg.ContentBlockDelta != nil &&
+				msg.ContentBlockDelta.Delta != nil &&
+				msg.ContentBlockDelta.Delta.Text != "" {
+				text := msg.ContentBlockDelta.Delta.Text
+				outputBuilder.WriteString(text)
 				select {
-				case task.output <- statsJSON:
+				case task.output <- text:
 				default:
-					r.logger.Warn().Msg("output channel full, dropping stats")
+					r.logger.Warn().Msg("output channel full, dropping delta")
 				}
 			}
+		case "result":
+			// Final result with stats.
+			r.logger.Info().
+				Str("subtype", msg.Subtype).
+				Float64("cost_usd", msg.TotalCostUSD).
+				Int("duration_ms", msg.DurationMS).
+				Int("num_turns", msg.NumTurns).
+				Bool("is_error", msg.IsError).
+				Msg("task result")
+
+			metrics.TaskCostUSD.WithLabelValues(taskLabel).Observe(msg.TotalCostUSD)
+			metrics.TaskNumTurns.WithLabelValues(taskLabel).Observe(float64(msg.NumTurns))
+
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.Float64("task.cost_usd", msg.TotalCostUSD),
+				attribute.Int("task.num_turns", msg.NumTurns),
+				attribute.Bool("task.is_error", msg.IsError),
+			)
+
+			if msg.IsError {
+				res.isError = true
+			}
+			if msg.Result != "" {
+				outputBuilder.WriteString(msg.Result)
+			}
+			// Send stats as JSON for special formatting by handler.
+			// Use __STATS__ prefix so handler can detect and format with blocks.
+			statsJSON := fmt.Sprintf(
+				"__STATS__{\"is_error\":%t,\"duration_ms\":%d,\"num_turns\":%d,\"cost_usd\":%.6f}",
+				msg.IsError,
+				msg.DurationMS,
+				msg.NumTurns,
+				msg.TotalCostUSD,
+			)
+			select {
+			case task.output <- statsJSON:
+			default:
+				r.logger.Warn().Msg("output channel full, dropping stats")
+			}
 		}
+	}
 
-		// Wait for process to complete
-		err := cmd.Wait()
-
-		result := &Result{
-			SessionID: task.sessionID,
-			Output:    outputBuilder.String(),
-		}
+	// Unregister before Wait so the reaper's SIGCHLD-driven wait4 doesn't
+	// race this call for the exit status (see ChildReaper.Unregister).
+	GlobalChildReaper().Unregister(a.cmd.Process.Pid)
 
-		if err != nil {
-			// Check if it was a timeout
-			if runCtx.Err() == context.DeadlineExceeded {
-				result.Error = oops.New("clod execution timed out after %v", r.timeout)
-			} else if runCtx.Err() == context.Canceled {
-				result.Error = oops.New("clod execution was cancelled")
-			} else {
-				result.Error = oops.Trace(err)
-			}
+	// Wait for process to complete
+	err := a.cmd.Wait()
+	if err != nil {
+		// Check if it was a timeout
+		if a.runCtx.Err() == context.DeadlineExceeded {
+			res.err = oops.New("clod execution timed out after %v", r.timeout)
+		} else if a.runCtx.Err() == context.Canceled {
+			res.err = oops.New("clod execution was cancelled")
+		} else {
+			res.err = oops.Trace(err)
 		}
+	}
 
-		task.done <- result
-	}()
-
-	return task, nil
+	return res
 }
 
 // Kill terminates a running process by its PID.
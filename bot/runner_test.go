@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRunningTaskCancelPendingInput(t *testing.T) {
+	task := &RunningTask{}
+
+	if task.CancelPendingInput("1234.5678") {
+		t.Fatal("expected no pending input before RecordInputTS")
+	}
+
+	task.RecordInputTS("1234.5678")
+
+	if !task.CancelPendingInput("1234.5678") {
+		t.Fatal("expected the recorded ts to cancel")
+	}
+
+	if task.CancelPendingInput("1234.5678") {
+		t.Fatal("expected a second cancel of the same ts to be a no-op")
+	}
+}
+
+func TestRunningTaskCancelPendingInputIgnoresStaleTS(t *testing.T) {
+	task := &RunningTask{}
+
+	task.RecordInputTS("1111.1111")
+	task.RecordInputTS("2222.2222")
+
+	if task.CancelPendingInput("1111.1111") {
+		t.Fatal("expected a superseded ts not to cancel")
+	}
+	if !task.CancelPendingInput("2222.2222") {
+		t.Fatal("expected the latest ts to cancel")
+	}
+}
+
+// TestRunnerRegisterChildReapsOnAbandon verifies that a pid registered via
+// Runner.registerChild (as launchPTY/launchJSONRPC2 do on spawn) is still
+// reaped by the global ChildReaper even if nothing ever calls cmd.Wait on
+// it, the scenario registerChild exists to guard against.
+func TestRunnerRegisterChildReapsOnAbandon(t *testing.T) {
+	r := &Runner{logger: zerolog.Nop()}
+
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	r.registerChild(cmd.Process.Pid)
+
+	deadline := time.After(2 * time.Second)
+	for {
+		GlobalChildReaper().mu.Lock()
+		_, stillRegistered := GlobalChildReaper().children[cmd.Process.Pid]
+		GlobalChildReaper().mu.Unlock()
+		if !stillRegistered {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("registered pid was never reaped")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -7,39 +7,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/calebcase/clod/bot/metrics"
 	"github.com/calebcase/oops"
 )
 
-// SessionMapping represents a Slack thread to clod session mapping.
-type SessionMapping struct {
-	ChannelID string    `json:"channel_id"`
-	ThreadTS  string    `json:"thread_ts"`
-	TaskName  string    `json:"task_name"`
-	TaskPath  string    `json:"task_path"`
-	SessionID string    `json:"session_id"`
-	UserID    string    `json:"user_id"`
-	Verbose   bool      `json:"verbose"` // Per-thread verbosity setting
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-// SessionStore manages thread-to-session mappings with JSON persistence.
-type SessionStore struct {
+// fileSessionStore is the original SessionStore backend: an in-memory map
+// mirrored to a single JSON file, rewritten wholesale on every Save. It's
+// the default (scheme "file" or a bare path) and the only backend that
+// doesn't require a separate service to run.
+type fileSessionStore struct {
 	path     string
 	sessions map[string]*SessionMapping // key: "channelID:threadTS"
 	mu       sync.RWMutex
 }
 
-// Count returns the number of stored sessions.
-func (s *SessionStore) Count() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.sessions)
-}
-
-// NewSessionStore creates a new SessionStore and loads existing sessions.
-func NewSessionStore(path string) (*SessionStore, error) {
-	s := &SessionStore{
+// newFileSessionStore creates a fileSessionStore and loads existing sessions.
+func newFileSessionStore(path string) (*fileSessionStore, error) {
+	s := &fileSessionStore{
 		path:     path,
 		sessions: make(map[string]*SessionMapping),
 	}
@@ -51,31 +35,47 @@ func NewSessionStore(path string) (*SessionStore, error) {
 	return s, nil
 }
 
-// key generates the map key for a channel/thread pair.
-func key(channelID, threadTS string) string {
-	return channelID + ":" + threadTS
+// Count returns the number of stored sessions.
+func (s *fileSessionStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sessions)
 }
 
 // Get retrieves a session mapping by channel and thread.
-func (s *SessionStore) Get(channelID, threadTS string) *SessionMapping {
+func (s *fileSessionStore) Get(channelID, threadTS string) *SessionMapping {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	return s.sessions[key(channelID, threadTS)]
 }
 
-// Set stores a session mapping.
-func (s *SessionStore) Set(mapping *SessionMapping) {
+// Put stores a session mapping.
+func (s *fileSessionStore) Put(mapping *SessionMapping) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	mapping.UpdatedAt = time.Now()
 	s.sessions[key(mapping.ChannelID, mapping.ThreadTS)] = mapping
+	metrics.SessionCount.Set(float64(len(s.sessions)))
+
+	return nil
+}
+
+// Delete removes a session mapping, if one exists.
+func (s *fileSessionStore) Delete(channelID, threadTS string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, key(channelID, threadTS))
+	metrics.SessionCount.Set(float64(len(s.sessions)))
+
+	return nil
 }
 
 // SetVerbose updates the verbose setting for a thread.
 // If no session exists, it creates a minimal one to store the setting.
-func (s *SessionStore) SetVerbose(channelID, threadTS string, verbose bool) {
+func (s *fileSessionStore) SetVerbose(channelID, threadTS string, verbose bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -89,14 +89,17 @@ func (s *SessionStore) SetVerbose(channelID, threadTS string, verbose bool) {
 			CreatedAt: time.Now(),
 		}
 		s.sessions[k] = session
+		metrics.SessionCount.Set(float64(len(s.sessions)))
 	}
 	session.Verbose = verbose
 	session.UpdatedAt = time.Now()
+
+	return nil
 }
 
 // IsVerbose returns the verbosity setting for a thread.
 // Returns false (quiet mode) if no session exists.
-func (s *SessionStore) IsVerbose(channelID, threadTS string) bool {
+func (s *fileSessionStore) IsVerbose(channelID, threadTS string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -107,9 +110,46 @@ func (s *SessionStore) IsVerbose(channelID, threadTS string) bool {
 	return session.Verbose
 }
 
+// SetPinned marks a thread's mapping as exempt from session GC.
+// If no session exists, it creates a minimal one to store the setting.
+func (s *fileSessionStore) SetPinned(channelID, threadTS string, pinned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(channelID, threadTS)
+	session := s.sessions[k]
+	if session == nil {
+		// Create minimal session to track pinning for threads without tasks yet
+		session = &SessionMapping{
+			ChannelID: channelID,
+			ThreadTS:  threadTS,
+			CreatedAt: time.Now(),
+		}
+		s.sessions[k] = session
+		metrics.SessionCount.Set(float64(len(s.sessions)))
+	}
+	session.Pinned = pinned
+	session.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// IsPinned returns whether a thread's mapping is pinned.
+// Returns false if no session exists.
+func (s *fileSessionStore) IsPinned(channelID, threadTS string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session := s.sessions[key(channelID, threadTS)]
+	if session == nil {
+		return false
+	}
+	return session.Pinned
+}
+
 // Load reads sessions from the JSON file.
 // Returns nil if the file doesn't exist (fresh start).
-func (s *SessionStore) Load() error {
+func (s *fileSessionStore) Load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -131,12 +171,13 @@ func (s *SessionStore) Load() error {
 	for _, session := range sessions {
 		s.sessions[key(session.ChannelID, session.ThreadTS)] = session
 	}
+	metrics.SessionCount.Set(float64(len(s.sessions)))
 
 	return nil
 }
 
 // Save writes sessions to the JSON file atomically.
-func (s *SessionStore) Save() error {
+func (s *fileSessionStore) Save() error {
 	s.mu.RLock()
 	sessions := make([]*SessionMapping, 0, len(s.sessions))
 	for _, session := range s.sessions {
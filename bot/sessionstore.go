@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/calebcase/oops"
+)
+
+// SessionMapping represents a Slack thread to clod session mapping.
+type SessionMapping struct {
+	ChannelID string    `json:"channel_id"`
+	ThreadTS  string    `json:"thread_ts"`
+	TaskName  string    `json:"task_name"`
+	TaskPath  string    `json:"task_path"`
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	Verbose   bool      `json:"verbose"`          // Per-thread verbosity setting
+	Pinned    bool      `json:"pinned,omitempty"` // Exempts the mapping from future session GC (see SetPinned)
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SessionStore persists thread-to-session mappings. Get/Put/Delete/Count
+// operate on an in-memory cache that every implementation keeps, so they
+// never block on the backend; Load/Save are where a backend actually talks
+// to its store. Networked backends (Consul, etcd, Redis) keep that cache
+// fresh by watching for remote changes in the background, so multiple bot
+// replicas can share sessions without polling; Save on those backends is a
+// no-op because Put already wrote through.
+type SessionStore interface {
+	// Get retrieves a session mapping by channel and thread, or nil.
+	Get(channelID, threadTS string) *SessionMapping
+	// Put stores a session mapping.
+	Put(mapping *SessionMapping) error
+	// Delete removes a session mapping, if one exists.
+	Delete(channelID, threadTS string) error
+	// SetVerbose updates the verbose setting for a thread, creating a
+	// minimal session to hold it if none exists yet.
+	SetVerbose(channelID, threadTS string, verbose bool) error
+	// IsVerbose returns the verbosity setting for a thread, or false if no
+	// session exists.
+	IsVerbose(channelID, threadTS string) bool
+	// SetPinned marks a thread's mapping as exempt from session GC, creating
+	// a minimal session to hold it if none exists yet (see
+	// SessionMapping.Pinned).
+	SetPinned(channelID, threadTS string, pinned bool) error
+	// IsPinned returns whether a thread's mapping is pinned, or false if no
+	// session exists.
+	IsPinned(channelID, threadTS string) bool
+	// Load (re)populates the in-memory cache from the backend.
+	Load() error
+	// Save flushes the in-memory cache to the backend.
+	Save() error
+	// Count returns the number of stored sessions.
+	Count() int
+}
+
+// key generates the cache key for a channel/thread pair, shared by every
+// SessionStore implementation.
+func key(channelID, threadTS string) string {
+	return channelID + ":" + threadTS
+}
+
+// setVerboseViaPut implements SetVerbose in terms of Get/Put, for backends
+// with no bespoke locked fast path of their own (see
+// fileSessionStore.SetVerbose for the original, lock-optimized version).
+func setVerboseViaPut(s SessionStore, channelID, threadTS string, verbose bool) error {
+	session := s.Get(channelID, threadTS)
+	if session == nil {
+		session = &SessionMapping{
+			ChannelID: channelID,
+			ThreadTS:  threadTS,
+			CreatedAt: time.Now(),
+		}
+	}
+	session.Verbose = verbose
+	return s.Put(session)
+}
+
+// setPinnedViaPut implements SetPinned in terms of Get/Put, for backends
+// with no bespoke locked fast path of their own (see
+// fileSessionStore.SetPinned for the original, lock-optimized version).
+func setPinnedViaPut(s SessionStore, channelID, threadTS string, pinned bool) error {
+	session := s.Get(channelID, threadTS)
+	if session == nil {
+		session = &SessionMapping{
+			ChannelID: channelID,
+			ThreadTS:  threadTS,
+			CreatedAt: time.Now(),
+		}
+	}
+	session.Pinned = pinned
+	return s.Put(session)
+}
+
+// NewSessionStore builds a SessionStore from a backend URL: file://path (a
+// bare path with no scheme is treated the same way, for backward
+// compatibility with the old SessionStorePath flag), consul://host:8500/k/v,
+// etcd://host:2379/prefix, redis://host:6379/db, or bolt://path/to/db (an
+// embedded, indexed store; see sessionstore_bolt.go).
+func NewSessionStore(rawURL string) (SessionStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		path := u.Path
+		if u.Scheme == "" {
+			path = rawURL
+		}
+		return newFileSessionStore(path)
+	case "consul":
+		return newConsulSessionStore(u)
+	case "etcd":
+		return newEtcdSessionStore(u)
+	case "redis":
+		return newRedisSessionStore(u)
+	case "bolt":
+		return newBoltSessionStore(u)
+	default:
+		return nil, oops.New("unknown session store scheme %q", u.Scheme)
+	}
+}
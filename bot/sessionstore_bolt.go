@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/calebcase/oops"
+)
+
+var (
+	boltSessionsBucket  = []byte("sessions")
+	boltTaskIndexBucket = []byte("idx_task_name")
+	boltUserIndexBucket = []byte("idx_user_id")
+)
+
+// boltSessionStore persists each SessionMapping as its own key in a bbolt
+// database (one key per channel/thread, same as etcdSessionStore), plus
+// secondary indices on TaskName and UserID so ListByTaskName/ListByUserID
+// don't need a full scan. Unlike fileSessionStore, which serializes the
+// whole map to one JSON file on every Save, Put/Delete write through to the
+// database immediately inside a single transaction, so Save is a no-op and
+// a kill mid-write can't lose a concurrent Set the way a file rewrite can.
+// An in-memory cache mirrors the sessions bucket for Get/Count, same as
+// every other backend.
+type boltSessionStore struct {
+	db *bolt.DB
+
+	mu       sync.RWMutex
+	sessions map[string]*SessionMapping
+}
+
+// newBoltSessionStore opens (creating if needed) a bbolt database at the
+// path from a bolt://path URL. If the database doesn't exist yet but a
+// sessions.json does alongside it, its contents are imported on this first
+// open (see migrateFromJSON).
+func newBoltSessionStore(u *url.URL) (*boltSessionStore, error) {
+	path := u.Host + u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, oops.New("bolt session store URL must include a database path")
+	}
+
+	_, statErr := os.Stat(path)
+	isNew := os.IsNotExist(statErr)
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltSessionsBucket, boltTaskIndexBucket, boltUserIndexBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, oops.Trace(err)
+	}
+
+	s := &boltSessionStore{db: db, sessions: make(map[string]*SessionMapping)}
+
+	if isNew {
+		legacyPath := filepath.Join(filepath.Dir(path), "sessions.json")
+		if err := s.migrateFromJSON(legacyPath); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if err := s.Load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// migrateFromJSON imports every session out of a legacy fileSessionStore's
+// JSON file (if one exists at path) into the bucket, so switching
+// --session-store from a bare path to bolt://... doesn't lose history.
+// No-op if path doesn't exist.
+func (s *boltSessionStore) migrateFromJSON(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return oops.Trace(err)
+	}
+
+	var sessions []*SessionMapping
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return oops.Trace(err)
+	}
+
+	for _, session := range sessions {
+		if err := s.Put(session); err != nil {
+			return oops.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a session mapping from the in-memory cache.
+func (s *boltSessionStore) Get(channelID, threadTS string) *SessionMapping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sessions[key(channelID, threadTS)]
+}
+
+// Count returns the number of stored sessions in the in-memory cache.
+func (s *boltSessionStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.sessions)
+}
+
+// Put writes mapping to the database, updating the TaskName/UserID indices
+// in the same transaction (dropping any stale entries from the record it
+// replaces), then updates the in-memory cache.
+func (s *boltSessionStore) Put(mapping *SessionMapping) error {
+	k := key(mapping.ChannelID, mapping.ThreadTS)
+	mapping.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(boltSessionsBucket)
+
+		if old, err := getMapping(sessions, k); err != nil {
+			return err
+		} else if old != nil {
+			dropIndexEntry(tx.Bucket(boltTaskIndexBucket), old.TaskName, k)
+			dropIndexEntry(tx.Bucket(boltUserIndexBucket), old.UserID, k)
+		}
+
+		if err := sessions.Put([]byte(k), data); err != nil {
+			return err
+		}
+
+		putIndexEntry(tx.Bucket(boltTaskIndexBucket), mapping.TaskName, k)
+		putIndexEntry(tx.Bucket(boltUserIndexBucket), mapping.UserID, k)
+
+		return nil
+	})
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	s.sessions[k] = mapping
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Delete removes a session mapping and its index entries from the
+// database, then updates the in-memory cache.
+func (s *boltSessionStore) Delete(channelID, threadTS string) error {
+	k := key(channelID, threadTS)
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(boltSessionsBucket)
+
+		old, err := getMapping(sessions, k)
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			dropIndexEntry(tx.Bucket(boltTaskIndexBucket), old.TaskName, k)
+			dropIndexEntry(tx.Bucket(boltUserIndexBucket), old.UserID, k)
+		}
+
+		return sessions.Delete([]byte(k))
+	})
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	delete(s.sessions, k)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SetVerbose and IsVerbose are implemented generically in terms of Get/Put
+// (see setVerboseViaPut).
+func (s *boltSessionStore) SetVerbose(channelID, threadTS string, verbose bool) error {
+	return setVerboseViaPut(s, channelID, threadTS, verbose)
+}
+
+func (s *boltSessionStore) IsVerbose(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Verbose
+}
+
+// SetPinned and IsPinned are implemented generically in terms of Get/Put
+// (see setPinnedViaPut).
+func (s *boltSessionStore) SetPinned(channelID, threadTS string, pinned bool) error {
+	return setPinnedViaPut(s, channelID, threadTS, pinned)
+}
+
+func (s *boltSessionStore) IsPinned(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Pinned
+}
+
+// Load reads every session out of the database and replaces the in-memory
+// cache wholesale.
+func (s *boltSessionStore) Load() error {
+	sessions := make(map[string]*SessionMapping)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionsBucket).ForEach(func(k, v []byte) error {
+			var mapping SessionMapping
+			if err := json.Unmarshal(v, &mapping); err != nil {
+				return err
+			}
+			sessions[string(k)] = &mapping
+			return nil
+		})
+	})
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	s.sessions = sessions
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Save is a no-op: Put already writes through to the database.
+func (s *boltSessionStore) Save() error {
+	return nil
+}
+
+// ListByTaskName returns every session mapping with the given TaskName, via
+// the idx_task_name bucket rather than a scan of the sessions bucket.
+func (s *boltSessionStore) ListByTaskName(taskName string) ([]*SessionMapping, error) {
+	return s.listByIndex(boltTaskIndexBucket, taskName)
+}
+
+// ListByUserID returns every session mapping with the given UserID, via the
+// idx_user_id bucket rather than a scan of the sessions bucket.
+func (s *boltSessionStore) ListByUserID(userID string) ([]*SessionMapping, error) {
+	return s.listByIndex(boltUserIndexBucket, userID)
+}
+
+// listByIndex collects every session keyed under value in the given index
+// bucket, resolving each through the sessions bucket.
+func (s *boltSessionStore) listByIndex(indexBucket []byte, value string) ([]*SessionMapping, error) {
+	var results []*SessionMapping
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(boltSessionsBucket)
+		cursor := tx.Bucket(indexBucket).Cursor()
+
+		prefix := indexKey(value, "")
+		for k, sessionKey := cursor.Seek(prefix); k != nil && hasPrefix(k, prefix); k, sessionKey = cursor.Next() {
+			mapping, err := getMapping(sessions, string(sessionKey))
+			if err != nil {
+				return err
+			}
+			if mapping != nil {
+				results = append(results, mapping)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	return results, nil
+}
+
+// getMapping reads and unmarshals one session from the sessions bucket, or
+// returns nil if k isn't present.
+func getMapping(sessions *bolt.Bucket, k string) (*SessionMapping, error) {
+	data := sessions.Get([]byte(k))
+	if data == nil {
+		return nil, nil
+	}
+
+	var mapping SessionMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// indexKey builds a secondary index key of the form "value\x00sessionKey",
+// so every entry for a given value sorts together and can be range-scanned
+// with a Seek/prefix walk (sessionKey == "" gives the scan's starting
+// prefix itself).
+func indexKey(value, sessionKey string) []byte {
+	return []byte(value + "\x00" + sessionKey)
+}
+
+// putIndexEntry adds k to the index bucket under value. A no-op for an
+// empty value (e.g. a minimal session created by SetVerbose/SetPinned
+// before any task has run).
+func putIndexEntry(bucket *bolt.Bucket, value, k string) {
+	if value == "" {
+		return
+	}
+	_ = bucket.Put(indexKey(value, k), []byte(k))
+}
+
+// dropIndexEntry removes k from the index bucket under value.
+func dropIndexEntry(bucket *bolt.Bucket, value, k string) {
+	if value == "" {
+		return
+	}
+	_ = bucket.Delete(indexKey(value, k))
+}
+
+// hasPrefix reports whether k starts with prefix, used to bound a Cursor
+// walk over one index value's entries.
+func hasPrefix(k, prefix []byte) bool {
+	return len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSessionStoreBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.bolt")
+
+	store, err := NewSessionStore("bolt://" + path)
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+	if _, ok := store.(*boltSessionStore); !ok {
+		t.Fatalf("NewSessionStore(bolt://...) = %T, want *boltSessionStore", store)
+	}
+}
+
+func TestBoltSessionStorePutGetDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.bolt")
+	store, err := NewSessionStore("bolt://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := &SessionMapping{ChannelID: "C1", ThreadTS: "T1", SessionID: "S1", TaskName: "build", UserID: "U1"}
+	if err := store.Put(mapping); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Get("C1", "T1"); got == nil || got.SessionID != "S1" {
+		t.Fatalf("Get = %+v, want SessionID S1", got)
+	}
+	if store.Count() != 1 {
+		t.Fatalf("Count = %d, want 1", store.Count())
+	}
+
+	if err := store.Delete("C1", "T1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Get("C1", "T1"); got != nil {
+		t.Fatalf("Get after Delete = %+v, want nil", got)
+	}
+}
+
+func TestBoltSessionStoreReopenLoadsExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.bolt")
+
+	store, err := newBoltSessionStore(mustParseURL(t, "bolt://"+path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(&SessionMapping{ChannelID: "C1", ThreadTS: "T1", SessionID: "S1"}); err != nil {
+		t.Fatal(err)
+	}
+	store.db.Close()
+
+	reopened, err := newBoltSessionStore(mustParseURL(t, "bolt://"+path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.db.Close()
+
+	if got := reopened.Get("C1", "T1"); got == nil || got.SessionID != "S1" {
+		t.Fatalf("Get after reopen = %+v, want SessionID S1", got)
+	}
+}
+
+func TestBoltSessionStoreListByTaskNameAndUserID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.bolt")
+	store, err := newBoltSessionStore(mustParseURL(t, "bolt://"+path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.db.Close()
+
+	mappings := []*SessionMapping{
+		{ChannelID: "C1", ThreadTS: "T1", TaskName: "build", UserID: "U1"},
+		{ChannelID: "C2", ThreadTS: "T2", TaskName: "build", UserID: "U2"},
+		{ChannelID: "C3", ThreadTS: "T3", TaskName: "deploy", UserID: "U1"},
+	}
+	for _, m := range mappings {
+		if err := store.Put(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byTask, err := store.ListByTaskName("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byTask) != 2 {
+		t.Fatalf("ListByTaskName(build) = %d results, want 2", len(byTask))
+	}
+
+	byUser, err := store.ListByUserID("U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byUser) != 2 {
+		t.Fatalf("ListByUserID(U1) = %d results, want 2", len(byUser))
+	}
+
+	// Moving a session to a different task should drop it from the old
+	// index entry, not just add the new one.
+	mappings[0].TaskName = "deploy"
+	if err := store.Put(mappings[0]); err != nil {
+		t.Fatal(err)
+	}
+	byTask, err = store.ListByTaskName("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byTask) != 1 {
+		t.Fatalf("ListByTaskName(build) after move = %d results, want 1", len(byTask))
+	}
+}
+
+func TestBoltSessionStoreMigratesFromJSON(t *testing.T) {
+	dir := t.TempDir()
+	legacy := []*SessionMapping{
+		{ChannelID: "C1", ThreadTS: "T1", SessionID: "S1", TaskName: "build"},
+		{ChannelID: "C2", ThreadTS: "T2", SessionID: "S2", TaskName: "deploy"},
+	}
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sessions.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewSessionStore("bolt://" + filepath.Join(dir, "sessions.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if store.Count() != 2 {
+		t.Fatalf("Count after migration = %d, want 2", store.Count())
+	}
+	if got := store.Get("C1", "T1"); got == nil || got.SessionID != "S1" {
+		t.Fatalf("Get(C1, T1) after migration = %+v, want SessionID S1", got)
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", raw, err)
+	}
+	return u
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+
+	"github.com/calebcase/oops"
+)
+
+// consulSessionStoreRetries bounds how many times Put retries a
+// CAS-rejected write before giving up; a losing CAS means another replica
+// wrote the same key between our read and our write, which a re-read and
+// retry resolves almost immediately.
+const consulSessionStoreRetries = 5
+
+// consulSessionStore stores sessions under a Consul KV prefix, one key per
+// channel/thread pair. A background blocking query keeps an in-memory cache
+// fresh so Get is never on Consul's critical path and every bot replica
+// observes other replicas' writes without polling; Put writes through
+// immediately, using CAS on ModifyIndex so two replicas racing to update the
+// same thread can't silently clobber each other.
+type consulSessionStore struct {
+	kv     *consulapi.KV
+	prefix string
+
+	mu       sync.RWMutex
+	sessions map[string]*SessionMapping
+	index    map[string]uint64 // ModifyIndex per key, for CAS on Put
+	watchIdx uint64
+
+	stop chan struct{}
+}
+
+// newConsulSessionStore builds a consulSessionStore from a
+// consul://host:port/key/prefix URL. An empty host defaults to Consul's
+// usual local agent address (127.0.0.1:8500, see consulapi.DefaultConfig).
+func newConsulSessionStore(u *url.URL) (*consulSessionStore, error) {
+	cfg := consulapi.DefaultConfig()
+	if u.Host != "" {
+		cfg.Address = u.Host
+	}
+
+	client, err := consulapi.NewClient(cfg)
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	s := &consulSessionStore{
+		kv:       client.KV(),
+		prefix:   strings.Trim(u.Path, "/"),
+		sessions: make(map[string]*SessionMapping),
+		index:    make(map[string]uint64),
+		stop:     make(chan struct{}),
+	}
+
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// consulKey maps a channel/thread pair to its full Consul KV key.
+func (s *consulSessionStore) consulKey(channelID, threadTS string) string {
+	return s.prefix + "/" + key(channelID, threadTS)
+}
+
+// Get retrieves a session mapping from the in-memory cache.
+func (s *consulSessionStore) Get(channelID, threadTS string) *SessionMapping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sessions[key(channelID, threadTS)]
+}
+
+// Count returns the number of stored sessions in the in-memory cache.
+func (s *consulSessionStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.sessions)
+}
+
+// Put writes mapping to Consul, retrying with a fresh ModifyIndex if a
+// concurrent writer's update wins the CAS race.
+func (s *consulSessionStore) Put(mapping *SessionMapping) error {
+	mapping.UpdatedAt = time.Now()
+	k := key(mapping.ChannelID, mapping.ThreadTS)
+	consulKey := s.consulKey(mapping.ChannelID, mapping.ThreadTS)
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	for attempt := 0; attempt < consulSessionStoreRetries; attempt++ {
+		s.mu.RLock()
+		modifyIndex := s.index[k]
+		s.mu.RUnlock()
+
+		pair := &consulapi.KVPair{Key: consulKey, Value: data, ModifyIndex: modifyIndex}
+		ok, _, err := s.kv.CAS(pair, nil)
+		if err != nil {
+			return oops.Trace(err)
+		}
+		if ok {
+			s.mu.Lock()
+			s.sessions[k] = mapping
+			s.mu.Unlock()
+			return nil
+		}
+
+		// Lost the CAS race: re-read the key's current ModifyIndex and retry.
+		current, _, err := s.kv.Get(consulKey, nil)
+		if err != nil {
+			return oops.Trace(err)
+		}
+		s.mu.Lock()
+		if current != nil {
+			s.index[k] = current.ModifyIndex
+		} else {
+			s.index[k] = 0
+		}
+		s.mu.Unlock()
+	}
+
+	return oops.New("consul session store: CAS write for %s lost the race %d times in a row", consulKey, consulSessionStoreRetries)
+}
+
+// Delete removes a session mapping from Consul and the local cache.
+func (s *consulSessionStore) Delete(channelID, threadTS string) error {
+	k := key(channelID, threadTS)
+
+	if _, err := s.kv.Delete(s.consulKey(channelID, threadTS), nil); err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	delete(s.sessions, k)
+	delete(s.index, k)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SetVerbose and IsVerbose have no CAS-worthy fast path here, so they're
+// implemented generically in terms of Get/Put (see setVerboseViaPut).
+func (s *consulSessionStore) SetVerbose(channelID, threadTS string, verbose bool) error {
+	return setVerboseViaPut(s, channelID, threadTS, verbose)
+}
+
+func (s *consulSessionStore) IsVerbose(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Verbose
+}
+
+// SetPinned and IsPinned have no CAS-worthy fast path here, so they're
+// implemented generically in terms of Get/Put (see setPinnedViaPut).
+func (s *consulSessionStore) SetPinned(channelID, threadTS string, pinned bool) error {
+	return setPinnedViaPut(s, channelID, threadTS, pinned)
+}
+
+func (s *consulSessionStore) IsPinned(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Pinned
+}
+
+// Load lists every session currently under prefix and replaces the
+// in-memory cache wholesale; used for the initial fill and whenever watch
+// needs to recover from a lost blocking query.
+func (s *consulSessionStore) Load() error {
+	pairs, meta, err := s.kv.List(s.prefix, nil)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	sessions := make(map[string]*SessionMapping, len(pairs))
+	index := make(map[string]uint64, len(pairs))
+	for _, pair := range pairs {
+		var mapping SessionMapping
+		if err := json.Unmarshal(pair.Value, &mapping); err != nil {
+			return oops.Trace(err)
+		}
+		k := key(mapping.ChannelID, mapping.ThreadTS)
+		sessions[k] = &mapping
+		index[k] = pair.ModifyIndex
+	}
+
+	s.mu.Lock()
+	s.sessions = sessions
+	s.index = index
+	if meta != nil {
+		s.watchIdx = meta.LastIndex
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Save is a no-op: Put already writes through to Consul.
+func (s *consulSessionStore) Save() error {
+	return nil
+}
+
+// watch runs a blocking-query loop against prefix, refreshing the in-memory
+// cache every time Consul reports a change, so replicas observe each
+// other's writes without polling.
+func (s *consulSessionStore) watch() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		s.mu.RLock()
+		waitIndex := s.watchIdx
+		s.mu.RUnlock()
+
+		_, meta, err := s.kv.List(s.prefix, &consulapi.QueryOptions{
+			WaitIndex: waitIndex,
+			WaitTime:  5 * time.Minute,
+		})
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		if meta.LastIndex == waitIndex {
+			continue
+		}
+
+		if err := s.Load(); err != nil {
+			time.Sleep(time.Second)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/calebcase/oops"
+)
+
+// etcdSessionStoreRetries bounds how many times Put retries a failed
+// compare-and-swap transaction before giving up (see consulSessionStore,
+// which applies the same CAS-on-retry pattern against Consul's
+// ModifyIndex).
+const etcdSessionStoreRetries = 5
+
+// etcdSessionStore stores sessions under an etcd key prefix, one key per
+// channel/thread pair. A background Watch keeps an in-memory cache fresh so
+// Get never touches etcd and every bot replica observes other replicas'
+// writes without polling; Put writes through immediately inside a
+// compare-and-swap transaction on the key's ModRevision, so two replicas
+// racing to update the same thread can't silently clobber each other.
+type etcdSessionStore struct {
+	client *clientv3.Client
+	prefix string
+
+	mu       sync.RWMutex
+	sessions map[string]*SessionMapping
+	revision map[string]int64 // ModRevision per key, for CAS on Put
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newEtcdSessionStore builds an etcdSessionStore from an
+// etcd://host:port/key/prefix URL.
+func newEtcdSessionStore(u *url.URL) (*etcdSessionStore, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{u.Host},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &etcdSessionStore{
+		client:   client,
+		prefix:   strings.Trim(u.Path, "/") + "/",
+		sessions: make(map[string]*SessionMapping),
+		revision: make(map[string]int64),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	if err := s.Load(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// etcdKey maps a channel/thread pair to its full etcd key.
+func (s *etcdSessionStore) etcdKey(channelID, threadTS string) string {
+	return s.prefix + key(channelID, threadTS)
+}
+
+// Get retrieves a session mapping from the in-memory cache.
+func (s *etcdSessionStore) Get(channelID, threadTS string) *SessionMapping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sessions[key(channelID, threadTS)]
+}
+
+// Count returns the number of stored sessions in the in-memory cache.
+func (s *etcdSessionStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.sessions)
+}
+
+// Put writes mapping to etcd inside a compare-and-swap transaction, retrying
+// with the key's current ModRevision if a concurrent writer wins the race.
+func (s *etcdSessionStore) Put(mapping *SessionMapping) error {
+	mapping.UpdatedAt = time.Now()
+	k := key(mapping.ChannelID, mapping.ThreadTS)
+	etcdKey := s.etcdKey(mapping.ChannelID, mapping.ThreadTS)
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	for attempt := 0; attempt < etcdSessionStoreRetries; attempt++ {
+		s.mu.RLock()
+		modRevision := s.revision[k]
+		s.mu.RUnlock()
+
+		resp, err := s.client.Txn(s.ctx).
+			If(clientv3.Compare(clientv3.ModRevision(etcdKey), "=", modRevision)).
+			Then(clientv3.OpPut(etcdKey, string(data))).
+			Else(clientv3.OpGet(etcdKey)).
+			Commit()
+		if err != nil {
+			return oops.Trace(err)
+		}
+		if resp.Succeeded {
+			s.mu.Lock()
+			s.sessions[k] = mapping
+			s.revision[k] = resp.Header.Revision
+			s.mu.Unlock()
+			return nil
+		}
+
+		// Lost the CAS race: adopt the current ModRevision and retry.
+		s.mu.Lock()
+		if getResp := resp.Responses[0].GetResponseRange(); len(getResp.Kvs) > 0 {
+			s.revision[k] = getResp.Kvs[0].ModRevision
+		} else {
+			s.revision[k] = 0
+		}
+		s.mu.Unlock()
+	}
+
+	return oops.New("etcd session store: CAS write for %s lost the race %d times in a row", etcdKey, etcdSessionStoreRetries)
+}
+
+// Delete removes a session mapping from etcd and the local cache.
+func (s *etcdSessionStore) Delete(channelID, threadTS string) error {
+	k := key(channelID, threadTS)
+
+	if _, err := s.client.Delete(s.ctx, s.etcdKey(channelID, threadTS)); err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	delete(s.sessions, k)
+	delete(s.revision, k)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SetVerbose and IsVerbose have no CAS-worthy fast path here, so they're
+// implemented generically in terms of Get/Put (see setVerboseViaPut).
+func (s *etcdSessionStore) SetVerbose(channelID, threadTS string, verbose bool) error {
+	return setVerboseViaPut(s, channelID, threadTS, verbose)
+}
+
+func (s *etcdSessionStore) IsVerbose(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Verbose
+}
+
+// SetPinned and IsPinned have no CAS-worthy fast path here, so they're
+// implemented generically in terms of Get/Put (see setPinnedViaPut).
+func (s *etcdSessionStore) SetPinned(channelID, threadTS string, pinned bool) error {
+	return setPinnedViaPut(s, channelID, threadTS, pinned)
+}
+
+func (s *etcdSessionStore) IsPinned(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Pinned
+}
+
+// Load lists every session currently under prefix and replaces the
+// in-memory cache wholesale; used for the initial fill.
+func (s *etcdSessionStore) Load() error {
+	resp, err := s.client.Get(s.ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	sessions := make(map[string]*SessionMapping, len(resp.Kvs))
+	revision := make(map[string]int64, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var mapping SessionMapping
+		if err := json.Unmarshal(kv.Value, &mapping); err != nil {
+			return oops.Trace(err)
+		}
+		k := key(mapping.ChannelID, mapping.ThreadTS)
+		sessions[k] = &mapping
+		revision[k] = kv.ModRevision
+	}
+
+	s.mu.Lock()
+	s.sessions = sessions
+	s.revision = revision
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Save is a no-op: Put already writes through to etcd.
+func (s *etcdSessionStore) Save() error {
+	return nil
+}
+
+// watch streams changes under prefix and applies them to the in-memory
+// cache as they arrive, so replicas observe each other's writes without
+// polling.
+func (s *etcdSessionStore) watch() {
+	for resp := range s.client.Watch(s.ctx, s.prefix, clientv3.WithPrefix()) {
+		if resp.Err() != nil {
+			continue
+		}
+
+		for _, event := range resp.Events {
+			var mapping SessionMapping
+			switch event.Type {
+			case clientv3.EventTypePut:
+				if err := json.Unmarshal(event.Kv.Value, &mapping); err != nil {
+					continue
+				}
+				k := key(mapping.ChannelID, mapping.ThreadTS)
+				s.mu.Lock()
+				s.sessions[k] = &mapping
+				s.revision[k] = event.Kv.ModRevision
+				s.mu.Unlock()
+			case clientv3.EventTypeDelete:
+				k := strings.TrimPrefix(string(event.Kv.Key), s.prefix)
+				s.mu.Lock()
+				delete(s.sessions, k)
+				delete(s.revision, k)
+				s.mu.Unlock()
+			}
+		}
+	}
+}
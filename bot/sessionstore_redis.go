@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/calebcase/oops"
+)
+
+// redisSessionHashKey is the single Redis hash all sessions live under,
+// field-keyed by channel/thread (see key). A hash keeps Count()/Load() to
+// one round trip instead of a key per session.
+const redisSessionHashKey = "clod:sessions"
+
+// redisSessionChannel is the pub/sub channel Put/Delete publish to, so every
+// replica's in-memory cache picks up the change without polling Redis.
+const redisSessionChannel = "clod:sessions:updates"
+
+// redisSessionUpdate is the payload published on redisSessionChannel.
+type redisSessionUpdate struct {
+	Key     string          `json:"key"`
+	Deleted bool            `json:"deleted"`
+	Mapping *SessionMapping `json:"mapping,omitempty"`
+}
+
+// redisSessionStore stores sessions as fields of a single Redis hash. A
+// background subscription to redisSessionChannel keeps an in-memory cache
+// fresh so Get never touches Redis and every bot replica observes other
+// replicas' writes without polling; Put writes through immediately via
+// HSET, which Redis applies atomically per field, and then publishes the
+// change.
+type redisSessionStore struct {
+	client *redis.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.RWMutex
+	sessions map[string]*SessionMapping
+}
+
+// newRedisSessionStore builds a redisSessionStore from a
+// redis://host:port/db URL.
+func newRedisSessionStore(u *url.URL) (*redisSessionStore, error) {
+	db := 0
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		parsed, err := strconv.Atoi(path)
+		if err != nil {
+			return nil, oops.New("invalid redis db %q in session store URL", path)
+		}
+		db = parsed
+	}
+
+	opts := &redis.Options{Addr: u.Host, DB: db}
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &redisSessionStore{
+		client:   redis.NewClient(opts),
+		ctx:      ctx,
+		cancel:   cancel,
+		sessions: make(map[string]*SessionMapping),
+	}
+
+	if err := s.Load(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// Get retrieves a session mapping from the in-memory cache.
+func (s *redisSessionStore) Get(channelID, threadTS string) *SessionMapping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sessions[key(channelID, threadTS)]
+}
+
+// Count returns the number of stored sessions in the in-memory cache.
+func (s *redisSessionStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.sessions)
+}
+
+// Put writes mapping to Redis and publishes the change so other replicas
+// update their cache.
+func (s *redisSessionStore) Put(mapping *SessionMapping) error {
+	k := key(mapping.ChannelID, mapping.ThreadTS)
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	if err := s.client.HSet(s.ctx, redisSessionHashKey, k, data).Err(); err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	s.sessions[k] = mapping
+	s.mu.Unlock()
+
+	return s.publish(redisSessionUpdate{Key: k, Mapping: mapping})
+}
+
+// Delete removes a session mapping from Redis and publishes the change.
+func (s *redisSessionStore) Delete(channelID, threadTS string) error {
+	k := key(channelID, threadTS)
+
+	if err := s.client.HDel(s.ctx, redisSessionHashKey, k).Err(); err != nil {
+		return oops.Trace(err)
+	}
+
+	s.mu.Lock()
+	delete(s.sessions, k)
+	s.mu.Unlock()
+
+	return s.publish(redisSessionUpdate{Key: k, Deleted: true})
+}
+
+// SetVerbose and IsVerbose are implemented generically in terms of Get/Put
+// (see setVerboseViaPut).
+func (s *redisSessionStore) SetVerbose(channelID, threadTS string, verbose bool) error {
+	return setVerboseViaPut(s, channelID, threadTS, verbose)
+}
+
+func (s *redisSessionStore) IsVerbose(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Verbose
+}
+
+// SetPinned and IsPinned are implemented generically in terms of Get/Put
+// (see setPinnedViaPut).
+func (s *redisSessionStore) SetPinned(channelID, threadTS string, pinned bool) error {
+	return setPinnedViaPut(s, channelID, threadTS, pinned)
+}
+
+func (s *redisSessionStore) IsPinned(channelID, threadTS string) bool {
+	session := s.Get(channelID, threadTS)
+	return session != nil && session.Pinned
+}
+
+// Load reads every session field out of the hash and replaces the
+// in-memory cache wholesale; used for the initial fill.
+func (s *redisSessionStore) Load() error {
+	fields, err := s.client.HGetAll(s.ctx, redisSessionHashKey).Result()
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	sessions := make(map[string]*SessionMapping, len(fields))
+	for k, raw := range fields {
+		var mapping SessionMapping
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return oops.Trace(err)
+		}
+		sessions[k] = &mapping
+	}
+
+	s.mu.Lock()
+	s.sessions = sessions
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Save is a no-op: Put already writes through to Redis.
+func (s *redisSessionStore) Save() error {
+	return nil
+}
+
+// publish announces a change on redisSessionChannel for other replicas.
+func (s *redisSessionStore) publish(update redisSessionUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	return oops.Trace(s.client.Publish(s.ctx, redisSessionChannel, data).Err())
+}
+
+// watch subscribes to redisSessionChannel and applies incoming updates to
+// the in-memory cache, so replicas observe each other's writes without
+// polling.
+func (s *redisSessionStore) watch() {
+	sub := s.client.Subscribe(s.ctx, redisSessionChannel)
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		var update redisSessionUpdate
+		if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		if update.Deleted {
+			delete(s.sessions, update.Key)
+		} else if update.Mapping != nil {
+			s.sessions[update.Key] = update.Mapping
+		}
+		s.mu.Unlock()
+	}
+}
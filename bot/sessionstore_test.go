@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSessionStoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+
+	for _, url := range []string{path, "file://" + path} {
+		store, err := NewSessionStore(url)
+		if err != nil {
+			t.Fatalf("NewSessionStore(%q): %v", url, err)
+		}
+		if _, ok := store.(*fileSessionStore); !ok {
+			t.Fatalf("NewSessionStore(%q) = %T, want *fileSessionStore", url, store)
+		}
+	}
+}
+
+func TestFileSessionStorePutGetDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := &SessionMapping{ChannelID: "C1", ThreadTS: "T1", SessionID: "S1"}
+	if err := store.Put(mapping); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Get("C1", "T1"); got == nil || got.SessionID != "S1" {
+		t.Fatalf("Get = %+v, want SessionID S1", got)
+	}
+	if store.Count() != 1 {
+		t.Fatalf("Count = %d, want 1", store.Count())
+	}
+
+	if err := store.Delete("C1", "T1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Get("C1", "T1"); got != nil {
+		t.Fatalf("Get after Delete = %+v, want nil", got)
+	}
+}
+
+func TestNewSessionStoreUnknownScheme(t *testing.T) {
+	if _, err := NewSessionStore("memcached://localhost"); err == nil {
+		t.Fatal("expected an error for an unknown session store scheme")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/calebcase/oops"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const (
+	userCacheSize    = 1024
+	channelCacheSize = 512
+	fileCacheSize    = 256
+
+	// deniedCacheTTL is how long a user's failed authorization check is
+	// remembered (see SlackCache.IsDeniedRecently), so a user who keeps
+	// retrying after being denied doesn't force repeated work on every
+	// single event.
+	deniedCacheTTL = 30 * time.Second
+)
+
+// SlackCache memoizes Slack Web API lookups that Handler would otherwise
+// repeat on every event in a busy channel: users.info, conversations.info,
+// and the conversations.history call FileHandler makes to recover a
+// message's file attachments. It also negative-caches recently-denied user
+// IDs for deniedCacheTTL (see Handler.isAuthorized). Entries are dropped by
+// Handler.HandleCacheInvalidation in response to user_change, team_join,
+// and channel_rename events. Nil-safe callers should check for a nil
+// *SlackCache, since it's unset for transports (e.g. terminal) with no
+// Slack client to cache calls to.
+type SlackCache struct {
+	client *slack.Client
+	logger zerolog.Logger
+
+	users    *lru.Cache // userID -> *slack.User
+	channels *lru.Cache // channelID -> *slack.Channel
+	files    *lru.Cache // "channelID:messageTS" -> []slack.File
+
+	deniedMu sync.Mutex
+	denied   map[string]time.Time // userID -> when the cached denial expires
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewSlackCache creates a SlackCache backed by client.
+func NewSlackCache(client *slack.Client, logger zerolog.Logger) *SlackCache {
+	users, _ := lru.New(userCacheSize)
+	channels, _ := lru.New(channelCacheSize)
+	files, _ := lru.New(fileCacheSize)
+
+	return &SlackCache{
+		client:   client,
+		logger:   logger.With().Str("component", "slack_cache").Logger(),
+		users:    users,
+		channels: channels,
+		files:    files,
+		denied:   make(map[string]time.Time),
+	}
+}
+
+// record logs one cache lookup's outcome at debug level, along with the
+// running hit/miss totals, so an operator can tune cache sizes and the
+// denied TTL off of logs without needing a separate metrics endpoint.
+func (c *SlackCache) record(op string, hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	c.logger.Debug().
+		Str("op", op).
+		Bool("hit", hit).
+		Uint64("hits", c.hits.Load()).
+		Uint64("misses", c.misses.Load()).
+		Msg("slack cache lookup")
+}
+
+// GetUserInfo returns userID's profile, fetching it via users.info and
+// caching the result on a miss.
+func (c *SlackCache) GetUserInfo(userID string) (*slack.User, error) {
+	if cached, ok := c.users.Get(userID); ok {
+		c.record("users.info", true)
+		return cached.(*slack.User), nil
+	}
+
+	user, err := c.client.GetUserInfo(userID)
+	if err != nil {
+		c.record("users.info", false)
+		return nil, oops.Trace(err)
+	}
+
+	c.users.Add(userID, user)
+	c.record("users.info", false)
+	return user, nil
+}
+
+// GetConversationInfo returns channelID's metadata, fetching it via
+// conversations.info and caching the result on a miss.
+func (c *SlackCache) GetConversationInfo(channelID string) (*slack.Channel, error) {
+	if cached, ok := c.channels.Get(channelID); ok {
+		c.record("conversations.info", true)
+		return cached.(*slack.Channel), nil
+	}
+
+	channel, err := c.client.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil {
+		c.record("conversations.info", false)
+		return nil, oops.Trace(err)
+	}
+
+	c.channels.Add(channelID, channel)
+	c.record("conversations.info", false)
+	return channel, nil
+}
+
+// fileCacheKey identifies one message's attachments for the files LRU.
+func fileCacheKey(channelID, messageTS string) string {
+	return channelID + ":" + messageTS
+}
+
+// GetOrFetchMessageFiles returns the cached file list for (channelID,
+// messageTS), calling fetch on a miss and caching whatever it returns. It
+// lets FileHandler memoize the conversations.history lookup it otherwise
+// makes on every file-bearing message without needing to know the cache
+// key's shape.
+func (c *SlackCache) GetOrFetchMessageFiles(channelID, messageTS string, fetch func() ([]slack.File, error)) ([]slack.File, error) {
+	key := fileCacheKey(channelID, messageTS)
+	if cached, ok := c.files.Get(key); ok {
+		c.record("conversations.history", true)
+		return cached.([]slack.File), nil
+	}
+
+	files, err := fetch()
+	if err != nil {
+		c.record("conversations.history", false)
+		return nil, err
+	}
+
+	c.files.Add(key, files)
+	c.record("conversations.history", false)
+	return files, nil
+}
+
+// IsDeniedRecently reports whether userID failed authorization within the
+// last deniedCacheTTL.
+func (c *SlackCache) IsDeniedRecently(userID string) bool {
+	c.deniedMu.Lock()
+	defer c.deniedMu.Unlock()
+
+	expires, ok := c.denied[userID]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(c.denied, userID)
+		return false
+	}
+	return true
+}
+
+// RememberDenied caches userID as unauthorized for deniedCacheTTL.
+func (c *SlackCache) RememberDenied(userID string) {
+	c.deniedMu.Lock()
+	defer c.deniedMu.Unlock()
+	c.denied[userID] = time.Now().Add(deniedCacheTTL)
+}
+
+// InvalidateUser drops userID's cached users.info entry and any cached
+// denial, in response to Slack reporting the user changed (user_change) or
+// just joined the team (team_join).
+func (c *SlackCache) InvalidateUser(userID string) {
+	c.users.Remove(userID)
+
+	c.deniedMu.Lock()
+	delete(c.denied, userID)
+	c.deniedMu.Unlock()
+}
+
+// InvalidateChannel drops channelID's cached conversations.info entry, in
+// response to Slack reporting the channel was renamed (channel_rename).
+func (c *SlackCache) InvalidateChannel(channelID string) {
+	c.channels.Remove(channelID)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/calebcase/oops"
+)
+
+// defaultSnippetCacheBytes is the default total size cap for a task's
+// on-disk snippet cache (see snippetCache).
+const defaultSnippetCacheBytes = 256 * 1024 * 1024
+
+// SnippetStats summarizes a snippet cache's usage.
+type SnippetStats struct {
+	Entries    int
+	TotalBytes int64
+	Hits       int64
+	Misses     int64
+	Evictions  int64
+}
+
+// snippetCache is a content-addressed, size-bounded LRU cache of tool_result
+// snippets for one task directory, persisted under
+// <taskPath>/.clod/snippets/<hash>. Repeated identical tool output (e.g. a
+// Bash command re-run across retries) is stored once on disk; runAttempt
+// emits a __SNIPPET_REF__ for duplicates instead of re-sending the content.
+type snippetCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	order   []string // hashes, least to most recently used
+	sizes   map[string]int64
+	total   int64
+	hits    int64
+	misses  int64
+	evicted int64
+}
+
+// newSnippetCache opens the snippet cache for a task directory, loading any
+// entries already on disk from a previous run.
+func newSnippetCache(taskPath string, maxBytes int64) *snippetCache {
+	c := &snippetCache{
+		dir:      filepath.Join(taskPath, ".clod", "snippets"),
+		maxBytes: maxBytes,
+		sizes:    make(map[string]int64),
+	}
+	c.loadExisting()
+	return c
+}
+
+// loadExisting populates the LRU order and size accounting from whatever is
+// already in the cache directory, oldest modification time first.
+func (c *snippetCache) loadExisting() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type fileInfo struct {
+		name    string
+		size    int64
+		modTime int64
+	}
+	var files []fileInfo
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{name: e.Name(), size: info.Size(), modTime: info.ModTime().UnixNano()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime < files[j].modTime })
+
+	for _, f := range files {
+		c.order = append(c.order, f.name)
+		c.sizes[f.name] = f.size
+		c.total += f.size
+	}
+}
+
+// Store writes content to the cache keyed by sha256(toolName + inputJSON +
+// content). It returns the hash and whether this exact content was already
+// cached, in which case the caller should emit a reference rather than
+// re-sending the content.
+func (c *snippetCache) Store(toolName, inputJSON, content string) (hash string, dup bool, err error) {
+	sum := sha256.Sum256([]byte(toolName + "\x00" + inputJSON + "\x00" + content))
+	hash = hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := filepath.Join(c.dir, hash)
+	if _, statErr := os.Stat(path); statErr == nil {
+		c.hits++
+		c.touch(hash)
+		return hash, true, nil
+	}
+	c.misses++
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", false, oops.Trace(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", false, oops.Trace(err)
+	}
+
+	size := int64(len(content))
+	c.order = append(c.order, hash)
+	c.sizes[hash] = size
+	c.total += size
+	c.evict()
+
+	return hash, false, nil
+}
+
+// Get reads a previously cached snippet by hash.
+func (c *snippetCache) Get(hash string) ([]byte, error) {
+	c.mu.Lock()
+	c.touch(hash)
+	dir := c.dir
+	c.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(dir, hash))
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+	return data, nil
+}
+
+// Size reports the byte length of a cached snippet without reading it, so
+// callers deciding whether (and how) to paginate don't need the content in
+// memory first.
+func (c *snippetCache) Size(hash string) (int64, error) {
+	c.mu.Lock()
+	dir := c.dir
+	c.mu.Unlock()
+
+	info, err := os.Stat(filepath.Join(dir, hash))
+	if err != nil {
+		return 0, oops.Trace(err)
+	}
+	return info.Size(), nil
+}
+
+// ReadRange reads up to length bytes starting at offset from a cached
+// snippet, seeking directly on the file instead of loading the whole
+// snippet into memory first (see Get, which does the latter for the
+// no-pagination case).
+func (c *snippetCache) ReadRange(hash string, offset, length int64) ([]byte, error) {
+	c.mu.Lock()
+	c.touch(hash)
+	dir := c.dir
+	c.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(dir, hash))
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	buf := make([]byte, length)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, oops.Trace(err)
+	}
+	return buf[:n], nil
+}
+
+// Path returns the on-disk location of a cached snippet, for callers (e.g.
+// a "Download raw" upload) that want to stream the unmodified file straight
+// to Slack instead of going through Get/ReadRange.
+func (c *snippetCache) Path(hash string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return filepath.Join(c.dir, hash)
+}
+
+// touch moves hash to the most-recently-used end of the eviction order. c.mu
+// must be held.
+func (c *snippetCache) touch(hash string) {
+	for i, h := range c.order {
+		if h == hash {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			c.order = append(c.order, hash)
+			return
+		}
+	}
+}
+
+// evict removes the least-recently-used entries until the cache is back
+// under maxBytes. c.mu must be held.
+func (c *snippetCache) evict() {
+	for c.total > c.maxBytes && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.total -= c.sizes[oldest]
+		delete(c.sizes, oldest)
+		_ = os.Remove(filepath.Join(c.dir, oldest))
+		c.evicted++
+	}
+}
+
+// Stats returns a snapshot of the cache's usage counters.
+func (c *snippetCache) Stats() SnippetStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return SnippetStats{
+		Entries:    len(c.order),
+		TotalBytes: c.total,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evicted,
+	}
+}
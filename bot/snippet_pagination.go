@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/calebcase/clod/bot/chat"
+	"github.com/calebcase/clod/bot/metrics"
+	"github.com/rs/zerolog"
+)
+
+// snippetPageBytes caps how much of a tool snippet's content is uploaded to
+// Slack at once. Output beyond this is paginated via "Show more" / "Show
+// head/tail only" buttons (see snippetPaginationBlocks) instead of loading
+// the whole thing into one file upload.
+const snippetPageBytes = 32 * 1024
+
+// snippetLanguage returns a syntax-highlighting hint for a tool's output
+// snippet, passed through as UploadFileV2Parameters.SnippetType so Slack
+// renders it with the right highlighter.
+func snippetLanguage(toolName string, toolInput map[string]any) string {
+	switch toolName {
+	case "Read":
+		if filePath, ok := toolInput["file_path"].(string); ok {
+			if lang := languageFromExt(filepath.Ext(filePath)); lang != "" {
+				return lang
+			}
+		}
+		return "text"
+	case "Write", "Edit", "MultiEdit":
+		return "diff"
+	case "Bash":
+		command, _ := toolInput["command"].(string)
+		if interp := interpreterFromCommand(command); interp != "" {
+			return interp
+		}
+		return "shell"
+	case "WebFetch":
+		return "markdown"
+	case "Grep", "Glob":
+		return "text"
+	default:
+		// Most other tools (MCP servers in particular) return structured
+		// JSON, so that's a better default highlighter than plain text.
+		return "json"
+	}
+}
+
+// languageFromExt maps a file extension (as returned by filepath.Ext,
+// leading dot included) to a Slack snippet_type. Unrecognized extensions
+// return "".
+func languageFromExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".go":
+		return "go"
+	case ".py":
+		return "python"
+	case ".js", ".mjs", ".cjs":
+		return "javascript"
+	case ".ts", ".tsx":
+		return "typescript"
+	case ".rb":
+		return "ruby"
+	case ".rs":
+		return "rust"
+	case ".java":
+		return "java"
+	case ".c", ".h":
+		return "c"
+	case ".cpp", ".cc", ".hpp":
+		return "cpp"
+	case ".sh", ".bash":
+		return "shell"
+	case ".sql":
+		return "sql"
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".html", ".htm":
+		return "html"
+	case ".css":
+		return "css"
+	case ".md", ".markdown":
+		return "markdown"
+	default:
+		return ""
+	}
+}
+
+// interpreterFromCommand guesses a Bash command's scripting language from
+// its leading interpreter invocation (e.g. "python3 script.py -x" ->
+// "python"), so its output is highlighted as that language rather than as
+// generic shell. Returns "" if command doesn't start with a recognized
+// interpreter.
+func interpreterFromCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	switch filepath.Base(fields[0]) {
+	case "python", "python3", "python2":
+		return "python"
+	case "node", "nodejs":
+		return "javascript"
+	case "ruby":
+		return "ruby"
+	case "perl":
+		return "perl"
+	default:
+		return ""
+	}
+}
+
+// SnippetActionValue holds the data encoded in a paginated tool-snippet
+// button's action value: enough to stream a slice (or the full file)
+// straight from the on-disk snippet cache (see Runner.SnippetRange,
+// Runner.SnippetPath), without needing a still-pending permission request
+// the way PermissionActionValue does.
+type SnippetActionValue struct {
+	TaskPath  string `json:"p"`
+	Hash      string `json:"h"`
+	ToolName  string `json:"t"`
+	InputJSON string `json:"i"`
+	ThreadTS  string `json:"ts,omitempty"`
+	Offset    int64  `json:"o,omitempty"` // bytes already shown, for "Show more"
+}
+
+const (
+	snippetActionShowMore    = "snippet_show_more"
+	snippetActionHeadTail    = "snippet_head_tail"
+	snippetActionDownloadRaw = "snippet_download_raw"
+)
+
+// snippetPaginationBlock renders the controls shown under a truncated tool
+// snippet: "Show more" (if there's another page beyond offset), "Show
+// head/tail only", and "Download raw". ok is false if hash is empty (the
+// snippet cache write failed, so there's nothing on disk to paginate from).
+func snippetPaginationBlock(toolName, inputJSON, taskPath, hash, threadTS string, totalBytes, offset int64) (block chat.Block, ok bool) {
+	if hash == "" {
+		return nil, false
+	}
+
+	base := SnippetActionValue{
+		TaskPath:  taskPath,
+		Hash:      hash,
+		ToolName:  toolName,
+		InputJSON: inputJSON,
+		ThreadTS:  threadTS,
+	}
+
+	var buttons []chat.Button
+	if offset < totalBytes {
+		moreValue := base
+		moreValue.Offset = offset
+		v, _ := json.Marshal(moreValue)
+		buttons = append(buttons, chat.Button{ActionID: snippetActionShowMore, Value: string(v), Label: "Show More"})
+	}
+	if totalBytes > 2*snippetPageBytes {
+		v, _ := json.Marshal(base)
+		buttons = append(buttons, chat.Button{ActionID: snippetActionHeadTail, Label: "Show Head/Tail Only", Value: string(v)})
+	}
+	downloadValue, _ := json.Marshal(base)
+	buttons = append(buttons, chat.Button{ActionID: snippetActionDownloadRaw, Label: "Download Raw", Value: string(downloadValue)})
+
+	return chat.Actions{BlockID: "snippet_pagination", Buttons: buttons}, true
+}
+
+// snippetFilename builds a download filename for a cached snippet from its
+// tool context, mirroring the title logic in postToolSnippet closely enough
+// to be recognizable, but without depending on that function's local state.
+func snippetFilename(toolName, inputJSON string) string {
+	var input map[string]any
+	json.Unmarshal([]byte(inputJSON), &input)
+
+	if toolName == "Read" {
+		if filePath, ok := input["file_path"].(string); ok && filePath != "" {
+			return filepath.Base(filePath)
+		}
+	}
+	return fmt.Sprintf("%s-output.txt", strings.ToLower(toolName))
+}
+
+// handleSnippetAction dispatches a "Show more" / "Show head/tail only" /
+// "Download raw" click on a paginated tool snippet (see
+// snippetPaginationBlock) to the handler that streams the requested slice
+// from the on-disk snippet cache.
+func (h *Handler) handleSnippetAction(action *chat.InteractionEvent, logger zerolog.Logger) {
+	var v SnippetActionValue
+	if err := json.Unmarshal([]byte(action.Value), &v); err != nil {
+		logger.Error().Err(err).Str("value", action.Value).Msg("failed to decode snippet action value")
+		return
+	}
+	if h.bot.files == nil {
+		return
+	}
+
+	switch action.ActionID {
+	case snippetActionShowMore:
+		h.postSnippetPage(action.Channel, v, logger)
+	case snippetActionHeadTail:
+		h.postSnippetHeadTail(action.Channel, v, logger)
+	case snippetActionDownloadRaw:
+		path := h.bot.runner.SnippetPath(v.TaskPath, v.Hash)
+		filename := snippetFilename(v.ToolName, v.InputJSON)
+		if _, err := h.bot.files.UploadRawFile(path, filename, fmt.Sprintf(":inbox_tray: Raw `%s` output", v.ToolName), action.Channel, v.ThreadTS); err != nil {
+			logger.Error().Err(err).Msg("failed to upload raw snippet download")
+		}
+	}
+}
+
+// postSnippetPage uploads the next snippetPageBytes slice of a paginated
+// snippet, streamed straight from the on-disk cache, and attaches another
+// "Show more" (etc.) control if there's still more beyond it.
+func (h *Handler) postSnippetPage(channelID string, v SnippetActionValue, logger zerolog.Logger) {
+	total, err := h.bot.runner.SnippetSize(v.TaskPath, v.Hash)
+	if err != nil {
+		logger.Error().Err(err).Str("hash", v.Hash).Msg("failed to stat cached snippet for pagination")
+		return
+	}
+
+	chunk, err := h.bot.runner.SnippetRange(v.TaskPath, v.Hash, v.Offset, snippetPageBytes)
+	if err != nil {
+		logger.Error().Err(err).Str("hash", v.Hash).Msg("failed to read cached snippet page")
+		return
+	}
+
+	nextOffset := v.Offset + int64(len(chunk))
+	language := snippetLanguage(v.ToolName, decodeToolInput(v.InputJSON))
+	title := fmt.Sprintf("%s (bytes %d-%d of %d)", snippetFilename(v.ToolName, v.InputJSON), v.Offset, nextOffset, total)
+
+	if _, err := h.bot.files.UploadSnippet(string(chunk), title, "", language, channelID, v.ThreadTS); err != nil {
+		logger.Error().Err(err).Msg("failed to upload snippet page")
+		return
+	}
+	metrics.SnippetBytesUploaded.WithLabelValues(v.ToolName).Add(float64(len(chunk)))
+
+	if block, ok := snippetPaginationBlock(v.ToolName, v.InputJSON, v.TaskPath, v.Hash, v.ThreadTS, total, nextOffset); ok {
+		if _, err := h.bot.PostMessageBlocks(channelID, []chat.Block{block}, v.ThreadTS); err != nil {
+			logger.Error().Err(err).Msg("failed to post snippet pagination controls")
+		}
+	}
+}
+
+// postSnippetHeadTail uploads just the first and last snippetPageBytes of a
+// cached snippet, each streamed directly from disk, with a marker line in
+// between noting how much was skipped.
+func (h *Handler) postSnippetHeadTail(channelID string, v SnippetActionValue, logger zerolog.Logger) {
+	total, err := h.bot.runner.SnippetSize(v.TaskPath, v.Hash)
+	if err != nil {
+		logger.Error().Err(err).Str("hash", v.Hash).Msg("failed to stat cached snippet for head/tail")
+		return
+	}
+
+	head, err := h.bot.runner.SnippetRange(v.TaskPath, v.Hash, 0, snippetPageBytes)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to read cached snippet head")
+		return
+	}
+
+	tailOffset := total - snippetPageBytes
+	if tailOffset < int64(len(head)) {
+		tailOffset = int64(len(head))
+	}
+	tail, err := h.bot.runner.SnippetRange(v.TaskPath, v.Hash, tailOffset, total-tailOffset)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to read cached snippet tail")
+		return
+	}
+
+	skipped := tailOffset - int64(len(head))
+	var body strings.Builder
+	body.Write(head)
+	if skipped > 0 {
+		fmt.Fprintf(&body, "\n\n... %s skipped ...\n\n", formatBytes(int(skipped)))
+	}
+	body.Write(tail)
+
+	language := snippetLanguage(v.ToolName, decodeToolInput(v.InputJSON))
+	title := fmt.Sprintf("%s (head/tail of %s)", snippetFilename(v.ToolName, v.InputJSON), formatBytes(int(total)))
+	if _, err := h.bot.files.UploadSnippet(body.String(), title, "", language, channelID, v.ThreadTS); err != nil {
+		logger.Error().Err(err).Msg("failed to upload snippet head/tail")
+		return
+	}
+	metrics.SnippetBytesUploaded.WithLabelValues(v.ToolName).Add(float64(len(head) + len(tail)))
+}
+
+// decodeToolInput unmarshals a tool's JSON input as stashed in a
+// SnippetActionValue, for re-deriving the same language hint
+// snippetLanguage picked at the time the snippet was first posted.
+func decodeToolInput(inputJSON string) map[string]any {
+	var input map[string]any
+	json.Unmarshal([]byte(inputJSON), &input)
+	return input
+}
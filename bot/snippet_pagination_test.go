@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/calebcase/clod/bot/chat"
+)
+
+func TestSnippetLanguage(t *testing.T) {
+	cases := []struct {
+		tool  string
+		input map[string]any
+		want  string
+	}{
+		{"Read", map[string]any{"file_path": "/tmp/main.go"}, "go"},
+		{"Read", map[string]any{"file_path": "/tmp/unknown.xyz"}, "text"},
+		{"Write", map[string]any{"file_path": "/tmp/main.go"}, "diff"},
+		{"Edit", map[string]any{"file_path": "/tmp/main.go"}, "diff"},
+		{"Bash", map[string]any{"command": "python3 script.py"}, "python"},
+		{"Bash", map[string]any{"command": "ls -la"}, "shell"},
+		{"WebFetch", map[string]any{"url": "https://example.com"}, "markdown"},
+		{"Grep", map[string]any{"pattern": "foo"}, "text"},
+		{"SomeMCPTool", map[string]any{}, "json"},
+	}
+
+	for _, c := range cases {
+		if got := snippetLanguage(c.tool, c.input); got != c.want {
+			t.Errorf("snippetLanguage(%q, %v) = %q, want %q", c.tool, c.input, got, c.want)
+		}
+	}
+}
+
+func TestInterpreterFromCommand(t *testing.T) {
+	cases := []struct {
+		command string
+		want    string
+	}{
+		{"python3 script.py --flag", "python"},
+		{"node index.js", "javascript"},
+		{"ruby run.rb", "ruby"},
+		{"echo hello", ""},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		if got := interpreterFromCommand(c.command); got != c.want {
+			t.Errorf("interpreterFromCommand(%q) = %q, want %q", c.command, got, c.want)
+		}
+	}
+}
+
+func TestSnippetPaginationBlockNoHash(t *testing.T) {
+	if _, ok := snippetPaginationBlock("Bash", "{}", "/tmp/task", "", "", 100, 0); ok {
+		t.Error("expected ok=false when hash is empty")
+	}
+}
+
+func TestSnippetPaginationBlockButtons(t *testing.T) {
+	block, ok := snippetPaginationBlock("Bash", "{}", "/tmp/task", "deadbeef", "1234.5678", 100, 40)
+	if !ok {
+		t.Fatal("expected ok=true")
+	}
+	actions, ok := block.(chat.Actions)
+	if !ok {
+		t.Fatalf("expected a chat.Actions block, got %T", block)
+	}
+
+	var labels []string
+	for _, b := range actions.Buttons {
+		labels = append(labels, b.Label)
+	}
+	wantLabels := []string{"Show More", "Download Raw"}
+	if len(labels) != len(wantLabels) {
+		t.Fatalf("got buttons %v, want %v", labels, wantLabels)
+	}
+	for i, want := range wantLabels {
+		if labels[i] != want {
+			t.Errorf("button %d: got %q, want %q", i, labels[i], want)
+		}
+	}
+}
+
+func TestSnippetPaginationBlockHeadTailShownForLargeOutput(t *testing.T) {
+	block, ok := snippetPaginationBlock("Bash", "{}", "/tmp/task", "deadbeef", "1234.5678", int64(3*snippetPageBytes), int64(3*snippetPageBytes))
+	if !ok {
+		t.Fatal("expected ok=true")
+	}
+	actions := block.(chat.Actions)
+
+	found := false
+	for _, b := range actions.Buttons {
+		if b.Label == "Show Head/Tail Only" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected a Show Head/Tail Only button for output over 2x the page size")
+	}
+}
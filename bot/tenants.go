@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+
+	"github.com/calebcase/oops"
+	"gopkg.in/yaml.v3"
+)
+
+// TenantConfig configures one Slack workspace served by this process.
+// AgentsPath and SessionStore default to the process-wide
+// CLI.AgentsPath/CLI.SessionStore when left empty, so a tenant entry only
+// needs to override what differs from the rest; SessionStore in particular
+// should usually be given its own namespace (a distinct file path, or a
+// distinct key prefix/DB for networked backends) so tenants' sessions don't
+// collide.
+type TenantConfig struct {
+	ID            string   `yaml:"id"`
+	SlackBotToken string   `yaml:"slack_bot_token"`
+	SlackAppToken string   `yaml:"slack_app_token"`
+	AllowedUsers  []string `yaml:"allowed_users"`
+	AgentsPath    string   `yaml:"agents_path,omitempty"`
+	SessionStore  string   `yaml:"session_store,omitempty"`
+
+	// SlackEventsAddr and SlackSigningSecret, if set, run this tenant's
+	// Events API HTTP receiver (see transport/slack.RunHTTP) alongside its
+	// Socket Mode connection on their own address; both are required
+	// together.
+	SlackEventsAddr    string `yaml:"slack_events_addr,omitempty"`
+	SlackSigningSecret string `yaml:"slack_signing_secret,omitempty"`
+}
+
+// TenantsConfig is the on-disk (YAML) shape of the --tenants file: one
+// entry per Slack workspace this process serves.
+type TenantsConfig struct {
+	Tenants []TenantConfig `yaml:"tenants"`
+}
+
+// LoadTenantsConfig reads and validates a --tenants YAML file.
+func LoadTenantsConfig(path string) (*TenantsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	var config TenantsConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	if len(config.Tenants) == 0 {
+		return nil, oops.New("tenants config %q defines no tenants", path)
+	}
+
+	seen := make(map[string]bool, len(config.Tenants))
+	for _, tenant := range config.Tenants {
+		if tenant.ID == "" {
+			return nil, oops.New("tenants config %q: tenant missing id", path)
+		}
+		if seen[tenant.ID] {
+			return nil, oops.New("tenants config %q: duplicate tenant id %q", path, tenant.ID)
+		}
+		seen[tenant.ID] = true
+
+		if tenant.SlackBotToken == "" || tenant.SlackAppToken == "" {
+			return nil, oops.New("tenants config %q: tenant %q missing slack_bot_token/slack_app_token", path, tenant.ID)
+		}
+
+		if (tenant.SlackEventsAddr == "") != (tenant.SlackSigningSecret == "") {
+			return nil, oops.New("tenants config %q: tenant %q must set both slack_events_addr and slack_signing_secret, or neither", path, tenant.ID)
+		}
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTenantsConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write tenants config: %v", err)
+	}
+	return path
+}
+
+func TestLoadTenantsConfig(t *testing.T) {
+	path := writeTenantsConfig(t, `
+tenants:
+  - id: acme
+    slack_bot_token: xoxb-acme
+    slack_app_token: xapp-acme
+    allowed_users: [U1, U2]
+    session_store: acme_sessions.json
+  - id: globex
+    slack_bot_token: xoxb-globex
+    slack_app_token: xapp-globex
+    agents_path: /srv/globex/agents
+`)
+
+	config, err := LoadTenantsConfig(path)
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig: %v", err)
+	}
+
+	if len(config.Tenants) != 2 {
+		t.Fatalf("got %d tenants, want 2", len(config.Tenants))
+	}
+	if config.Tenants[0].ID != "acme" || len(config.Tenants[0].AllowedUsers) != 2 {
+		t.Fatalf("unexpected first tenant: %+v", config.Tenants[0])
+	}
+	if config.Tenants[1].AgentsPath != "/srv/globex/agents" {
+		t.Fatalf("unexpected second tenant agents path: %+v", config.Tenants[1])
+	}
+}
+
+func TestLoadTenantsConfigRejectsDuplicateID(t *testing.T) {
+	path := writeTenantsConfig(t, `
+tenants:
+  - id: acme
+    slack_bot_token: xoxb-1
+    slack_app_token: xapp-1
+  - id: acme
+    slack_bot_token: xoxb-2
+    slack_app_token: xapp-2
+`)
+
+	if _, err := LoadTenantsConfig(path); err == nil {
+		t.Fatal("expected an error for duplicate tenant id, got nil")
+	}
+}
+
+func TestLoadTenantsConfigRejectsMissingTokens(t *testing.T) {
+	path := writeTenantsConfig(t, `
+tenants:
+  - id: acme
+    slack_bot_token: xoxb-1
+`)
+
+	if _, err := LoadTenantsConfig(path); err == nil {
+		t.Fatal("expected an error for a tenant missing slack_app_token, got nil")
+	}
+}
+
+func TestLoadTenantsConfigRejectsEmpty(t *testing.T) {
+	path := writeTenantsConfig(t, "tenants: []\n")
+
+	if _, err := LoadTenantsConfig(path); err == nil {
+		t.Fatal("expected an error for an empty tenants list, got nil")
+	}
+}
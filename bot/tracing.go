@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"github.com/calebcase/oops"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies this package's spans in a trace backend; it has no
+// effect on behavior.
+const tracerName = "github.com/calebcase/clod/bot"
+
+// tracer is used to start every span this package emits (see
+// RunningTask/runAttempt in runner.go). Before newTracerProvider installs a
+// real SDK provider, otel.Tracer returns a no-op implementation, so spans
+// are always safe to create even when tracing isn't configured.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// newTracerProvider builds an SDK TracerProvider that exports spans to
+// endpoint (an OTLP/HTTP collector address, e.g. "localhost:4318") and
+// installs it as the global provider, so every tracer() call across the
+// process picks it up. The caller must call the returned shutdown func
+// before exit to flush pending spans.
+func newTracerProvider(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+	if err != nil {
+		return nil, oops.Trace(err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName("clod-bot"),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
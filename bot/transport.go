@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/calebcase/oops"
+	"github.com/rs/zerolog"
+)
+
+// Transport abstracts how a RunningTask exchanges stream messages with a
+// clod subprocess, so Runner can support wire formats other than the
+// default PTY/stream-json pipe (see jsonrpc2Transport).
+type Transport interface {
+	// Send delivers a user input message to clod.
+	Send(InputMessage) error
+	// Recv blocks until the next stream message is available, returning
+	// io.EOF once clod's output is exhausted.
+	Recv() (StreamMessage, error)
+	// Close releases the underlying transport.
+	Close() error
+}
+
+// PermissionTransport is implemented by transports that carry permission
+// requests/responses in-band instead of via PermissionFIFO's side-channel
+// FIFOs.
+type PermissionTransport interface {
+	PermissionRequests() <-chan PermissionRequest
+	SendPermissionResponse(PermissionResponse)
+}
+
+// pttyTransport is the default Transport: clod is driven over a PTY using
+// stream-json for both input and output.
+type pttyTransport struct {
+	f       *os.File
+	scanner *bufio.Scanner
+}
+
+// newPttyTransport wraps an already-started clod PTY.
+func newPttyTransport(f *os.File) *pttyTransport {
+	scanner := bufio.NewScanner(f)
+	// Increase buffer size for long lines.
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+	return &pttyTransport{f: f, scanner: scanner}
+}
+
+func (t *pttyTransport) Send(msg InputMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return oops.Trace(err)
+	}
+	if _, err := t.f.Write(append(data, '\n')); err != nil {
+		return oops.Trace(err)
+	}
+	return nil
+}
+
+func (t *pttyTransport) Recv() (StreamMessage, error) {
+	for t.scanner.Scan() {
+		line := t.scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		var msg StreamMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			continue
+		}
+		return msg, nil
+	}
+	if err := t.scanner.Err(); err != nil {
+		return StreamMessage{}, oops.Trace(err)
+	}
+	return StreamMessage{}, io.EOF
+}
+
+func (t *pttyTransport) Close() error {
+	return t.f.Close()
+}
+
+// jsonrpcEnvelope is a JSON-RPC 2.0 message, used for both directions over
+// clod's stdio when Runner.TransportKind is transportJSONRPC2.
+type jsonrpcEnvelope struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonrpcResponse is the response half of a JSON-RPC 2.0 request/response
+// pair, used to answer clod's "permission/request".
+type jsonrpcResponse struct {
+	JSONRPC string             `json:"jsonrpc"`
+	ID      json.RawMessage    `json:"id"`
+	Result  PermissionResponse `json:"result"`
+}
+
+// jsonrpc2Transport is an opt-in ("canary") Transport that speaks JSON-RPC
+// 2.0 over clod's stdin/stdout instead of a PTY running stream-json. It
+// makes headless execution possible in environments (e.g. containers)
+// where allocating a PTY is undesirable.
+//
+// Incoming "assistant/text", "assistant/tool_use", "user/tool_result",
+// "content_block_delta" and "result" notifications are translated into the
+// same StreamMessage shape runAttempt already understands. "permission/
+// request" is a JSON-RPC request rather than a notification: it is
+// surfaced via PermissionRequests/SendPermissionResponse instead of Recv,
+// with the response correlated back to clod by the request's JSON-RPC id,
+// replacing PermissionFIFO's out-of-band FIFOs entirely.
+type jsonrpc2Transport struct {
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	logger zerolog.Logger
+
+	msgs  chan StreamMessage
+	perms chan PermissionRequest
+
+	mu        sync.Mutex
+	pendingID json.RawMessage // id of the most recent unanswered permission/request.
+
+	recvErr error
+}
+
+// newJSONRPC2Transport wraps an already-started clod process's stdio and
+// begins demuxing its output in the background.
+func newJSONRPC2Transport(stdin io.WriteCloser, stdout io.ReadCloser, logger zerolog.Logger) *jsonrpc2Transport {
+	t := &jsonrpc2Transport{
+		stdin:  stdin,
+		stdout: stdout,
+		logger: logger.With().Str("component", "jsonrpc2_transport").Logger(),
+		msgs:   make(chan StreamMessage, 100),
+		perms:  make(chan PermissionRequest, 10),
+	}
+	go t.readLoop()
+	return t
+}
+
+// readLoop demuxes clod's stdout into regular stream messages (msgs) and
+// permission requests (perms) until clod closes stdout.
+func (t *jsonrpc2Transport) readLoop() {
+	defer close(t.msgs)
+	defer close(t.perms)
+
+	scanner := bufio.NewScanner(t.stdout)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		var env jsonrpcEnvelope
+		if err := json.Unmarshal([]byte(line), &env); err != nil {
+			t.logger.Debug().Str("line", line).Err(err).Msg("failed to parse jsonrpc2 line")
+			continue
+		}
+
+		if env.Method == "permission/request" {
+			var req PermissionRequest
+			if err := json.Unmarshal(env.Params, &req); err != nil {
+				t.logger.Error().Err(err).Msg("failed to parse permission/request params")
+				continue
+			}
+			t.mu.Lock()
+			t.pendingID = env.ID
+			t.mu.Unlock()
+			t.perms <- req
+			continue
+		}
+
+		msg, ok := t.translate(env)
+		if !ok {
+			continue
+		}
+		t.msgs <- msg
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.recvErr = oops.Trace(err)
+	}
+}
+
+// translate maps one JSON-RPC notification onto the StreamMessage shape
+// runAttempt already knows how to handle, so the parsing logic in
+// runAttempt stays transport-agnostic.
+func (t *jsonrpc2Transport) translate(env jsonrpcEnvelope) (StreamMessage, bool) {
+	switch env.Method {
+	case "assistant/text":
+		var p struct {
+			SessionID string `json:"session_id"`
+			Text      string `json:"text"`
+		}
+		if err := json.Unmarshal(env.Params, &p); err != nil {
+			return StreamMessage{}, false
+		}
+		return StreamMessage{
+			Type:      "assistant",
+			SessionID: p.SessionID,
+			Message:   &StreamMsgBody{Content: []StreamContentBlock{{Type: "text", Text: p.Text}}},
+		}, true
+
+	case "assistant/tool_use":
+		var p struct {
+			SessionID string         `json:"session_id"`
+			ID        string         `json:"id"`
+			Name      string         `json:"name"`
+			Input     map[string]any `json:"input"`
+		}
+		if err := json.Unmarshal(env.Params, &p); err != nil {
+			return StreamMessage{}, false
+		}
+		return StreamMessage{
+			Type:      "assistant",
+			SessionID: p.SessionID,
+			Message: &StreamMsgBody{Content: []StreamContentBlock{{
+				Type: "tool_use", ID: p.ID, Name: p.Name, Input: p.Input,
+			}}},
+		}, true
+
+	case "user/tool_result":
+		var p struct {
+			ToolUseID string          `json:"tool_use_id"`
+			Content   json.RawMessage `json:"content"`
+			IsError   bool            `json:"is_error"`
+		}
+		if err := json.Unmarshal(env.Params, &p); err != nil {
+			return StreamMessage{}, false
+		}
+		return StreamMessage{
+			Type: "user",
+			Message: &StreamMsgBody{Content: []StreamContentBlock{{
+				Type: "tool_result", ToolUseID: p.ToolUseID, Content: p.Content, IsError: p.IsError,
+			}}},
+		}, true
+
+	case "content_block_delta":
+		var delta ContentBlockDelta
+		if err := json.Unmarshal(env.Params, &delta); err != nil {
+			return StreamMessage{}, false
+		}
+		return StreamMessage{Type: "content_block_delta", ContentBlockDelta: &delta}, true
+
+	case "result":
+		var msg StreamMessage
+		if err := json.Unmarshal(env.Params, &msg); err != nil {
+			return StreamMessage{}, false
+		}
+		msg.Type = "result"
+		return msg, true
+
+	default:
+		t.logger.Debug().Str("method", env.Method).Msg("ignoring unrecognized jsonrpc2 notification")
+		return StreamMessage{}, false
+	}
+}
+
+func (t *jsonrpc2Transport) Send(msg InputMessage) error {
+	data, err := json.Marshal(msg.Message)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	env := jsonrpcEnvelope{JSONRPC: "2.0", Method: "user/input", Params: data}
+	line, err := json.Marshal(env)
+	if err != nil {
+		return oops.Trace(err)
+	}
+
+	if _, err := t.stdin.Write(append(line, '\n')); err != nil {
+		return oops.Trace(err)
+	}
+	return nil
+}
+
+func (t *jsonrpc2Transport) Recv() (StreamMessage, error) {
+	msg, ok := <-t.msgs
+	if !ok {
+		if t.recvErr != nil {
+			return StreamMessage{}, t.recvErr
+		}
+		return StreamMessage{}, io.EOF
+	}
+	return msg, nil
+}
+
+// PermissionRequests returns the channel of in-band permission requests.
+func (t *jsonrpc2Transport) PermissionRequests() <-chan PermissionRequest {
+	return t.perms
+}
+
+// SendPermissionResponse answers the most recently received
+// permission/request by its JSON-RPC id.
+func (t *jsonrpc2Transport) SendPermissionResponse(resp PermissionResponse) {
+	t.mu.Lock()
+	id := t.pendingID
+	t.pendingID = nil
+	t.mu.Unlock()
+
+	if id == nil {
+		t.logger.Warn().Str("behavior", resp.Behavior).Msg("no pending permission request, dropping response")
+		return
+	}
+
+	data, err := json.Marshal(jsonrpcResponse{JSONRPC: "2.0", ID: id, Result: resp})
+	if err != nil {
+		t.logger.Error().Err(err).Msg("failed to marshal permission response")
+		return
+	}
+
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		t.logger.Error().Err(err).Msg("failed to write permission response")
+	}
+}
+
+func (t *jsonrpc2Transport) Close() error {
+	stdinErr := t.stdin.Close()
+	stdoutErr := t.stdout.Close()
+	if stdinErr != nil {
+		return oops.Trace(stdinErr)
+	}
+	if stdoutErr != nil {
+		return oops.Trace(stdoutErr)
+	}
+	return nil
+}
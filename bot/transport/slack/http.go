@@ -0,0 +1,100 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/calebcase/oops"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// ServeEventsAPI returns an http.Handler that accepts Slack Events API
+// callbacks delivered over HTTP instead of (or alongside) Socket Mode. Each
+// request's signature is verified against signingSecret before it's parsed,
+// and a url_verification challenge is answered directly rather than being
+// turned into a ChatEvent. Everything else is handed to the same
+// dispatchEventsAPI used by the Socket Mode handler, so app_mention,
+// message, and reaction events reach Handler identically regardless of
+// which path delivered them.
+func (t *Transport) ServeEventsAPI(signingSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.logger.Warn().Err(err).Msg("events api: failed to read request body")
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		sv, err := slack.NewSecretsVerifier(r.Header, signingSecret)
+		if err != nil {
+			t.logger.Warn().Err(err).Msg("events api: missing or malformed signature headers")
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if _, err := sv.Write(body); err != nil {
+			t.logger.Warn().Err(err).Msg("events api: failed to hash request body")
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if err := sv.Ensure(); err != nil {
+			t.logger.Warn().Err(err).Msg("events api: signature verification failed")
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		eventsAPIEvent, err := slackevents.ParseEvent(body, slackevents.OptionNoVerifyToken())
+		if err != nil {
+			t.logger.Warn().Err(err).Msg("events api: failed to parse event")
+			http.Error(w, "failed to parse event", http.StatusBadRequest)
+			return
+		}
+
+		if eventsAPIEvent.Type == slackevents.URLVerification {
+			var challenge slackevents.ChallengeResponse
+			if err := json.Unmarshal(body, &challenge); err != nil {
+				t.logger.Warn().Err(err).Msg("events api: failed to parse url_verification challenge")
+				http.Error(w, "failed to parse challenge", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(challenge.Challenge))
+			return
+		}
+
+		// Slack requires a 200 within 3 seconds of delivery; acknowledge
+		// now and dispatch afterward rather than making delivery wait on
+		// Handler.
+		w.WriteHeader(http.StatusOK)
+
+		t.dispatchEventsAPI(eventsAPIEvent, func() {})
+	})
+}
+
+// RunHTTP serves Slack Events API callbacks on addr until ctx is cancelled,
+// feeding parsed events into the same channel Run's Socket Mode connection
+// does. The two are independent: a deployment can run both to keep
+// interactive components and slash commands on Socket Mode while moving
+// Events API traffic to an HTTP endpoint behind a load balancer, or run
+// HTTP alone by never calling Run.
+func (t *Transport) RunHTTP(ctx context.Context, addr, signingSecret string) error {
+	server := &http.Server{Addr: addr, Handler: t.ServeEventsAPI(signingSecret)}
+
+	errors := make(chan error, 1)
+	go func() {
+		t.logger.Info().Str("addr", addr).Msg("serving Slack Events API over HTTP")
+		errors <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errors:
+		if err != nil && err != http.ErrServerClosed {
+			return oops.Trace(err)
+		}
+		return nil
+	case <-ctx.Done():
+		return oops.Trace(server.Shutdown(context.Background()))
+	}
+}
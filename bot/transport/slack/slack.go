@@ -0,0 +1,547 @@
+// Package slack implements chat.ChatTransport over a Slack Socket Mode
+// connection. It is the bot's original (and default) chat backend, moved
+// here unchanged in behavior so Bot, Handler, PermissionFIFO, and Runner
+// can depend on chat.ChatTransport instead of the slack-go client directly.
+package slack
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/calebcase/clod/bot/chat"
+	"github.com/calebcase/clod/bot/metrics"
+	"github.com/calebcase/oops"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	// defaultBaseDelay is the initial delay before the first Socket Mode
+	// reconnect attempt.
+	defaultBaseDelay = 1 * time.Second
+	// defaultMaxDelay caps the exponential backoff between reconnect
+	// attempts.
+	defaultMaxDelay = 2 * time.Minute
+	// defaultJitter randomizes each reconnect delay by up to this
+	// fraction so that a Slack-wide outage doesn't make every bot
+	// instance reconnect in lockstep.
+	defaultJitter = 0.2
+)
+
+// Options configures Transport.Run's reconnection supervisor.
+type Options struct {
+	// MaxRetries caps how many times Run reconnects after
+	// RunEventLoopContext returns an error before giving up and
+	// returning the error to the caller. 0 means retry indefinitely,
+	// which is the default for a long-running bot.
+	MaxRetries int
+	// BaseDelay is the initial delay before the first reconnect attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential backoff between reconnect attempts.
+	MaxDelay time.Duration
+	// Jitter randomizes each delay by up to this fraction (e.g. 0.2 means
+	// ±20%).
+	Jitter float64
+}
+
+// Transport is a chat.ChatTransport backed by a Slack Socket Mode
+// connection.
+type Transport struct {
+	client        *slack.Client
+	socket        *socketmode.Client
+	socketHandler *socketmode.SocketmodeHandler
+	logger        zerolog.Logger
+
+	events chan chat.ChatEvent
+
+	// Options configures Run's reconnection supervisor. Set by New to
+	// sensible defaults; callers may override fields before calling Run.
+	Options Options
+}
+
+// New creates a Slack Socket Mode transport. Call Run to connect and begin
+// delivering events.
+func New(botToken, appToken string, logger zerolog.Logger) *Transport {
+	client := slack.New(
+		botToken,
+		slack.OptionAppLevelToken(appToken),
+	)
+
+	socket := socketmode.New(
+		client,
+		socketmode.OptionDebug(logger.GetLevel() <= zerolog.DebugLevel),
+	)
+
+	t := &Transport{
+		client: client,
+		socket: socket,
+		logger: logger.With().Str("component", "transport.slack").Logger(),
+		events: make(chan chat.ChatEvent, 64),
+		Options: Options{
+			BaseDelay: defaultBaseDelay,
+			MaxDelay:  defaultMaxDelay,
+			Jitter:    defaultJitter,
+		},
+	}
+	t.socketHandler = socketmode.NewSocketmodeHandler(socket)
+	t.registerEventHandlers()
+
+	return t
+}
+
+// Client returns the underlying Slack API client, for components (e.g.
+// FileHandler, Authorizer) that still need direct Slack access for features
+// chat.ChatTransport doesn't cover.
+func (t *Transport) Client() *slack.Client {
+	return t.client
+}
+
+// Events implements chat.ChatTransport.
+func (t *Transport) Events() <-chan chat.ChatEvent {
+	return t.events
+}
+
+// Run connects to Slack over Socket Mode and delivers events until ctx is
+// cancelled. RunEventLoopContext only returns an error when Slack's own
+// reconnection fails outright, so Run wraps it in a supervisor that
+// recreates the socket and reconnects with exponential backoff and jitter
+// (see Options), re-registering handlers against the new socket each time.
+func (t *Transport) Run(ctx context.Context) error {
+	delay := t.Options.BaseDelay
+	if delay <= 0 {
+		delay = defaultBaseDelay
+	}
+	maxDelay := t.Options.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+
+	attempt := 0
+	for {
+		t.logger.Info().Msg("starting socket mode connection")
+		t.emitConnectionState(chat.ConnectionStateConnected)
+
+		err := t.socketHandler.RunEventLoopContext(ctx)
+		if ctx.Err() != nil || err == nil {
+			t.emitConnectionState(chat.ConnectionStateDisconnected)
+			return nil
+		}
+
+		attempt++
+		if t.Options.MaxRetries > 0 && attempt > t.Options.MaxRetries {
+			t.emitConnectionState(chat.ConnectionStateDisconnected)
+			return oops.Trace(err)
+		}
+
+		wait := jitteredDelay(delay, t.Options.Jitter)
+		t.logger.Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("delay", wait).
+			Msg("socket mode connection lost, reconnecting")
+		t.emitConnectionState(chat.ConnectionStateReconnecting)
+
+		select {
+		case <-ctx.Done():
+			t.emitConnectionState(chat.ConnectionStateDisconnected)
+			return nil
+		case <-time.After(wait):
+		}
+		delay = nextBackoff(delay, maxDelay)
+
+		// The failed connection's socket and handler don't survive a
+		// reconnect, so both are recreated and handlers re-registered
+		// against the new socket before looping back into
+		// RunEventLoopContext.
+		t.socket = socketmode.New(
+			t.client,
+			socketmode.OptionDebug(t.logger.GetLevel() <= zerolog.DebugLevel),
+		)
+		t.socketHandler = socketmode.NewSocketmodeHandler(t.socket)
+		t.registerEventHandlers()
+	}
+}
+
+// emitConnectionState publishes a connection state change as a ChatEvent,
+// dropping it if the events channel is full rather than blocking the
+// reconnect supervisor.
+func (t *Transport) emitConnectionState(state chat.ConnectionState) {
+	select {
+	case t.events <- chat.ChatEvent{Type: chat.EventConnection, State: state}:
+	default:
+		t.logger.Debug().Str("state", string(state)).Msg("events channel full, dropping connection state")
+	}
+}
+
+// nextBackoff doubles delay, capped at max.
+func nextBackoff(delay, max time.Duration) time.Duration {
+	delay *= 2
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// jitteredDelay randomizes base by up to ±jitter (a fraction, e.g. 0.2 for
+// ±20%), so that many bot instances reconnecting after the same outage
+// don't all retry in lockstep.
+func jitteredDelay(base time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return base
+	}
+	spread := float64(base) * jitter
+	offset := (rand.Float64()*2 - 1) * spread
+	d := base + time.Duration(offset)
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// registerEventHandlers sets up all the socketmode handler callbacks.
+func (t *Transport) registerEventHandlers() {
+	t.socketHandler.Handle(socketmode.EventTypeEventsAPI, t.handleEventsAPI)
+	t.socketHandler.Handle(socketmode.EventTypeInteractive, t.handleInteractive)
+	t.socketHandler.Handle(socketmode.EventTypeSlashCommand, t.handleSlashCommand)
+
+	t.socketHandler.Handle(socketmode.EventTypeConnecting, func(evt *socketmode.Event, client *socketmode.Client) {
+		t.logger.Info().Msg("connecting to Slack...")
+	})
+	t.socketHandler.Handle(socketmode.EventTypeConnected, func(evt *socketmode.Event, client *socketmode.Client) {
+		t.logger.Info().Msg("connected to Slack")
+	})
+	t.socketHandler.Handle(socketmode.EventTypeConnectionError, func(evt *socketmode.Event, client *socketmode.Client) {
+		t.logger.Error().Msg("connection error")
+	})
+	t.socketHandler.Handle(socketmode.EventTypeHello, func(evt *socketmode.Event, client *socketmode.Client) {
+		t.logger.Debug().Msg("received hello from Slack")
+	})
+}
+
+// ackFunc returns the AckToken for a Socket Mode envelope: a closure that
+// acks it against the socket client it arrived on.
+func ackFunc(client *socketmode.Client, evt *socketmode.Event) func() {
+	return func() {
+		client.Ack(*evt.Request)
+	}
+}
+
+// handleEventsAPI is the socketmode handler for Events API events.
+func (t *Transport) handleEventsAPI(evt *socketmode.Event, client *socketmode.Client) {
+	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+	if !ok {
+		t.logger.Warn().Interface("data", evt.Data).Msg("failed to cast EventsAPI event")
+		return
+	}
+
+	t.dispatchEventsAPI(eventsAPIEvent, ackFunc(client, evt))
+}
+
+// dispatchEventsAPI converts one parsed Events API callback into a
+// chat.ChatEvent and sends it, regardless of whether it arrived over Socket
+// Mode (handleEventsAPI) or the HTTP receiver (ServeEventsAPI). ack is
+// called once the event has been handed off; Socket Mode's ack tells Slack
+// to retire the envelope, while the HTTP receiver has already written its
+// response and passes a no-op.
+func (t *Transport) dispatchEventsAPI(eventsAPIEvent slackevents.EventsAPIEvent, ack func()) {
+	if eventsAPIEvent.Type != slackevents.CallbackEvent {
+		t.logger.Debug().Str("type", eventsAPIEvent.Type).Msg("unhandled Events API event type")
+		return
+	}
+
+	switch ev := eventsAPIEvent.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		t.send(chat.ChatEvent{
+			Type:            chat.EventAppMention,
+			Channel:         ev.Channel,
+			User:            ev.User,
+			Text:            ev.Text,
+			TimeStamp:       ev.TimeStamp,
+			ThreadTimeStamp: ev.ThreadTimeStamp,
+			AckToken:        ack,
+		})
+	case *slackevents.MessageEvent:
+		t.sendMessageEvent(ev, ack)
+	case *slackevents.ReactionAddedEvent:
+		t.send(chat.ChatEvent{
+			Type:      chat.EventReactionAdded,
+			Channel:   ev.Item.Channel,
+			User:      ev.User,
+			Reaction:  ev.Reaction,
+			TimeStamp: ev.Item.Timestamp,
+			AckToken:  ack,
+		})
+	case *slackevents.ReactionRemovedEvent:
+		t.send(chat.ChatEvent{
+			Type:      chat.EventReactionRemoved,
+			Channel:   ev.Item.Channel,
+			User:      ev.User,
+			Reaction:  ev.Reaction,
+			TimeStamp: ev.Item.Timestamp,
+			AckToken:  ack,
+		})
+	case *slackevents.UserChangeEvent:
+		t.send(chat.ChatEvent{
+			Type:     chat.EventUserChange,
+			User:     ev.User.ID,
+			AckToken: ack,
+		})
+	case *slackevents.TeamJoinEvent:
+		if ev.User != nil {
+			t.send(chat.ChatEvent{
+				Type:     chat.EventTeamJoin,
+				User:     ev.User.ID,
+				AckToken: ack,
+			})
+		} else {
+			ack()
+		}
+	case *slackevents.ChannelRenameEvent:
+		t.send(chat.ChatEvent{
+			Type:     chat.EventChannelRename,
+			Channel:  ev.Channel.ID,
+			AckToken: ack,
+		})
+	case *slackevents.FileDeletedEvent:
+		t.send(chat.ChatEvent{
+			Type:      chat.EventFileDeleted,
+			FileID:    ev.FileID,
+			TimeStamp: ev.EventTimestamp,
+			AckToken:  ack,
+		})
+	default:
+		t.logger.Debug().Str("type", eventsAPIEvent.InnerEvent.Type).Msg("unhandled callback event type")
+		ack()
+	}
+}
+
+// sendMessageEvent converts a Slack MessageEvent into the right ChatEvent,
+// distinguishing ordinary messages from the message_changed/message_deleted
+// subtypes so Handler can treat edits as corrections and deletions as
+// cancellations.
+func (t *Transport) sendMessageEvent(ev *slackevents.MessageEvent, ack func()) {
+	switch ev.SubType {
+	case "message_changed":
+		if ev.Message == nil {
+			t.logger.Warn().Msg("message_changed event with no message payload")
+			ack()
+			return
+		}
+		t.send(chat.ChatEvent{
+			Type:            chat.EventMessageChanged,
+			Channel:         ev.Channel,
+			User:            ev.Message.User,
+			BotID:           ev.Message.BotID,
+			Text:            ev.Message.Text,
+			TimeStamp:       ev.Message.Timestamp,
+			ThreadTimeStamp: ev.Message.ThreadTimestamp,
+			AckToken:        ack,
+		})
+	case "message_deleted":
+		threadTS := ""
+		user := ""
+		if ev.PreviousMessage != nil {
+			threadTS = ev.PreviousMessage.ThreadTimestamp
+			user = ev.PreviousMessage.User
+		}
+		t.send(chat.ChatEvent{
+			Type:            chat.EventMessageDeleted,
+			Channel:         ev.Channel,
+			User:            user,
+			TimeStamp:       ev.DeletedTimeStamp,
+			ThreadTimeStamp: threadTS,
+			AckToken:        ack,
+		})
+	default:
+		t.send(chat.ChatEvent{
+			Type:            chat.EventMessage,
+			Channel:         ev.Channel,
+			User:            ev.User,
+			BotID:           ev.BotID,
+			Text:            ev.Text,
+			TimeStamp:       ev.TimeStamp,
+			ThreadTimeStamp: ev.ThreadTimeStamp,
+			AckToken:        ack,
+		})
+	}
+}
+
+// handleInteractive is the socketmode handler for interactive events
+// (button clicks, etc).
+func (t *Transport) handleInteractive(evt *socketmode.Event, client *socketmode.Client) {
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok {
+		t.logger.Warn().Interface("data", evt.Data).Msg("failed to cast interactive callback")
+		return
+	}
+
+	ack := ackFunc(client, evt)
+
+	if callback.Type != slack.InteractionTypeBlockActions {
+		t.logger.Debug().Str("type", string(callback.Type)).Msg("unhandled interactive callback type")
+		ack()
+		return
+	}
+
+	if len(callback.ActionCallback.BlockActions) == 0 {
+		t.logger.Warn().Msg("no block actions found in callback")
+		ack()
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		t.send(chat.ChatEvent{
+			Type:      chat.EventInteraction,
+			Channel:   callback.Channel.ID,
+			User:      callback.User.ID,
+			TimeStamp: callback.Message.Timestamp,
+			Interaction: &chat.InteractionEvent{
+				ActionID:  action.ActionID,
+				Value:     action.Value,
+				UserID:    callback.User.ID,
+				UserName:  callback.User.Name,
+				Channel:   callback.Channel.ID,
+				MessageTS: callback.Message.Timestamp,
+			},
+			AckToken: ack,
+		})
+	}
+}
+
+// handleSlashCommand is the socketmode handler for slash command invocations
+// (e.g. "/clod deprecation upstream-deprecation.md").
+func (t *Transport) handleSlashCommand(evt *socketmode.Event, client *socketmode.Client) {
+	cmd, ok := evt.Data.(slack.SlashCommand)
+	if !ok {
+		t.logger.Warn().Interface("data", evt.Data).Msg("failed to cast slash command event")
+		return
+	}
+
+	t.send(chat.ChatEvent{
+		Type:     chat.EventSlashCommand,
+		Channel:  cmd.ChannelID,
+		User:     cmd.UserID,
+		Text:     cmd.Text,
+		Command:  cmd.Command,
+		AckToken: ackFunc(client, evt),
+	})
+}
+
+// send delivers evt, dropping it (with a warning) rather than blocking
+// forever if the consumer has fallen behind.
+func (t *Transport) send(evt chat.ChatEvent) {
+	select {
+	case t.events <- evt:
+	default:
+		t.logger.Warn().Str("type", string(evt.Type)).Msg("events channel full, dropping event")
+	}
+}
+
+// Ack implements chat.ChatTransport.
+func (t *Transport) Ack(evt chat.ChatEvent) {
+	if fn, ok := evt.AckToken.(func()); ok && fn != nil {
+		fn()
+	}
+}
+
+// PostMessage implements chat.ChatTransport.
+func (t *Transport) PostMessage(channelID, text, threadTS string) (string, error) {
+	opts := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+
+	_, ts, err := t.client.PostMessage(channelID, opts...)
+	if err != nil {
+		metrics.SlackAPIErrors.WithLabelValues("PostMessage").Inc()
+		return "", oops.Trace(err)
+	}
+	return ts, nil
+}
+
+// UpdateMessage implements chat.ChatTransport.
+func (t *Transport) UpdateMessage(channelID, ts, text string) error {
+	_, _, _, err := t.client.UpdateMessage(channelID, ts, slack.MsgOptionText(text, false))
+	if err != nil {
+		metrics.SlackAPIErrors.WithLabelValues("UpdateMessage").Inc()
+		return oops.Trace(err)
+	}
+	return nil
+}
+
+// PostBlocks implements chat.ChatTransport.
+func (t *Transport) PostBlocks(channelID string, blocks []chat.Block, threadTS string) (string, error) {
+	opts := []slack.MsgOption{slack.MsgOptionBlocks(toSlackBlocks(blocks)...)}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+
+	_, ts, err := t.client.PostMessage(channelID, opts...)
+	if err != nil {
+		metrics.SlackAPIErrors.WithLabelValues("PostBlocks").Inc()
+		return "", oops.Trace(err)
+	}
+	return ts, nil
+}
+
+// UpdateBlocks implements chat.ChatTransport.
+func (t *Transport) UpdateBlocks(channelID, ts string, blocks []chat.Block) error {
+	_, _, _, err := t.client.UpdateMessage(channelID, ts, slack.MsgOptionBlocks(toSlackBlocks(blocks)...))
+	if err != nil {
+		metrics.SlackAPIErrors.WithLabelValues("UpdateBlocks").Inc()
+		return oops.Trace(err)
+	}
+	return nil
+}
+
+// SendInteractive implements chat.ChatTransport. Slack has no separate
+// "interactive" post call; buttons are just another block, so this is
+// PostBlocks under a name that documents intent at the call site.
+func (t *Transport) SendInteractive(channelID string, blocks []chat.Block, threadTS string) (string, error) {
+	return t.PostBlocks(channelID, blocks, threadTS)
+}
+
+// PostEphemeral implements chat.ChatTransport.
+func (t *Transport) PostEphemeral(channelID, userID, text string) error {
+	_, err := t.client.PostEphemeral(channelID, userID, slack.MsgOptionText(text, false))
+	if err != nil {
+		metrics.SlackAPIErrors.WithLabelValues("PostEphemeral").Inc()
+		return oops.Trace(err)
+	}
+	return nil
+}
+
+// toSlackBlocks converts the transport-agnostic chat.Block list into Slack
+// Block Kit blocks.
+func toSlackBlocks(blocks []chat.Block) []slack.Block {
+	out := make([]slack.Block, 0, len(blocks))
+	for _, b := range blocks {
+		switch v := b.(type) {
+		case chat.Section:
+			text := slack.NewTextBlockObject("mrkdwn", v.Text.Markdown, false, false)
+			out = append(out, slack.NewSectionBlock(text, nil, nil))
+		case chat.Context:
+			elements := make([]slack.MixedElement, 0, len(v.Elements))
+			for _, e := range v.Elements {
+				elements = append(elements, slack.NewTextBlockObject("mrkdwn", e.Markdown, false, false))
+			}
+			out = append(out, slack.NewContextBlock("", elements...))
+		case chat.Actions:
+			elements := make([]slack.BlockElement, 0, len(v.Buttons))
+			for _, btn := range v.Buttons {
+				button := slack.NewButtonBlockElement(
+					btn.ActionID,
+					btn.Value,
+					slack.NewTextBlockObject("plain_text", btn.Label, false, false),
+				)
+				button.Style = slack.Style(btn.Style)
+				elements = append(elements, button)
+			}
+			out = append(out, slack.NewActionBlock(v.BlockID, elements...))
+		}
+	}
+	return out
+}
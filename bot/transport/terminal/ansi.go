@@ -0,0 +1,49 @@
+package terminal
+
+import "regexp"
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiItal  = "\x1b[3m"
+	ansiStrk  = "\x1b[9m"
+	ansiDim   = "\x1b[2m"
+	ansiCyan  = "\x1b[36m"
+	ansiGreen = "\x1b[32m"
+	ansiRed   = "\x1b[31m"
+)
+
+// mrkdwn markup patterns, matched in the order they should be applied so
+// that e.g. bold doesn't eat the asterisks inside a code span.
+var (
+	reCodeBlock = regexp.MustCompile("(?s)```(?:\\n)?(.*?)```")
+	reCode      = regexp.MustCompile("`([^`]+)`")
+	reBold      = regexp.MustCompile(`\*([^*]+)\*`)
+	reItalic    = regexp.MustCompile(`_([^_]+)_`)
+	reStrike    = regexp.MustCompile(`~([^~]+)~`)
+	reLink      = regexp.MustCompile(`<([^|>]+)\|([^>]+)>`)
+	reQuote     = regexp.MustCompile(`(?m)^> ?(.*)$`)
+)
+
+// renderANSI renders text already converted to Slack's mrkdwn format (see
+// mrkdwn.ConvertMarkdownToMrkdwn) as ANSI-decorated terminal output, so the
+// terminal transport can reuse the same formatting Slack sees instead of
+// its own markdown dialect.
+func renderANSI(mrkdwn string) string {
+	s := mrkdwn
+	s = reCodeBlock.ReplaceAllString(s, ansiDim+ansiCyan+"$1"+ansiReset)
+	s = reCode.ReplaceAllString(s, ansiCyan+"$1"+ansiReset)
+	s = reBold.ReplaceAllString(s, ansiBold+"$1"+ansiReset)
+	s = reItalic.ReplaceAllString(s, ansiItal+"$1"+ansiReset)
+	s = reStrike.ReplaceAllString(s, ansiStrk+"$1"+ansiReset)
+	s = reLink.ReplaceAllString(s, ansiItal+"$2 ($1)"+ansiReset)
+	s = reQuote.ReplaceAllString(s, ansiDim+"> $1"+ansiReset)
+	return s
+}
+
+// dim wraps s in the ANSI dim attribute, used for out-of-band terminal
+// transport chrome (update markers, context blocks) rather than message
+// content.
+func dim(s string) string {
+	return ansiDim + s + ansiReset
+}
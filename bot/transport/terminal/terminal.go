@@ -0,0 +1,231 @@
+// Package terminal implements chat.ChatTransport over the local TTY, so the
+// bot can be driven without a Slack workspace during development. Input
+// lines become chat events; posted messages and blocks are rendered as ANSI
+// to stdout.
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/calebcase/clod/bot/chat"
+)
+
+// channelID and threadTS are fixed since the terminal transport models a
+// single local conversation rather than Slack's channels/threads.
+const (
+	channelID = "terminal"
+	threadTS  = "terminal-thread"
+)
+
+// Transport is a chat.ChatTransport that reads lines from in and writes
+// ANSI-rendered output to out, for local testing without Slack.
+type Transport struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	events chan chat.ChatEvent
+
+	mu       sync.Mutex
+	pending  []chat.Button // buttons from the most recently sent interactive prompt
+	promptID string        // message ID the pending buttons belong to
+
+	nextID atomic.Int64
+}
+
+// New wraps in/out as a local terminal transport and starts reading input
+// in the background. Call Events to receive what it reads.
+func New(in io.Reader, out io.Writer) *Transport {
+	t := &Transport{
+		in:     bufio.NewScanner(in),
+		out:    out,
+		events: make(chan chat.ChatEvent, 16),
+	}
+	go t.readLoop()
+	return t
+}
+
+// readLoop turns stdin lines into ChatEvents: a bare number answers the most
+// recent interactive prompt's buttons, a line starting with "/" is a slash
+// command (e.g. "/clod deprecation upstream-deprecation.md"), "@task_name:
+// ..." starts or continues a task the same way an app_mention would on
+// Slack, and anything else is a plain thread reply.
+func (t *Transport) readLoop() {
+	defer close(t.events)
+
+	for t.in.Scan() {
+		line := strings.TrimSpace(t.in.Text())
+		if line == "" {
+			continue
+		}
+
+		if choice, ok := t.resolveChoice(line); ok {
+			t.events <- chat.ChatEvent{
+				Type:        chat.EventInteraction,
+				Channel:     channelID,
+				User:        "local",
+				TimeStamp:   t.promptID,
+				Interaction: &choice,
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			cmd, text, _ := strings.Cut(line, " ")
+			t.events <- chat.ChatEvent{
+				Type:      chat.EventSlashCommand,
+				Channel:   channelID,
+				User:      "local",
+				Command:   cmd,
+				Text:      strings.TrimSpace(text),
+				TimeStamp: t.newID(),
+			}
+			continue
+		}
+
+		t.events <- chat.ChatEvent{
+			Type:            chat.EventAppMention,
+			Channel:         channelID,
+			User:            "local",
+			Text:            line,
+			TimeStamp:       t.newID(),
+			ThreadTimeStamp: threadTS,
+		}
+	}
+}
+
+// resolveChoice matches a line like "1" against the buttons from the most
+// recently sent interactive prompt.
+func (t *Transport) resolveChoice(line string) (chat.InteractionEvent, bool) {
+	idx, err := strconv.Atoi(line)
+	if err != nil {
+		return chat.InteractionEvent{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if idx < 1 || idx > len(t.pending) {
+		return chat.InteractionEvent{}, false
+	}
+	btn := t.pending[idx-1]
+	return chat.InteractionEvent{
+		ActionID:  btn.ActionID,
+		Value:     btn.Value,
+		UserID:    "local",
+		UserName:  "local",
+		Channel:   channelID,
+		MessageTS: t.promptID,
+	}, true
+}
+
+// newID returns a synthetic, monotonically increasing message ID in place
+// of Slack's message timestamps.
+func (t *Transport) newID() string {
+	return fmt.Sprintf("term-%d", t.nextID.Add(1))
+}
+
+// Events implements chat.ChatTransport.
+func (t *Transport) Events() <-chan chat.ChatEvent {
+	return t.events
+}
+
+// Ack implements chat.ChatTransport. There's no envelope to acknowledge
+// locally, so this is a no-op.
+func (t *Transport) Ack(chat.ChatEvent) {}
+
+// PostMessage implements chat.ChatTransport.
+func (t *Transport) PostMessage(_, text, _ string) (string, error) {
+	id := t.newID()
+	fmt.Fprintln(t.out, renderANSI(text))
+	return id, nil
+}
+
+// UpdateMessage implements chat.ChatTransport. The terminal can't rewrite a
+// previous line, so updates are printed as a new line marked "(update)".
+func (t *Transport) UpdateMessage(_, ts, text string) error {
+	fmt.Fprintf(t.out, "%s %s\n", dim("(update "+ts+")"), renderANSI(text))
+	return nil
+}
+
+// PostEphemeral implements chat.ChatTransport. The terminal has only one
+// local user, so there's no one else to hide the text from; it's printed
+// the same as PostMessage, marked "(ephemeral)" for parity with Slack.
+func (t *Transport) PostEphemeral(_, _, text string) error {
+	fmt.Fprintf(t.out, "%s %s\n", dim("(ephemeral)"), renderANSI(text))
+	return nil
+}
+
+// PostBlocks implements chat.ChatTransport.
+func (t *Transport) PostBlocks(_ string, blocks []chat.Block, _ string) (string, error) {
+	id := t.newID()
+	t.renderBlocks(blocks, nil)
+	return id, nil
+}
+
+// UpdateBlocks implements chat.ChatTransport.
+func (t *Transport) UpdateBlocks(_, ts string, blocks []chat.Block) error {
+	fmt.Fprintln(t.out, dim("(update "+ts+")"))
+	t.renderBlocks(blocks, nil)
+	return nil
+}
+
+// SendInteractive implements chat.ChatTransport, additionally remembering
+// the prompt's buttons so a later bare number from readLoop can answer it.
+func (t *Transport) SendInteractive(_ string, blocks []chat.Block, _ string) (string, error) {
+	id := t.newID()
+
+	var buttons []chat.Button
+	t.renderBlocks(blocks, &buttons)
+
+	t.mu.Lock()
+	t.pending = buttons
+	t.promptID = id
+	t.mu.Unlock()
+
+	return id, nil
+}
+
+// renderBlocks writes blocks to t.out as ANSI text. If collect is non-nil,
+// every button encountered is appended to it and printed with a "[n]"
+// prefix the user can type back.
+func (t *Transport) renderBlocks(blocks []chat.Block, collect *[]chat.Button) {
+	for _, b := range blocks {
+		switch v := b.(type) {
+		case chat.Section:
+			fmt.Fprintln(t.out, renderANSI(v.Text.Markdown))
+		case chat.Context:
+			parts := make([]string, 0, len(v.Elements))
+			for _, e := range v.Elements {
+				parts = append(parts, renderANSI(e.Markdown))
+			}
+			fmt.Fprintln(t.out, dim(strings.Join(parts, "  ")))
+		case chat.Actions:
+			for _, btn := range v.Buttons {
+				if collect != nil {
+					*collect = append(*collect, btn)
+					fmt.Fprintf(t.out, "  [%d] %s\n", len(*collect), styleButton(btn))
+				} else {
+					fmt.Fprintf(t.out, "  - %s\n", styleButton(btn))
+				}
+			}
+		}
+	}
+}
+
+// styleButton renders a button's label, colored by its Style.
+func styleButton(btn chat.Button) string {
+	switch btn.Style {
+	case "primary":
+		return ansiGreen + btn.Label + ansiReset
+	case "danger":
+		return ansiRed + btn.Label + ansiReset
+	default:
+		return btn.Label
+	}
+}